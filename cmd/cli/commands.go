@@ -0,0 +1,719 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
+)
+
+// profileInfo is the JSON form of a profile in listings
+type profileInfo struct {
+	Name       string          `json:"name"`
+	Scope      string          `json:"scope"`
+	Character  string          `json:"character,omitempty"`
+	Active     bool            `json:"active"`
+	AddonCount int             `json:"addon_count"`
+	AutoDeps   bool            `json:"auto_deps"`
+	Created    int64           `json:"created,omitempty"`
+	Addons     map[string]bool `json:"addons,omitempty"`
+}
+
+// flavors lists the game clients found under the install path
+func (c *cli) flavors(args []string) error {
+	if len(args) != 0 {
+		return usageError{"flavors takes no arguments"}
+	}
+
+	flavors, err := wow.DetectFlavors(c.cfg.WowInstallPath)
+	if err != nil {
+		return err
+	}
+
+	type flavorInfo struct {
+		Dir      string `json:"dir"`
+		Name     string `json:"name"`
+		Selected bool   `json:"selected"`
+	}
+
+	infos := []flavorInfo{}
+	for _, flavor := range flavors {
+		infos = append(infos, flavorInfo{
+			Dir:      flavor.Dir,
+			Name:     flavor.Name,
+			Selected: flavor.Dir == c.cfg.Flavor,
+		})
+	}
+
+	return c.print(infos, func() {
+		if len(infos) == 0 {
+			fmt.Fprintln(c.stdout, "The install path is a single game client")
+			return
+		}
+		for _, info := range infos {
+			fmt.Fprintf(c.stdout, "%s %-20s %s\n", marker(info.Selected), info.Dir, info.Name)
+		}
+	})
+}
+
+// accounts lists the accounts of the selected client
+func (c *cli) accounts(args []string) error {
+	if len(args) != 0 {
+		return usageError{"accounts takes no arguments"}
+	}
+
+	summaries, err := c.manager.GetAccountSummaries()
+	if err != nil {
+		return err
+	}
+
+	type accountInfo struct {
+		Name          string `json:"name"`
+		HasProfilesDB bool   `json:"has_profiles_db"`
+		ProfileCount  int    `json:"profile_count"`
+		Selected      bool   `json:"selected"`
+		Error         string `json:"error,omitempty"`
+	}
+
+	infos := []accountInfo{}
+	for _, summary := range summaries {
+		info := accountInfo{
+			Name:          summary.Name,
+			HasProfilesDB: summary.HasProfilesDB,
+			ProfileCount:  summary.ProfileCount,
+			Selected:      summary.Name == c.cfg.SelectedAccount,
+		}
+		if summary.Err != nil {
+			info.Error = summary.Err.Error()
+		}
+		infos = append(infos, info)
+	}
+
+	return c.print(infos, func() {
+		for _, info := range infos {
+			detail := fmt.Sprintf("%d profiles", info.ProfileCount)
+			if !info.HasProfilesDB {
+				detail = "no AddonProfilesDB.lua"
+			} else if info.Error != "" {
+				detail = "error: " + info.Error
+			}
+			fmt.Fprintf(c.stdout, "%s %-20s %s\n", marker(info.Selected), info.Name, detail)
+		}
+	})
+}
+
+// profiles runs the "profiles" subcommands: list, show, create, rename,
+// delete and activate. Edits apply to the account's profiles, or to those
+// of --character.
+func (c *cli) profiles(args []string) error {
+	if len(args) == 0 {
+		return usageError{"profiles requires a subcommand: list, show, create, rename, delete, activate or check"}
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError{"profiles list takes no arguments"}
+		}
+		return c.listProfiles()
+	case "show":
+		if len(args) != 2 {
+			return usageError{"profiles show requires a profile name"}
+		}
+		return c.showProfile(args[1])
+	case "create":
+		if len(args) != 2 {
+			return usageError{"profiles create requires a profile name"}
+		}
+		return c.editProfile(func(char *wow.Character) (string, error) {
+			profile, err := c.manager.CreateProfileFromAddons(args[1], char)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created profile '%s' with %d addons", profile.Name, len(profile.Addons)), nil
+		})
+	case "rename":
+		if len(args) != 3 {
+			return usageError{"profiles rename requires the current and the new name"}
+		}
+		return c.editProfile(func(char *wow.Character) (string, error) {
+			return fmt.Sprintf("Renamed profile '%s' to '%s'", args[1], args[2]), c.manager.RenameProfile(args[1], args[2], char)
+		})
+	case "delete":
+		if len(args) != 2 {
+			return usageError{"profiles delete requires a profile name"}
+		}
+		return c.editProfile(func(char *wow.Character) (string, error) {
+			return fmt.Sprintf("Deleted profile '%s'", args[1]), c.manager.DeleteProfile(args[1], char)
+		})
+	case "activate":
+		if len(args) != 2 {
+			return usageError{"profiles activate requires a profile name"}
+		}
+		return c.editProfile(func(char *wow.Character) (string, error) {
+			return fmt.Sprintf("Profile '%s' is now active", args[1]), c.manager.SetActiveProfile(args[1], char)
+		})
+	case "check":
+		if len(args) != 1 {
+			return usageError{"profiles check takes no arguments"}
+		}
+		return c.checkProfiles()
+	default:
+		return usageError{fmt.Sprintf("unknown profiles subcommand: %s", args[0])}
+	}
+}
+
+// editProfile runs a profile edit for the account or --character and
+// reports the message it returns
+func (c *cli) editProfile(edit func(char *wow.Character) (string, error)) error {
+	char, err := c.character()
+	if err != nil {
+		return err
+	}
+
+	message, err := edit(char)
+	if err != nil {
+		return err
+	}
+
+	return c.print(map[string]string{"result": message}, func() {
+		fmt.Fprintln(c.stdout, message)
+	})
+}
+
+// loadProfiles loads the profiles, warning on stderr when the file is
+// damaged and only the lenient fallback parser could read part of it, or
+// when entries of the wrong type were skipped
+func (c *cli) loadProfiles() (*lua.Database, error) {
+	db, report, err := c.manager.LoadProfilesWithReport()
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Recovered() {
+		fmt.Fprintf(c.stderr, "Warning: AddonProfilesDB.lua is damaged and was read with the lenient fallback parser, some profiles may be missing: %v\n", report.Errors[0])
+		if parseErr, ok := report.Errors[0].(*lua.ParseError); ok {
+			for _, line := range strings.Split(parseErr.Excerpt, "\n") {
+				fmt.Fprintf(c.stderr, "  %s\n", line)
+			}
+		}
+	} else if !report.Complete() {
+		fmt.Fprintf(c.stderr, "Warning: AddonProfilesDB.lua has an entry of the wrong type, which was skipped: %v\n", report.Errors[0])
+	}
+	if len(report.Disagreements) > 0 {
+		fmt.Fprintf(c.stderr, "Warning: the simple and regex parsers read AddonProfilesDB.lua differently:\n")
+		for _, d := range report.Disagreements {
+			fmt.Fprintf(c.stderr, "  %s\n", d)
+		}
+	}
+	return db, nil
+}
+
+// parseCheck is the JSON form of a parse report
+type parseCheck struct {
+	Parser        string             `json:"parser"`
+	Recovered     bool               `json:"recovered"`
+	Errors        []string           `json:"errors"`
+	Disagreements []disagreementInfo `json:"disagreements"`
+}
+
+// disagreementInfo is the JSON form of a value the parsers read differently
+type disagreementInfo struct {
+	Path   string `json:"path"`
+	Simple string `json:"simple"`
+	Regex  string `json:"regex"`
+}
+
+// checkProfiles reads AddonProfilesDB.lua with both parsers and reports
+// which one was used and where they disagree
+func (c *cli) checkProfiles() error {
+	opts := c.manager.ParseOptions()
+	opts.Compare = true
+	c.manager.SetParseOptions(opts)
+
+	_, report, err := c.manager.LoadProfilesWithReport()
+	if report == nil {
+		return err
+	}
+
+	check := parseCheck{
+		Parser:        report.Parser,
+		Recovered:     report.Recovered(),
+		Errors:        []string{},
+		Disagreements: []disagreementInfo{},
+	}
+	for _, parseErr := range report.Errors {
+		check.Errors = append(check.Errors, parseErr.Error())
+	}
+	for _, d := range report.Disagreements {
+		check.Disagreements = append(check.Disagreements, disagreementInfo{Path: d.Path, Simple: d.Simple, Regex: d.Regex})
+	}
+
+	if printErr := c.print(check, func() {
+		fmt.Fprintf(c.stdout, "Parser:    %s\n", check.Parser)
+		for _, parseErr := range check.Errors {
+			fmt.Fprintf(c.stdout, "Error:     %s\n", parseErr)
+		}
+		if len(report.Disagreements) == 0 && err == nil && report.Complete() {
+			fmt.Fprintln(c.stdout, "The simple and regex parsers agree")
+		}
+		for _, d := range report.Disagreements {
+			fmt.Fprintf(c.stdout, "Differs:   %s\n", d)
+		}
+	}); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// listProfiles prints every profile, or only those of --character
+func (c *cli) listProfiles() error {
+	db, err := c.loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	infos := []profileInfo{}
+	if c.opts.character == "" {
+		for _, name := range sortedProfileNames(db.Global.Profiles) {
+			infos = append(infos, newProfileInfo(db.Global.Profiles[name], "", name == db.Global.ActiveProfile))
+		}
+	}
+
+	var charKeys []string
+	for charKey := range db.Char {
+		if c.opts.character == "" || strings.EqualFold(charKey, c.opts.character) {
+			charKeys = append(charKeys, charKey)
+		}
+	}
+	sort.Strings(charKeys)
+
+	for _, charKey := range charKeys {
+		charData := db.Char[charKey]
+		for _, name := range sortedProfileNames(charData.Profiles) {
+			infos = append(infos, newProfileInfo(charData.Profiles[name], charKey, name == charData.ActiveProfile))
+		}
+	}
+
+	return c.print(infos, func() {
+		for _, info := range infos {
+			scope := info.Scope
+			if info.Character != "" {
+				scope = info.Character
+			}
+			fmt.Fprintf(c.stdout, "%s %-24s %-30s %d addons\n", marker(info.Active), info.Name, scope, info.AddonCount)
+		}
+	})
+}
+
+// showProfile prints a profile with its addons
+func (c *cli) showProfile(name string) error {
+	profile, charKey, active, err := c.findProfile(name)
+	if err != nil {
+		return err
+	}
+
+	info := newProfileInfo(profile, charKey, active)
+	info.Addons = profile.Addons
+
+	return c.print(info, func() {
+		fmt.Fprintf(c.stdout, "Name:      %s\n", info.Name)
+		if info.Character != "" {
+			fmt.Fprintf(c.stdout, "Scope:     %s (%s)\n", info.Scope, info.Character)
+		} else {
+			fmt.Fprintf(c.stdout, "Scope:     %s\n", info.Scope)
+		}
+		fmt.Fprintf(c.stdout, "Active:    %v\n", info.Active)
+		fmt.Fprintf(c.stdout, "Auto deps: %v\n", info.AutoDeps)
+		if info.Created != 0 {
+			fmt.Fprintf(c.stdout, "Created:   %s\n", time.Unix(info.Created, 0).Format(time.RFC3339))
+		}
+		fmt.Fprintf(c.stdout, "AddOns:    %d\n", info.AddonCount)
+		for _, addon := range sortedAddonNames(profile.Addons) {
+			fmt.Fprintf(c.stdout, "  %s %s\n", enabledMarker(profile.Addons[addon]), addon)
+		}
+	})
+}
+
+// apply applies a profile to the account, a character or every character
+func (c *cli) apply(args []string) error {
+	if len(args) != 1 {
+		return usageError{"apply requires a profile name"}
+	}
+	if c.opts.allCharacters && c.opts.character != "" {
+		return usageError{"--character and --all-characters cannot be combined"}
+	}
+
+	profile, _, _, err := c.findProfile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if c.opts.wait {
+		if err := c.waitForClientExit(); err != nil {
+			return err
+		}
+	}
+
+	var results []*wow.ApplyResult
+	switch {
+	case c.opts.allCharacters:
+		results, err = c.manager.ApplyProfileToAllCharacters(profile)
+	case c.opts.character != "":
+		var char wow.Character
+		char, err = c.manager.FindCharacter(c.opts.character)
+		if err == nil {
+			var result *wow.ApplyResult
+			if result, err = c.manager.ApplyProfileToCharacter(profile, char); err == nil {
+				results = append(results, result)
+			}
+		}
+	default:
+		var result *wow.ApplyResult
+		if result, err = c.manager.ApplyProfile(profile); err == nil {
+			results = append(results, result)
+		}
+	}
+	if err != nil {
+		var running *wow.ClientRunningError
+		if errors.As(err, &running) {
+			return fmt.Errorf("%w, or pass --wait to apply once it exits", err)
+		}
+		// With --all-characters some characters may have been updated
+		// before others failed; report those before the error
+		if len(results) == 0 {
+			return err
+		}
+	}
+
+	type applyInfo struct {
+		Path              string              `json:"path"`
+		DependenciesAdded []string            `json:"dependencies_added,omitempty"`
+		MissingDeps       map[string][]string `json:"missing_dependencies,omitempty"`
+		Cycles            [][]string          `json:"dependency_cycles,omitempty"`
+	}
+
+	infos := []applyInfo{}
+	for _, result := range results {
+		info := applyInfo{Path: result.Path}
+		if result.Dependencies != nil {
+			info.DependenciesAdded = result.Dependencies.Added
+			if len(result.Dependencies.Missing) > 0 {
+				info.MissingDeps = result.Dependencies.Missing
+			}
+			info.Cycles = result.Dependencies.Cycles
+		}
+		infos = append(infos, info)
+	}
+
+	if printErr := c.print(infos, func() {
+		for _, info := range infos {
+			fmt.Fprintf(c.stdout, "Applied '%s' to %s\n", profile.Name, info.Path)
+			if len(info.DependenciesAdded) > 0 {
+				fmt.Fprintf(c.stdout, "  Enabled as dependencies: %s\n", strings.Join(info.DependenciesAdded, ", "))
+			}
+			for _, addon := range sortedKeys(info.MissingDeps) {
+				fmt.Fprintf(c.stdout, "  Warning: %s requires missing %s\n", addon, strings.Join(info.MissingDeps[addon], ", "))
+			}
+			for _, cycle := range info.Cycles {
+				fmt.Fprintf(c.stdout, "  Warning: dependency cycle %s\n", strings.Join(cycle, " -> "))
+			}
+		}
+	}); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
+
+// waitForClientExit blocks until no WoW client is running
+func (c *cli) waitForClientExit() error {
+	processes, err := c.manager.RunningClients()
+	if err != nil || len(processes) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(c.stderr, "Waiting for World of Warcraft to exit...")
+	return c.manager.WaitForClientExit(context.Background(), clientPollInterval)
+}
+
+// diff shows which addons applying a profile would enable and disable
+func (c *cli) diff(args []string) error {
+	if len(args) != 1 {
+		return usageError{"diff requires a profile name"}
+	}
+
+	profile, _, _, err := c.findProfile(args[0])
+	if err != nil {
+		return err
+	}
+
+	char, err := c.character()
+	if err != nil {
+		return err
+	}
+
+	diff, err := c.manager.DiffProfile(profile, char)
+	if err != nil {
+		return err
+	}
+
+	return c.printDiff(diff)
+}
+
+// printDiff prints the changes of a diff, or the diff itself with --json
+func (c *cli) printDiff(diff *wow.ProfileDiff) error {
+	type diffInfo struct {
+		Path      string   `json:"path"`
+		Enabled   []string `json:"enabled"`
+		Disabled  []string `json:"disabled"`
+		Added     []string `json:"added"`
+		Removed   []string `json:"removed"`
+		Unchanged []string `json:"unchanged"`
+	}
+
+	info := diffInfo{
+		Path:      diff.Path,
+		Enabled:   nonNil(diff.Enabled),
+		Disabled:  nonNil(diff.Disabled),
+		Added:     nonNil(diff.Added),
+		Removed:   nonNil(diff.Removed),
+		Unchanged: nonNil(diff.Unchanged),
+	}
+
+	return c.print(info, func() {
+		for _, addon := range info.Enabled {
+			fmt.Fprintf(c.stdout, "+ %s\n", addon)
+		}
+		for _, addon := range info.Added {
+			if diff.Result[addon] {
+				fmt.Fprintf(c.stdout, "+ %s (new)\n", addon)
+			} else {
+				fmt.Fprintf(c.stdout, "  %s (new, disabled)\n", addon)
+			}
+		}
+		for _, addon := range info.Disabled {
+			fmt.Fprintf(c.stdout, "- %s\n", addon)
+		}
+		for _, addon := range info.Removed {
+			fmt.Fprintf(c.stdout, "- %s (removed)\n", addon)
+		}
+		fmt.Fprintf(c.stdout, "%d enabled, %d disabled, %d added, %d removed, %d unchanged\n",
+			len(info.Enabled), len(info.Disabled), len(info.Added), len(info.Removed), len(info.Unchanged))
+	})
+}
+
+// backups runs the "backups" subcommands: list, restore, diff, pin and unpin
+func (c *cli) backups(args []string) error {
+	if len(args) == 0 {
+		return usageError{"backups requires a subcommand: list, restore, diff, pin or unpin"}
+	}
+
+	char, err := c.character()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError{"backups list takes no arguments"}
+		}
+
+		backups, err := c.manager.ListBackups(char)
+		if err != nil {
+			return err
+		}
+
+		type backupInfo struct {
+			Name    string    `json:"name"`
+			Path    string    `json:"path"`
+			Created time.Time `json:"created"`
+			Profile string    `json:"profile,omitempty"`
+			Reason  string    `json:"reason,omitempty"`
+			Pinned  bool      `json:"pinned"`
+		}
+
+		infos := []backupInfo{}
+		for _, backup := range backups {
+			infos = append(infos, backupInfo{
+				Name:    backup.Name,
+				Path:    backup.Path,
+				Created: backup.Created,
+				Profile: backup.Profile,
+				Reason:  backup.Reason,
+				Pinned:  backup.Pinned,
+			})
+		}
+
+		return c.print(infos, func() {
+			for _, info := range infos {
+				pin := " "
+				if info.Pinned {
+					pin = "*"
+				}
+				fmt.Fprintf(c.stdout, "%s %s  %s", pin, info.Created.Format("2006-01-02 15:04:05"), info.Name)
+				if info.Reason != "" {
+					fmt.Fprintf(c.stdout, "  (%s)", info.Reason)
+				}
+				fmt.Fprintln(c.stdout)
+			}
+		})
+	case "restore":
+		if len(args) != 2 {
+			return usageError{"backups restore requires a backup name"}
+		}
+
+		if err := c.manager.RestoreBackup(char, args[1]); err != nil {
+			return err
+		}
+
+		path := c.manager.AddOnsPath(char)
+		return c.print(map[string]string{"restored": args[1], "path": path}, func() {
+			fmt.Fprintf(c.stdout, "Restored %s to %s\n", args[1], path)
+		})
+	case "diff":
+		if len(args) != 2 {
+			return usageError{"backups diff requires a backup name"}
+		}
+
+		diff, err := c.manager.DiffBackup(char, args[1])
+		if err != nil {
+			return err
+		}
+		return c.printDiff(diff)
+	case "pin", "unpin":
+		if len(args) != 2 {
+			return usageError{fmt.Sprintf("backups %s requires a backup name", args[0])}
+		}
+
+		pinned := args[0] == "pin"
+		if err := c.manager.PinBackup(char, args[1], pinned); err != nil {
+			return err
+		}
+
+		return c.print(map[string]interface{}{"backup": args[1], "pinned": pinned}, func() {
+			if pinned {
+				fmt.Fprintf(c.stdout, "Pinned %s\n", args[1])
+			} else {
+				fmt.Fprintf(c.stdout, "Unpinned %s\n", args[1])
+			}
+		})
+	default:
+		return usageError{fmt.Sprintf("unknown backups subcommand: %s", args[0])}
+	}
+}
+
+// character resolves --character to a character folder
+func (c *cli) character() (*wow.Character, error) {
+	if c.opts.character == "" {
+		return nil, nil
+	}
+
+	char, err := c.manager.FindCharacter(c.opts.character)
+	if err != nil {
+		return nil, err
+	}
+	return &char, nil
+}
+
+// findProfile looks a profile up by name. With --character the character's
+// own profiles are searched before the account profiles.
+func (c *cli) findProfile(name string) (*lua.Profile, string, bool, error) {
+	db, err := c.loadProfiles()
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if c.opts.character != "" {
+		for charKey, charData := range db.Char {
+			if !strings.EqualFold(charKey, c.opts.character) {
+				continue
+			}
+			if profile, ok := charData.Profiles[name]; ok {
+				return profile, charKey, name == charData.ActiveProfile, nil
+			}
+		}
+	}
+
+	if profile, ok := db.Global.Profiles[name]; ok {
+		return profile, "", name == db.Global.ActiveProfile, nil
+	}
+
+	if c.opts.character == "" {
+		return nil, "", false, fmt.Errorf("profile not found: %s (use --character for character profiles)", name)
+	}
+	return nil, "", false, fmt.Errorf("profile not found: %s", name)
+}
+
+// newProfileInfo converts a profile for listing
+func newProfileInfo(profile *lua.Profile, charKey string, active bool) profileInfo {
+	return profileInfo{
+		Name:       profile.Name,
+		Scope:      profile.Scope,
+		Character:  charKey,
+		Active:     active,
+		AddonCount: len(profile.Addons),
+		AutoDeps:   profile.AutoDeps,
+		Created:    profile.Created,
+	}
+}
+
+// sortedProfileNames returns profile names in sorted order
+func sortedProfileNames(profiles map[string]*lua.Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedAddonNames returns addon names in sorted order
+func sortedAddonNames(addons map[string]bool) []string {
+	names := make([]string, 0, len(addons))
+	for name := range addons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys returns the keys of a string list map in sorted order
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nonNil turns a nil slice into an empty one so JSON shows []
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// marker returns the prefix for selected or active entries
+func marker(selected bool) string {
+	if selected {
+		return "*"
+	}
+	return " "
+}
+
+// enabledMarker returns the checkbox shown for an addon state
+func enabledMarker(enabled bool) string {
+	if enabled {
+		return "[x]"
+	}
+	return "[ ]"
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/config"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/version"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// clientPollInterval is how often --wait checks whether WoW has exited
+const clientPollInterval = 2 * time.Second
+
+const usage = `Usage: addonprofiles [options] <command> [arguments]
+
+Commands:
+  flavors                       List the game clients under the install path
+  accounts                      List accounts and their profile counts
+  profiles list                 List account and character profiles
+  profiles show <name>          Show the addons of a profile
+  profiles create <name>        Save the enabled addons of AddOns.txt as a profile
+  profiles rename <old> <new>   Rename a profile
+  profiles delete <name>        Delete a profile
+  profiles activate <name>      Mark a profile as active
+  profiles check                Report how AddonProfilesDB.lua was parsed and
+                                where the simple and regex parsers disagree
+  apply <name>                  Apply a profile to AddOns.txt
+  diff <name>                   Show what applying a profile would change
+  backups list                  List AddOns.txt backups
+  backups restore <backup>      Restore an AddOns.txt backup
+  backups diff <backup>         Show what restoring a backup would change
+  backups pin <backup>          Keep a backup regardless of the backup limit
+  backups unpin <backup>        Let a pinned backup be pruned again
+  version                       Print the version
+
+Options:
+  --wow-path <path>             WoW install path (default: from the GUI config)
+  --flavor <dir>                Game client, e.g. _retail_ or _classic_era_
+  --account <name>              Account folder under WTF/Account
+  --character "<Name - Realm>"  Use a character's profiles and AddOns.txt
+  --all-characters              apply: update every character of the account
+  --wait                        apply: wait for a running WoW client to exit first
+  --strict                      Fail on a damaged AddonProfilesDB.lua instead of
+                                recovering what the fallback parser can read
+  --compare-parsers             Also read AddonProfilesDB.lua with the regex
+                                parser and warn where the parsers disagree
+  --json                        Print machine-readable JSON
+`
+
+// knownCommands lists the commands that need a configured WoW install
+var knownCommands = map[string]bool{
+	"flavors":  true,
+	"accounts": true,
+	"profiles": true,
+	"apply":    true,
+	"diff":     true,
+	"backups":  true,
+}
+
+// options holds the flags shared by every command
+type options struct {
+	wowPath        string
+	flavor         string
+	account        string
+	character      string
+	allCharacters  bool
+	wait           bool
+	strict         bool
+	compareParsers bool
+	json           bool
+}
+
+// cli runs a single command
+type cli struct {
+	opts    options
+	cfg     *config.Config
+	manager *wow.Manager
+	stdout  io.Writer
+	stderr  io.Writer
+	// wroteJSON is set once a JSON document was written to stdout
+	wroteJSON bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("addonprofiles", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	fs.StringVar(&c.opts.wowPath, "wow-path", "", "WoW install path")
+	fs.StringVar(&c.opts.flavor, "flavor", "", "game client directory")
+	fs.StringVar(&c.opts.account, "account", "", "account folder")
+	fs.StringVar(&c.opts.character, "character", "", "character key")
+	fs.BoolVar(&c.opts.allCharacters, "all-characters", false, "apply to every character")
+	fs.BoolVar(&c.opts.wait, "wait", false, "wait for WoW to exit before applying")
+	fs.BoolVar(&c.opts.strict, "strict", false, "fail on damaged files")
+	fs.BoolVar(&c.opts.compareParsers, "compare-parsers", false, "compare the simple and regex parsers")
+	fs.BoolVar(&c.opts.json, "json", false, "print JSON")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if len(positional) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	if positional[0] == "version" {
+		err := c.print(map[string]string{"version": version.GetVersion()}, func() {
+			fmt.Fprintf(stdout, "addonprofiles %s\n", version.GetVersion())
+		})
+		if err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+
+	command, rest := positional[0], positional[1:]
+	if !knownCommands[command] {
+		return c.fail(usageError{fmt.Sprintf("unknown command: %s", command)})
+	}
+
+	if err := c.setup(command); err != nil {
+		return c.fail(err)
+	}
+
+	switch command {
+	case "flavors":
+		err = c.flavors(rest)
+	case "accounts":
+		err = c.accounts(rest)
+	case "profiles":
+		err = c.profiles(rest)
+	case "apply":
+		err = c.apply(rest)
+	case "diff":
+		err = c.diff(rest)
+	case "backups":
+		err = c.backups(rest)
+	}
+
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// setup loads the config and creates the WoW manager, with command line
+// options taking precedence over the GUI config
+func (c *cli) setup(command string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	c.cfg = cfg
+
+	if c.opts.wowPath != "" && c.opts.wowPath != cfg.WowInstallPath {
+		cfg.WowInstallPath = c.opts.wowPath
+		cfg.Flavor = ""
+		cfg.SelectedAccount = ""
+	}
+	if c.opts.flavor != "" {
+		cfg.Flavor = c.opts.flavor
+	}
+	if c.opts.account != "" {
+		cfg.SelectedAccount = c.opts.account
+	}
+
+	if cfg.WowInstallPath == "" {
+		return fmt.Errorf("WoW installation path is not set; use --wow-path")
+	}
+	if err := wow.ValidateWowDirectory(cfg.WowInstallPath); err != nil {
+		return err
+	}
+	if cfg.Flavor == "" {
+		cfg.Flavor = wow.DefaultFlavor(cfg.WowInstallPath)
+	}
+
+	c.manager = wow.NewManager(cfg.WowInstallPath, "", cfg.BackupCount)
+	c.manager.SetFlavor(cfg.Flavor)
+
+	mode, err := wow.ParseApplyMode(cfg.ApplyMode)
+	if err != nil {
+		return err
+	}
+	c.manager.SetApplyMode(mode)
+
+	parseOpts := lua.ParseOptions{Compare: c.opts.compareParsers}
+	if cfg.StrictParsing || c.opts.strict {
+		parseOpts.Mode = lua.ParseStrict
+	}
+	c.manager.SetParseOptions(parseOpts)
+	c.manager.SetWarningOutput(c.stderr)
+
+	// Commands that work across accounts don't need one selected
+	if command == "flavors" || command == "accounts" {
+		return nil
+	}
+
+	if cfg.SelectedAccount == "" {
+		accounts, err := c.manager.GetAccounts()
+		if err != nil {
+			return err
+		}
+		if len(accounts) != 1 {
+			return fmt.Errorf("found %d accounts; choose one with --account", len(accounts))
+		}
+		cfg.SelectedAccount = accounts[0]
+	}
+
+	c.manager = wow.NewManager(cfg.WowInstallPath, cfg.SelectedAccount, cfg.BackupCount)
+	c.manager.SetFlavor(cfg.Flavor)
+	c.manager.SetApplyMode(mode)
+	c.manager.SetParseOptions(parseOpts)
+	c.manager.SetWarningOutput(c.stderr)
+	return nil
+}
+
+// usageError marks errors caused by invalid command line arguments
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+// fail reports an error and returns the matching exit code. With --json
+// the error is written as a JSON document, unless the command already
+// wrote one, e.g. the results of the characters a profile was applied to,
+// so stdout stays a single JSON value.
+func (c *cli) fail(err error) int {
+	if c.opts.json && !c.wroteJSON {
+		if jsonErr := c.writeJSON(map[string]string{"error": err.Error()}); jsonErr != nil {
+			fmt.Fprintf(c.stderr, "Error: %v\n", err)
+		}
+	} else {
+		fmt.Fprintf(c.stderr, "Error: %v\n", err)
+	}
+
+	if _, ok := err.(usageError); ok {
+		if !c.opts.json {
+			fmt.Fprint(c.stderr, "\n"+usage)
+		}
+		return exitUsage
+	}
+	return exitError
+}
+
+// print writes value as JSON when --json is set, and calls text otherwise
+func (c *cli) print(value interface{}, text func()) error {
+	if c.opts.json {
+		return c.writeJSON(value)
+	}
+	text()
+	return nil
+}
+
+// writeJSON writes an indented JSON document to stdout
+func (c *cli) writeJSON(value interface{}) error {
+	c.wroteJSON = true
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
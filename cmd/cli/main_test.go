@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupInstall creates a retail install with one account and character
+// and points the config directory at a temporary location
+func setupInstall(t *testing.T) string {
+	t.Helper()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	root := t.TempDir()
+	accountDir := filepath.Join(root, "_retail_", "WTF", "Account", "12345#1")
+	os.MkdirAll(filepath.Join(accountDir, "SavedVariables"), 0755)
+	os.MkdirAll(filepath.Join(accountDir, "TestRealm", "TestChar"), 0755)
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "pkg", "wow", "testdata", "SavedVariables", "AddonProfilesDB.lua"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	os.WriteFile(filepath.Join(accountDir, "SavedVariables", "AddonProfilesDB.lua"), data, 0644)
+	os.WriteFile(filepath.Join(accountDir, "AddOns.txt"), []byte("Ace3: 1\nDetails: 1\n# BigWigs: 0\n"), 0644)
+
+	for _, addon := range []string{"Ace3", "Details", "BigWigs", "WeakAuras", "RCLootCouncil"} {
+		dir := filepath.Join(root, "_retail_", "Interface", "AddOns", addon)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, addon+".toc"), []byte("## Title: "+addon+"\n"), 0644)
+	}
+
+	return root
+}
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if code, _, _ := runCLI(t); code != exitUsage {
+		t.Errorf("no arguments: exit code = %d, want %d", code, exitUsage)
+	}
+
+	code, _, stderr := runCLI(t, "frobnicate")
+	if code != exitUsage {
+		t.Errorf("unknown command: exit code = %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(stderr, "unknown command") {
+		t.Errorf("stderr = %q, want unknown command error", stderr)
+	}
+
+	code, stdout, _ := runCLI(t, "version", "--json")
+	if code != exitOK || !strings.Contains(stdout, `"version"`) {
+		t.Errorf("version: exit code = %d, stdout = %q", code, stdout)
+	}
+
+	// No install path configured
+	if code, _, _ := runCLI(t, "accounts"); code != exitError {
+		t.Errorf("accounts without path: exit code = %d, want %d", code, exitError)
+	}
+}
+
+func TestRunProfilesList(t *testing.T) {
+	root := setupInstall(t)
+
+	code, stdout, stderr := runCLI(t, "--wow-path", root, "profiles", "list", "--json")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+
+	var profiles []profileInfo
+	if err := json.Unmarshal([]byte(stdout), &profiles); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, stdout)
+	}
+
+	if len(profiles) != 3 {
+		t.Fatalf("Expected 3 profiles, got %d", len(profiles))
+	}
+
+	if profiles[0].Name != "Default" || !profiles[0].Active {
+		t.Errorf("profiles[0] = %+v, want active Default", profiles[0])
+	}
+
+	if profiles[2].Name != "PvP" || profiles[2].Character != "TestChar - TestRealm" {
+		t.Errorf("profiles[2] = %+v, want PvP of TestChar - TestRealm", profiles[2])
+	}
+}
+
+func TestRunProfilesListDamaged(t *testing.T) {
+	root := setupInstall(t)
+
+	dbPath := filepath.Join(root, "_retail_", "WTF", "Account", "12345#1", "SavedVariables", "AddonProfilesDB.lua")
+	os.WriteFile(dbPath, []byte("AddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"activeProfile\"] = \"Default\"\n\t\t[\"profiles\"] = {},\n\t},\n}\n"), 0644)
+
+	code, _, stderr := runCLI(t, "--wow-path", root, "profiles", "list")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "line 4, column 3") || !strings.Contains(stderr, "^") {
+		t.Errorf("stderr = %q, want damaged file warning with position and excerpt", stderr)
+	}
+
+	code, _, stderr = runCLI(t, "--wow-path", root, "profiles", "list", "--strict")
+	if code != exitError || !strings.Contains(stderr, "line 4, column 3") {
+		t.Errorf("--strict: exit code = %d, stderr = %q, want error at line 4, column 3", code, stderr)
+	}
+}
+
+func TestRunProfilesCheck(t *testing.T) {
+	root := setupInstall(t)
+
+	code, stdout, stderr := runCLI(t, "--wow-path", root, "profiles", "check", "--json")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+
+	var check parseCheck
+	if err := json.Unmarshal([]byte(stdout), &check); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, stdout)
+	}
+	if check.Parser != "simple" || check.Recovered {
+		t.Errorf("check = %+v, want the simple parser", check)
+	}
+
+	// The regex parser reads nested profiles wrong
+	found := false
+	for _, d := range check.Disagreements {
+		if d.Path == "global.profiles.Raiding" && d.Simple == "present" && d.Regex == "missing" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Disagreements = %+v, want Raiding missing for the regex parser", check.Disagreements)
+	}
+
+	// --compare-parsers warns on stderr and leaves the JSON intact
+	code, stdout, stderr = runCLI(t, "--wow-path", root, "profiles", "list", "--json", "--compare-parsers")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "global.profiles.Raiding: simple parser read present, regex parser read missing") {
+		t.Errorf("stderr = %q, want the disagreements", stderr)
+	}
+	var profiles []profileInfo
+	if err := json.Unmarshal([]byte(stdout), &profiles); err != nil {
+		t.Errorf("Invalid JSON output: %v\n%s", err, stdout)
+	}
+}
+
+func TestRunApplyAndDiff(t *testing.T) {
+	root := setupInstall(t)
+
+	code, stdout, stderr := runCLI(t, "--wow-path", root, "diff", "Raiding", "--json")
+	if code != exitOK {
+		t.Fatalf("diff: exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"BigWigs"`) || !strings.Contains(stdout, `"Ace3"`) {
+		t.Errorf("diff output = %s, want BigWigs enabled and Ace3 disabled", stdout)
+	}
+
+	code, _, stderr = runCLI(t, "--wow-path", root, "apply", "Raiding")
+	if code != exitOK {
+		t.Fatalf("apply: exit code = %d, stderr = %s", code, stderr)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "_retail_", "WTF", "Account", "12345#1", "AddOns.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read AddOns.txt: %v", err)
+	}
+	if !strings.Contains(string(data), "BigWigs: 1") {
+		t.Errorf("AddOns.txt = %q, want BigWigs enabled", data)
+	}
+
+	code, stdout, _ = runCLI(t, "--wow-path", root, "backups", "list")
+	if code != exitOK || !strings.Contains(stdout, "AddOns.txt.backup.") || !strings.Contains(stdout, "Raiding") {
+		t.Errorf("backups list: exit code = %d, stdout = %q", code, stdout)
+	}
+
+	backup := strings.Fields(stdout)[2]
+	if code, _, stderr := runCLI(t, "--wow-path", root, "backups", "pin", backup); code != exitOK {
+		t.Errorf("backups pin: exit code = %d, stderr = %s", code, stderr)
+	}
+
+	code, stdout, _ = runCLI(t, "--wow-path", root, "backups", "diff", backup)
+	if code != exitOK || !strings.Contains(stdout, "- BigWigs") {
+		t.Errorf("backups diff: exit code = %d, stdout = %q", code, stdout)
+	}
+
+	// Character profiles need --character
+	if code, _, _ := runCLI(t, "--wow-path", root, "apply", "PvP"); code != exitError {
+		t.Errorf("apply PvP: exit code = %d, want %d", code, exitError)
+	}
+
+	code, _, stderr = runCLI(t, "--wow-path", root, "--character", "TestChar - TestRealm", "apply", "PvP")
+	if code != exitOK {
+		t.Fatalf("apply PvP to character: exit code = %d, stderr = %s", code, stderr)
+	}
+
+	data, err = os.ReadFile(filepath.Join(root, "_retail_", "WTF", "Account", "12345#1", "TestRealm", "TestChar", "AddOns.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read character AddOns.txt: %v", err)
+	}
+	if !strings.Contains(string(data), "Gladius: 1") {
+		t.Errorf("character AddOns.txt = %q, want Gladius enabled", data)
+	}
+}
+
+func TestRunApplyAllCharactersPartialFailure(t *testing.T) {
+	root := setupInstall(t)
+
+	// A folder where Broken's AddOns.txt should be makes that character fail
+	accountDir := filepath.Join(root, "_retail_", "WTF", "Account", "12345#1")
+	os.MkdirAll(filepath.Join(accountDir, "TestRealm", "Broken", "AddOns.txt"), 0755)
+
+	code, stdout, stderr := runCLI(t, "--wow-path", root, "--all-characters", "apply", "Raiding", "--json")
+	if code != exitError {
+		t.Errorf("exit code = %d, want %d", code, exitError)
+	}
+	if !strings.Contains(stderr, "Broken - TestRealm") {
+		t.Errorf("stderr = %q, want the failed character", stderr)
+	}
+
+	// stdout holds only the results of the characters that were updated
+	var results []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	if err := decoder.Decode(&results); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+	}
+	if decoder.More() {
+		t.Errorf("stdout holds more than one JSON value:\n%s", stdout)
+	}
+	if len(results) != 1 || !strings.Contains(results[0]["path"].(string), "TestChar") {
+		t.Errorf("results = %v, want TestChar only", results)
+	}
+}
+
+func TestRunProfileEdits(t *testing.T) {
+	root := setupInstall(t)
+
+	steps := [][]string{
+		{"profiles", "create", "Questing"},
+		{"profiles", "rename", "Questing", "Leveling"},
+		{"profiles", "activate", "Leveling"},
+		{"--character", "TestChar - TestRealm", "profiles", "delete", "PvP"},
+	}
+	for _, step := range steps {
+		args := append([]string{"--wow-path", root}, step...)
+		if code, _, stderr := runCLI(t, args...); code != exitOK {
+			t.Fatalf("%v: exit code = %d, stderr = %s", step, code, stderr)
+		}
+	}
+
+	code, stdout, _ := runCLI(t, "--wow-path", root, "profiles", "list")
+	if code != exitOK {
+		t.Fatalf("profiles list: exit code = %d", code)
+	}
+	if !strings.Contains(stdout, "* Leveling") {
+		t.Errorf("profiles list = %q, want Leveling active", stdout)
+	}
+	if strings.Contains(stdout, "Questing") || strings.Contains(stdout, "PvP") {
+		t.Errorf("profiles list = %q, want Questing renamed and PvP deleted", stdout)
+	}
+
+	if code, _, _ := runCLI(t, "--wow-path", root, "profiles", "delete", "Missing"); code != exitError {
+		t.Errorf("delete Missing: exit code = %d, want %d", code, exitError)
+	}
+}
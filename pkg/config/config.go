@@ -6,13 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/fsutil"
 )
 
 // Config represents the application configuration
 type Config struct {
 	WowInstallPath  string `json:"wow_install_path"`
+	Flavor          string `json:"flavor"` // client directory such as "_retail_", empty if WowInstallPath is one
 	SelectedAccount string `json:"selected_account"`
 	BackupCount     int    `json:"backup_count"`
+	ApplyMode       string `json:"apply_mode"`
+	StrictParsing   bool   `json:"strict_parsing"` // fail on a damaged AddonProfilesDB.lua instead of recovering what can be read
 }
 
 // DefaultConfig returns a config with default values
@@ -21,6 +26,7 @@ func DefaultConfig() *Config {
 		WowInstallPath:  "",
 		SelectedAccount: "",
 		BackupCount:     5,
+		ApplyMode:       "merge",
 	}
 }
 
@@ -88,6 +94,9 @@ func Load() (*Config, error) {
 	if config.BackupCount == 0 {
 		config.BackupCount = 5
 	}
+	if config.ApplyMode == "" {
+		config.ApplyMode = "merge"
+	}
 
 	return &config, nil
 }
@@ -104,7 +113,7 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -122,8 +131,8 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("WoW installation path does not exist: %s", c.WowInstallPath)
 	}
 
-	// Check if WTF directory exists
-	wtfPath := filepath.Join(c.WowInstallPath, "WTF")
+	// Check if WTF directory exists for the selected client
+	wtfPath := filepath.Join(c.WowInstallPath, c.Flavor, "WTF")
 	if _, err := os.Stat(wtfPath); os.IsNotExist(err) {
 		return fmt.Errorf("WTF directory not found at: %s", wtfPath)
 	}
@@ -132,5 +141,9 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("backup count must be at least 1")
 	}
 
+	if c.ApplyMode != "" && c.ApplyMode != "merge" && c.ApplyMode != "replace" {
+		return fmt.Errorf("apply mode must be \"merge\" or \"replace\"")
+	}
+
 	return nil
 }
@@ -21,6 +21,10 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.BackupCount != 5 {
 		t.Errorf("Expected BackupCount 5, got %d", cfg.BackupCount)
 	}
+
+	if cfg.ApplyMode != "merge" {
+		t.Errorf("Expected ApplyMode merge, got %s", cfg.ApplyMode)
+	}
 }
 
 func TestGetConfigPath(t *testing.T) {
@@ -87,8 +91,10 @@ func TestSaveAndLoad(t *testing.T) {
 	// Create test config
 	cfg := &Config{
 		WowInstallPath:  "/path/to/wow",
+		Flavor:          "_classic_",
 		SelectedAccount: "TestAccount",
 		BackupCount:     10,
+		StrictParsing:   true,
 	}
 
 	// Save
@@ -107,6 +113,10 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Errorf("WowInstallPath = %v, want %v", loaded.WowInstallPath, cfg.WowInstallPath)
 	}
 
+	if loaded.Flavor != cfg.Flavor {
+		t.Errorf("Flavor = %v, want %v", loaded.Flavor, cfg.Flavor)
+	}
+
 	if loaded.SelectedAccount != cfg.SelectedAccount {
 		t.Errorf("SelectedAccount = %v, want %v", loaded.SelectedAccount, cfg.SelectedAccount)
 	}
@@ -115,6 +125,10 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Errorf("BackupCount = %v, want %v", loaded.BackupCount, cfg.BackupCount)
 	}
 
+	if loaded.StrictParsing != cfg.StrictParsing {
+		t.Errorf("StrictParsing = %v, want %v", loaded.StrictParsing, cfg.StrictParsing)
+	}
+
 	// Cleanup
 	configPath, _ := GetConfigPath()
 	os.Remove(configPath)
@@ -179,6 +193,60 @@ func TestValidate(t *testing.T) {
 				os.RemoveAll(dir)
 			},
 		},
+		{
+			name: "valid config with flavor",
+			config: &Config{
+				WowInstallPath:  "",
+				Flavor:          "_classic_era_",
+				SelectedAccount: "TestAccount",
+				BackupCount:     5,
+			},
+			wantErr: false,
+			setup: func() string {
+				tmpDir, _ := os.MkdirTemp("", "wow-test-*")
+				os.MkdirAll(filepath.Join(tmpDir, "_classic_era_", "WTF"), 0755)
+				return tmpDir
+			},
+			cleanup: func(dir string) {
+				os.RemoveAll(dir)
+			},
+		},
+		{
+			name: "flavor without WTF directory",
+			config: &Config{
+				WowInstallPath:  "",
+				Flavor:          "_classic_",
+				SelectedAccount: "TestAccount",
+				BackupCount:     5,
+			},
+			wantErr: true,
+			setup: func() string {
+				tmpDir, _ := os.MkdirTemp("", "wow-test-*")
+				os.MkdirAll(filepath.Join(tmpDir, "_retail_", "WTF"), 0755)
+				return tmpDir
+			},
+			cleanup: func(dir string) {
+				os.RemoveAll(dir)
+			},
+		},
+		{
+			name: "invalid apply mode",
+			config: &Config{
+				WowInstallPath:  "",
+				SelectedAccount: "",
+				BackupCount:     5,
+				ApplyMode:       "overwrite",
+			},
+			wantErr: true,
+			setup: func() string {
+				tmpDir, _ := os.MkdirTemp("", "wow-test-*")
+				os.Mkdir(filepath.Join(tmpDir, "WTF"), 0755)
+				return tmpDir
+			},
+			cleanup: func(dir string) {
+				os.RemoveAll(dir)
+			},
+		},
 		{
 			name: "invalid backup count",
 			config: &Config{
@@ -240,6 +308,10 @@ func TestLoadWithMissingBackupCount(t *testing.T) {
 		t.Errorf("Expected default BackupCount 5, got %d", cfg.BackupCount)
 	}
 
+	if cfg.ApplyMode != "merge" {
+		t.Errorf("Expected default ApplyMode merge, got %s", cfg.ApplyMode)
+	}
+
 	// Cleanup
 	os.Remove(configPath)
 }
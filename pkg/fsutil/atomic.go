@@ -0,0 +1,82 @@
+// Package fsutil provides crash-safe file writes
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces path with data so that readers see either the
+// old or the new contents, never a partial write. The data goes to a temp
+// file in the same directory, is synced to disk and then renamed over
+// path. An existing file keeps its permissions; new files get perm.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteFileVerified(path, data, perm, nil)
+}
+
+// WriteFileVerified is WriteFileAtomic with a check of the written temp
+// file before it replaces path. If verify returns an error the temp file
+// is removed and path is left untouched.
+func WriteFileVerified(path string, data []byte, perm os.FileMode, verify func(tmpPath string) error) error {
+	dir := filepath.Dir(path)
+
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Don't leave the temp file behind on failure
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if verify != nil {
+		if err := verify(tmpPath); err != nil {
+			return fmt.Errorf("failed to verify %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	committed = true
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a rename to disk. Not every platform can sync a
+// directory, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
@@ -0,0 +1,104 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "AddOns.txt")
+
+	// New file
+	if err := WriteFileAtomic(path, []byte("first\n"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+
+	// Existing file is replaced
+	if err := WriteFileAtomic(path, []byte("second\n"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "second\n" {
+		t.Errorf("contents = %q, want %q", data, "second\n")
+	}
+
+	// No temp files are left behind
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(entries))
+	}
+}
+
+func TestWriteFileAtomicPreservesPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "config.json")
+	os.WriteFile(path, []byte("{}"), 0600)
+	os.Chmod(path, 0600)
+
+	if err := WriteFileAtomic(path, []byte(`{"a": 1}`), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permissions = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+}
+
+func TestWriteFileVerifiedFailure(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "AddOns.txt")
+	os.WriteFile(path, []byte("original\n"), 0644)
+
+	var checked string
+	err = WriteFileVerified(path, []byte("broken\n"), 0644, func(tmpPath string) error {
+		data, _ := os.ReadFile(tmpPath)
+		checked = string(data)
+		return fmt.Errorf("unexpected contents")
+	})
+	if err == nil {
+		t.Fatal("Expected verification error")
+	}
+
+	if checked != "broken\n" {
+		t.Errorf("verified contents = %q, want %q", checked, "broken\n")
+	}
+
+	// The original is untouched and the temp file is gone
+	data, _ := os.ReadFile(path)
+	if string(data) != "original\n" {
+		t.Errorf("contents = %q, want %q", data, "original\n")
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(entries))
+	}
+}
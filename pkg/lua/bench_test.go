@@ -0,0 +1,98 @@
+package lua
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkSizes are the approximate sizes of the generated files
+var benchmarkSizes = []int{1 << 20, 16 << 20}
+
+// largeSavedVariables generates a SavedVariables file of about size bytes:
+// a small AddonProfilesDB followed by a large table of another addon, the
+// way addons like WeakAuras share a file's worth of data
+func largeSavedVariables(size int) []byte {
+	var b strings.Builder
+	b.WriteString("AddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"profiles\"] = {\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&b, "\t\t\t[\"Profile %d\"] = {\n\t\t\t\t[\"addons\"] = {\n", i)
+		for j := 0; j < 40; j++ {
+			fmt.Fprintf(&b, "\t\t\t\t\t[\"Addon%d\"] = %v,\n", j, (i+j)%3 != 0)
+		}
+		fmt.Fprintf(&b, "\t\t\t\t},\n\t\t\t\t[\"autoDeps\"] = true,\n\t\t\t\t[\"created\"] = %d,\n\t\t\t\t[\"scope\"] = \"account\",\n\t\t\t},\n", 1698765432+i)
+	}
+	b.WriteString("\t\t},\n\t},\n}\n")
+
+	b.WriteString("WeakAurasSaved = {\n\t[\"displays\"] = {\n")
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "\t\t[\"Aura %d\"] = {\n\t\t\t[\"id\"] = \"Aura %d\",\n\t\t\t[\"alpha\"] = 0.85,\n\t\t\t[\"desc\"] = \"Tracks \\\"%d\\\" stacks\\n\",\n\t\t\t[\"spells\"] = {\n\t\t\t\t%d, -- [1]\n\t\t\t\t%d, -- [2]\n\t\t\t},\n\t\t\t[\"load\"] = {\n\t\t\t\t[\"use_combat\"] = true,\n\t\t\t},\n\t\t},\n", i, i, i, 47528+i, 183752+i)
+	}
+	b.WriteString("\t},\n}\n")
+
+	return []byte(b.String())
+}
+
+func BenchmarkParseSimpleReader(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		content := largeSavedVariables(size)
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseSimpleReader(bytes.NewReader(content)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseGlobal(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		content := largeSavedVariables(size)
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, ok, err := ParseGlobal(bytes.NewReader(content), "AddonProfilesDB"); err != nil || !ok {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseChunk(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		content := string(largeSavedVariables(size))
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseChunk(content); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDocumentSet(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		doc, err := ParseDocument(largeSavedVariables(size))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				path := NewPath("AddonProfilesDB", "global", "profiles", fmt.Sprintf("Profile %d", i%50), "addons", "Addon0")
+				if err := doc.Set(path, i%2 == 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
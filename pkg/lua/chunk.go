@@ -0,0 +1,97 @@
+package lua
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ParseChunk parses a SavedVariables file, which WoW writes as a series of
+// `Name = value` assignments, and returns the value of every global. When a
+// name is assigned twice the last value wins, as it would in the game.
+func ParseChunk(content string) (map[string]Value, error) {
+	return parseChunk(newStringLexer(content, Limits{}), nil)
+}
+
+// ParseChunkReader parses a SavedVariables file read from r like
+// ParseChunk, without holding all of it in memory
+func ParseChunkReader(r io.Reader) (map[string]Value, error) {
+	return parseChunk(newLexer(r, Limits{}), nil)
+}
+
+// ParseChunkFile parses a SavedVariables file with ParseChunk
+func ParseChunkFile(filepath string) (map[string]Value, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseChunkReader(file)
+}
+
+// ParseGlobal parses a SavedVariables file read from r and returns the
+// value of the global name, or false if it isn't assigned. The values of
+// other globals are skipped without being built, which makes this much
+// cheaper than ParseChunk on files shared with large addon data.
+func ParseGlobal(r io.Reader, name string) (Value, bool, error) {
+	return parseGlobal(newLexer(r, Limits{}), name)
+}
+
+func parseGlobal(l *lexer, name string) (Value, bool, error) {
+	globals, err := parseChunk(l, func(global string) bool {
+		return global == name
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := globals[name]
+	return value, ok, nil
+}
+
+// parseChunk parses the assignments of a file. If want is set, only the
+// globals it accepts are parsed and the others are skipped.
+func parseChunk(l *lexer, want func(name string) bool) (map[string]Value, error) {
+	globals, err := newParser(l).parseAssignments(want)
+
+	// A failed read looks like the end of the input to the parser
+	if readErr := l.readError(); readErr != nil {
+		return nil, readErr
+	}
+	return globals, err
+}
+
+func (p *parser) parseAssignments(want func(name string) bool) (map[string]Value, error) {
+	globals := make(map[string]Value)
+
+	for p.peek().typ != tokenEOF {
+		if p.peek().typ == tokenSemicolon {
+			p.next()
+			continue
+		}
+
+		name, err := p.expect(tokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenEquals); err != nil {
+			return nil, err
+		}
+
+		if want != nil && !want(name.value) {
+			if err := p.skipValue(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		globals[name.value] = value
+	}
+
+	return globals, nil
+}
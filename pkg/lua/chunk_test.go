@@ -0,0 +1,210 @@
+package lua
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseChunk(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "several globals",
+			input: `
+AddonProfilesDB = {
+	["global"] = {
+	},
+}
+AddonProfilesDBVersion = 3
+DetailsTimeLine = nil
+`,
+			want: map[string]interface{}{
+				"AddonProfilesDB":        map[string]interface{}{"global": map[string]interface{}{}},
+				"AddonProfilesDBVersion": int64(3),
+				"DetailsTimeLine":        nil,
+			},
+		},
+		{
+			name:  "last assignment wins",
+			input: "A = 1; A = 2",
+			want:  map[string]interface{}{"A": int64(2)},
+		},
+		{name: "empty file", input: "-- nothing saved\n", want: map[string]interface{}{}},
+		{name: "missing value", input: "A =", wantErr: true},
+		{name: "statement", input: "print(1)", wantErr: true},
+		{name: "bad value", input: "A = 1\nB = {", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globals, err := ParseChunk(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChunk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(globals) != len(tt.want) {
+				t.Errorf("ParseChunk() returned %d globals, want %d", len(globals), len(tt.want))
+			}
+			for name, want := range tt.want {
+				value, ok := globals[name]
+				if !ok {
+					t.Errorf("global %s missing", name)
+					continue
+				}
+				if got := toInterface(value); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChunkFile(t *testing.T) {
+	globals, err := ParseChunkFile(filepath.Join("testdata", "wow_format.lua"))
+	if err != nil {
+		t.Fatalf("ParseChunkFile() error = %v", err)
+	}
+
+	saved, ok := globals["WeakAurasSaved"].(*Table)
+	if !ok {
+		t.Fatalf("WeakAurasSaved = %v, want a table", globals["WeakAurasSaved"])
+	}
+	if saved.Field("dbVersion") != Number(76) {
+		t.Errorf("dbVersion = %v, want %v", saved.Field("dbVersion"), 76)
+	}
+
+	if _, err := ParseChunkFile(filepath.Join("testdata", "does_not_exist.lua")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestParseSimpleGlobals(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "other globals first", input: "OtherDB = { 1 }\nAddonProfilesDB = {\n}\n"},
+		{name: "nil", input: "AddonProfilesDB = nil\n"},
+		{name: "missing", input: "OtherDB = {}\n", wantErr: true},
+		{name: "not a table", input: "AddonProfilesDB = 1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := ParseSimple(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSimple() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (db.Global.Profiles == nil || db.Char == nil) {
+				t.Errorf("ParseSimple() = %+v, want empty maps", db)
+			}
+		})
+	}
+}
+
+func TestParseChunkReader(t *testing.T) {
+	for _, file := range []string{"valid_profile.lua", "wow_format.lua"} {
+		t.Run(file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			want, err := ParseChunk(string(content))
+			if err != nil {
+				t.Fatalf("ParseChunk() error = %v", err)
+			}
+
+			// Reading a byte at a time splits every token across reads
+			got, err := ParseChunkReader(iotest.OneByteReader(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("ParseChunkReader() error = %v", err)
+			}
+
+			for name, value := range want {
+				if !reflect.DeepEqual(toInterface(got[name]), toInterface(value)) {
+					t.Errorf("%s = %v, want %v", name, got[name], value)
+				}
+			}
+			if len(got) != len(want) {
+				t.Errorf("ParseChunkReader() returned %d globals, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestParseChunkReaderErrors(t *testing.T) {
+	// The error comes long after the first read, when the start of the
+	// input has been dropped
+	var b strings.Builder
+	b.WriteString("Big = {\n")
+	for b.Len() < 4*readSize {
+		b.WriteString("\t\"some saved value\", -- [n]\n")
+	}
+	b.WriteString("}\nAddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"x\"] 1,\n\t},\n}\n")
+	lines := strings.Count(b.String(), "\n")
+
+	_, err := ParseChunkReader(iotest.HalfReader(strings.NewReader(b.String())))
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("ParseChunkReader() error = %v, want *ParseError", err)
+	}
+	if parseErr.Line != lines-2 || parseErr.Column != 9 {
+		t.Errorf("error at %d:%d, want %d:9", parseErr.Line, parseErr.Column, lines-2)
+	}
+	if want := "\t\t[\"x\"] 1,\n\t\t      ^"; parseErr.Excerpt != want {
+		t.Errorf("Excerpt = %q, want %q", parseErr.Excerpt, want)
+	}
+
+	_, err = ParseChunkReader(iotest.TimeoutReader(strings.NewReader(b.String())))
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("ParseChunkReader() error = %v, want %v", err, iotest.ErrTimeout)
+	}
+}
+
+func TestParseGlobal(t *testing.T) {
+	input := `
+Skipped = {
+	["with \"quotes\" and { braces }"] = [[ } ]],
+	nested = { { 1, 2 }, -- }
+	},
+	bad = "\q", [1..2] = true, escaped = "a\z
+		b",
+}
+AddonProfilesDB = { ["global"] = { ["activeProfile"] = "Raid" } }
+Unbalanced = {
+`
+
+	value, ok, err := ParseGlobal(strings.NewReader(input), "AddonProfilesDB")
+	if err == nil {
+		t.Fatal("ParseGlobal() error = nil, want error for unbalanced table")
+	}
+
+	input = strings.TrimSuffix(input, "Unbalanced = {\n")
+	value, ok, err = ParseGlobal(strings.NewReader(input), "AddonProfilesDB")
+	if err != nil || !ok {
+		t.Fatalf("ParseGlobal() = %v, %v, %v", value, ok, err)
+	}
+	global, _ := value.(*Table).Field("global").(*Table)
+	if global == nil || global.Field("activeProfile") != String("Raid") {
+		t.Errorf("AddonProfilesDB = %v, want activeProfile Raid", value)
+	}
+
+	if _, ok, err := ParseGlobal(strings.NewReader(input), "Missing"); ok || err != nil {
+		t.Errorf("ParseGlobal(Missing) = %v, %v; want not found", ok, err)
+	}
+}
@@ -0,0 +1,138 @@
+package lua
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Disagreement is a value the simple and the regex parser read differently
+type Disagreement struct {
+	// Path is where the value is, e.g. global.profiles.Raid.autoDeps
+	Path string
+	// Simple and Regex are the values each parser read, "missing" if it
+	// found none
+	Simple string
+	Regex  string
+}
+
+func (d Disagreement) String() string {
+	return fmt.Sprintf("%s: simple parser read %s, regex parser read %s", d.Path, d.Simple, d.Regex)
+}
+
+const missing = "missing"
+
+// compareDatabases lists where two parses of a file differ. Only enabled
+// addons are compared, as the regex parser leaves out disabled ones.
+func compareDatabases(simple, regex *Database) []Disagreement {
+	c := &comparison{}
+
+	c.value(NewPath("global", "activeProfile"), simple.Global.ActiveProfile, regex.Global.ActiveProfile)
+	c.profiles(NewPath("global", "profiles"), simple.Global.Profiles, regex.Global.Profiles)
+
+	for _, key := range unionKeys(simple.Char, regex.Char) {
+		path := NewPath("char", key)
+		simpleChar, inSimple := simple.Char[key]
+		regexChar, inRegex := regex.Char[key]
+		if !inSimple || !inRegex {
+			c.presence(path, inSimple, inRegex)
+			continue
+		}
+
+		c.value(join(path, "activeProfile"), simpleChar.ActiveProfile, regexChar.ActiveProfile)
+		c.profiles(join(path, "profiles"), simpleChar.Profiles, regexChar.Profiles)
+	}
+
+	return c.found
+}
+
+type comparison struct {
+	found []Disagreement
+}
+
+func (c *comparison) add(path Path, simple, regex string) {
+	c.found = append(c.found, Disagreement{Path: path.String(), Simple: simple, Regex: regex})
+}
+
+// value compares a string value, where "" means missing
+func (c *comparison) value(path Path, simple, regex string) {
+	if simple == regex {
+		return
+	}
+	quote := func(s string) string {
+		if s == "" {
+			return missing
+		}
+		return strconv.Quote(s)
+	}
+	c.add(path, quote(simple), quote(regex))
+}
+
+// presence records an entry only one parser found
+func (c *comparison) presence(path Path, inSimple, inRegex bool) {
+	present := func(found bool) string {
+		if found {
+			return "present"
+		}
+		return missing
+	}
+	c.add(path, present(inSimple), present(inRegex))
+}
+
+func (c *comparison) profiles(path Path, simple, regex map[string]*Profile) {
+	for _, name := range unionKeys(simple, regex) {
+		profilePath := join(path, name)
+		a, inSimple := simple[name]
+		b, inRegex := regex[name]
+		if !inSimple || !inRegex {
+			c.presence(profilePath, inSimple, inRegex)
+			continue
+		}
+
+		enabled := func(addons map[string]bool) map[string]bool {
+			result := make(map[string]bool)
+			for addon, on := range addons {
+				if on {
+					result[addon] = true
+				}
+			}
+			return result
+		}
+		simpleAddons, regexAddons := enabled(a.Addons), enabled(b.Addons)
+		for _, addon := range unionKeys(simpleAddons, regexAddons) {
+			if simpleAddons[addon] != regexAddons[addon] {
+				c.add(join(profilePath, "addons", addon),
+					strconv.FormatBool(simpleAddons[addon]), strconv.FormatBool(regexAddons[addon]))
+			}
+		}
+
+		if a.AutoDeps != b.AutoDeps {
+			c.add(join(profilePath, "autoDeps"),
+				strconv.FormatBool(a.AutoDeps), strconv.FormatBool(b.AutoDeps))
+		}
+		if a.Created != b.Created {
+			c.add(join(profilePath, "created"),
+				strconv.FormatInt(a.Created, 10), strconv.FormatInt(b.Created, 10))
+		}
+	}
+}
+
+// join returns path with keys appended, leaving path as it is
+func join(path Path, keys ...string) Path {
+	return append(append(Path{}, path...), NewPath(keys...)...)
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,434 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Unmarshaler is implemented by types that decode themselves from a Lua
+// value, e.g. to set defaults for missing keys
+type Unmarshaler interface {
+	UnmarshalLua(v Value) error
+}
+
+// UnmarshalTypeError describes a Lua value that doesn't fit the Go type it
+// is decoded into
+type UnmarshalTypeError struct {
+	Value Kind         // Lua type of the value
+	Type  reflect.Type // Go type it could not be stored in
+	Path  string       // where the value was found, e.g. global.profiles.Raid
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("cannot unmarshal Lua %v into Go value of type %v", e.Value, e.Type)
+	}
+	return fmt.Sprintf("cannot unmarshal Lua %v into Go value of type %v at %s", e.Value, e.Type, e.Path)
+}
+
+// Unmarshal parses a single Lua value, such as the table assigned in a
+// SavedVariables file, and stores it in the value pointed to by v.
+//
+// Tables decode into structs, maps and slices, and other values into the
+// matching Go types; see UnmarshalValue for the rules.
+func Unmarshal(data []byte, v interface{}) error {
+	value, err := ParseValue(string(data))
+	if err != nil {
+		return err
+	}
+	return UnmarshalValue(value, v)
+}
+
+// UnmarshalValue stores a parsed Lua value in the value pointed to by v:
+//
+//   - structs are filled from string keys, matched against the field's
+//     `lua:"key"` tag or else its name, ignoring case; `lua:"-"` skips a
+//     field and options like omitempty only affect encoding. A map field
+//     tagged `lua:",remain"` takes the string keys no other field matches,
+//     so fields the Go type doesn't know about are kept.
+//   - maps take every entry; array entries have the keys 1, 2, ... which
+//     map to "1", "2", ... for string keys
+//   - slices and arrays take the array part and integer keys
+//   - a Value field keeps the value as is, interface{} gets plain Go values
+//   - nil sets pointers, maps, slices and interfaces to nil
+//
+// Values that don't fit are skipped and the first is returned as an
+// *UnmarshalTypeError once everything else is decoded.
+func UnmarshalValue(value Value, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("UnmarshalValue requires a non-nil pointer, got %T", v)
+	}
+
+	d := &decoder{}
+	d.decode(value, rv.Elem())
+	return d.err
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	valueType       = reflect.TypeOf((*Value)(nil)).Elem()
+)
+
+type decoder struct {
+	path []string
+	err  error
+}
+
+// saveError keeps the first error
+func (d *decoder) saveError(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// typeError records that value doesn't fit rv
+func (d *decoder) typeError(value Value, rv reflect.Value) {
+	d.saveError(&UnmarshalTypeError{
+		Value: value.Kind(),
+		Type:  rv.Type(),
+		Path:  strings.Join(d.path, "."),
+	})
+}
+
+// decode stores value in rv and reports whether it fit. Mismatches further
+// down, e.g. in a field of a struct, are recorded but don't count.
+func (d *decoder) decode(value Value, rv reflect.Value) bool {
+	if value == nil {
+		value = Nil{}
+	}
+
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() && rv.Addr().Type().Implements(unmarshalerType) {
+		return d.unmarshaler(value, rv.Addr().Interface().(Unmarshaler))
+	}
+
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(&value).Elem())
+		return true
+	}
+
+	if _, ok := value.(Nil); ok {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return true
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(value, rv.Elem())
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			d.typeError(value, rv)
+			return false
+		}
+		if plain := toInterface(value); plain != nil {
+			rv.Set(reflect.ValueOf(plain))
+		}
+	case reflect.Bool:
+		b, ok := value.(Bool)
+		if !ok {
+			d.typeError(value, rv)
+			return false
+		}
+		rv.SetBool(bool(b))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(Number)
+		if !ok {
+			d.typeError(value, rv)
+			return false
+		}
+		i, ok := n.Int()
+		if !ok || rv.OverflowInt(i) {
+			d.typeError(value, rv)
+			return false
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(Number)
+		if !ok {
+			d.typeError(value, rv)
+			return false
+		}
+		i, ok := n.Int()
+		if !ok || i < 0 || rv.OverflowUint(uint64(i)) {
+			d.typeError(value, rv)
+			return false
+		}
+		rv.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(Number)
+		if !ok {
+			d.typeError(value, rv)
+			return false
+		}
+		rv.SetFloat(float64(n))
+	case reflect.String:
+		s, ok := value.(String)
+		if !ok {
+			d.typeError(value, rv)
+			return false
+		}
+		rv.SetString(string(s))
+	case reflect.Struct:
+		return d.decodeStruct(value, rv)
+	case reflect.Map:
+		return d.decodeMap(value, rv)
+	case reflect.Slice, reflect.Array:
+		return d.decodeSlice(value, rv)
+	default:
+		d.typeError(value, rv)
+		return false
+	}
+	return true
+}
+
+// unmarshaler lets u decode value. A type error for value itself means it
+// didn't fit; type errors below it are recorded with the full path.
+func (d *decoder) unmarshaler(value Value, u Unmarshaler) bool {
+	err := u.UnmarshalLua(value)
+	if err == nil {
+		return true
+	}
+
+	typeErr, ok := err.(*UnmarshalTypeError)
+	if !ok {
+		d.saveError(err)
+		return false
+	}
+
+	path := append([]string{}, d.path...)
+	if typeErr.Path != "" {
+		path = append(path, typeErr.Path)
+	}
+	nested := *typeErr
+	nested.Path = strings.Join(path, ".")
+	d.saveError(&nested)
+	return typeErr.Path != ""
+}
+
+func (d *decoder) decodeStruct(value Value, rv reflect.Value) bool {
+	table, ok := value.(*Table)
+	if !ok {
+		d.typeError(value, rv)
+		return false
+	}
+
+	fields := structFields(rv.Type())
+	remain, hasRemain := remainField(fields)
+	for _, field := range table.Fields() {
+		key, ok := field.Key.(String)
+		if !ok {
+			continue
+		}
+
+		d.path = append(d.path, string(key))
+		if f, ok := findField(fields, string(key)); ok {
+			d.decode(field.Value, rv.Field(f.index))
+		} else if hasRemain {
+			d.decodeRemain(key, field.Value, rv.Field(remain.index))
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+	return true
+}
+
+// decodeRemain stores an entry no field matched in the remain map
+func (d *decoder) decodeRemain(key String, value Value, rv reflect.Value) {
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		d.saveError(fmt.Errorf("lua: remain field must be a map with string keys, not %v", rv.Type()))
+		return
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	elem := reflect.New(rv.Type().Elem()).Elem()
+	if d.decode(value, elem) {
+		rv.SetMapIndex(reflect.ValueOf(string(key)).Convert(rv.Type().Key()), elem)
+	}
+}
+
+func (d *decoder) decodeMap(value Value, rv reflect.Value) bool {
+	table, ok := value.(*Table)
+	if !ok {
+		d.typeError(value, rv)
+		return false
+	}
+
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	keyType := rv.Type().Key()
+	elemType := rv.Type().Elem()
+
+	set := func(key, item Value) {
+		k := reflect.New(keyType).Elem()
+		if !decodeKey(key, k) {
+			d.path = append(d.path, keyString(key))
+			d.typeError(key, k)
+			d.path = d.path[:len(d.path)-1]
+			return
+		}
+
+		// Entries that don't fit are left out rather than stored as zero
+		elem := reflect.New(elemType).Elem()
+		d.path = append(d.path, keyString(key))
+		ok := d.decode(item, elem)
+		d.path = d.path[:len(d.path)-1]
+		if ok {
+			rv.SetMapIndex(k, elem)
+		}
+	}
+
+	for i, item := range table.Array {
+		set(Number(i+1), item)
+	}
+	for _, field := range table.Fields() {
+		set(field.Key, field.Value)
+	}
+	return true
+}
+
+// decodeKey converts a table key to a Go map key
+func decodeKey(key Value, k reflect.Value) bool {
+	switch k.Kind() {
+	case reflect.String:
+		if key.Kind() == KindTable || key.Kind() == KindNil {
+			return false
+		}
+		k.SetString(keyString(key))
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := key.(Number)
+		if !ok {
+			return false
+		}
+		i, ok := n.Int()
+		if !ok || k.OverflowInt(i) {
+			return false
+		}
+		k.SetInt(i)
+		return true
+	case reflect.Bool:
+		b, ok := key.(Bool)
+		if !ok {
+			return false
+		}
+		k.SetBool(bool(b))
+		return true
+	default:
+		d := &decoder{}
+		d.decode(key, k)
+		return d.err == nil
+	}
+}
+
+func (d *decoder) decodeSlice(value Value, rv reflect.Value) bool {
+	table, ok := value.(*Table)
+	if !ok {
+		d.typeError(value, rv)
+		return false
+	}
+
+	// Sequences may also be written with explicit keys, [1] = ..., [2] = ...
+	items := append([]Value{}, table.Array...)
+	for _, field := range table.Fields() {
+		n, ok := field.Key.(Number)
+		if !ok {
+			continue
+		}
+		i, ok := n.Int()
+		if !ok || i < 1 || i > int64(len(table.Array)+len(table.Fields())) {
+			continue
+		}
+		for int64(len(items)) < i {
+			items = append(items, Nil{})
+		}
+		items[i-1] = field.Value
+	}
+
+	if rv.Kind() == reflect.Slice {
+		rv.Set(reflect.MakeSlice(rv.Type(), len(items), len(items)))
+	} else if len(items) > rv.Len() {
+		d.typeError(value, rv)
+		items = items[:rv.Len()]
+	}
+
+	for i, item := range items {
+		d.path = append(d.path, strconv.Itoa(i+1))
+		d.decode(item, rv.Index(i))
+		d.path = d.path[:len(d.path)-1]
+	}
+	return true
+}
+
+// fieldInfo describes a struct field that can be decoded
+type fieldInfo struct {
+	name      string
+	index     int
+	omitEmpty bool
+	remain    bool
+}
+
+// structFields returns the exported fields of a struct type with their Lua
+// key names
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get("lua")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, fieldInfo{
+			name:      name,
+			index:     i,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			remain:    strings.Contains(","+opts+",", ",remain,"),
+		})
+	}
+	return fields
+}
+
+// findField finds the field for a key, preferring an exact match
+func findField(fields []fieldInfo, key string) (fieldInfo, bool) {
+	for _, f := range fields {
+		if !f.remain && f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if !f.remain && strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+// remainField returns the field tagged remain, if the struct has one
+func remainField(fields []fieldInfo) (fieldInfo, bool) {
+	for _, f := range fields {
+		if f.remain {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
@@ -0,0 +1,198 @@
+package lua
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAura struct {
+	ID       int               `lua:"id"`
+	Name     string            `lua:"name"`
+	Scale    float64           `lua:"scale,omitempty"`
+	Enabled  bool              `lua:"enabled"`
+	Tags     []string          `lua:"tags"`
+	Loads    map[int]bool      `lua:"load"`
+	Parent   *testAura         `lua:"parent"`
+	Extra    Value             `lua:"extra"`
+	Raw      interface{}       `lua:"raw"`
+	Options  map[string]string `lua:"options"`
+	Skipped  string            `lua:"-"`
+	Color    [3]float32        `lua:"color"`
+	Untagged string
+}
+
+func TestUnmarshal(t *testing.T) {
+	input := `{
+		id = 7,
+		name = "Interrupts",
+		scale = 0.5,
+		enabled = true,
+		tags = { "pvp", "raid" },
+		load = { [1] = true, [3] = false },
+		parent = { id = 1, name = "Group" },
+		extra = { 1, 2 },
+		raw = { a = 1 },
+		options = { sound = "ding" },
+		["-"] = "ignored",
+		Skipped = "ignored",
+		color = { 1, 0.5, 0 },
+		untagged = "matched ignoring case",
+		unknown = { deeply = { nested = true } },
+	}`
+
+	var aura testAura
+	if err := Unmarshal([]byte(input), &aura); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := testAura{
+		ID:       7,
+		Name:     "Interrupts",
+		Scale:    0.5,
+		Enabled:  true,
+		Tags:     []string{"pvp", "raid"},
+		Loads:    map[int]bool{1: true, 3: false},
+		Parent:   &testAura{ID: 1, Name: "Group"},
+		Raw:      map[string]interface{}{"a": int64(1)},
+		Options:  map[string]string{"sound": "ding"},
+		Color:    [3]float32{1, 0.5, 0},
+		Untagged: "matched ignoring case",
+	}
+
+	extra := aura.Extra
+	aura.Extra = nil
+	if !reflect.DeepEqual(aura, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", aura, want)
+	}
+
+	table, ok := extra.(*Table)
+	if !ok || table.Len() != 2 {
+		t.Errorf("Extra = %v, want the table kept as a Value", extra)
+	}
+}
+
+func TestUnmarshalRemain(t *testing.T) {
+	type profile struct {
+		Name  string           `lua:"name"`
+		Extra map[string]Value `lua:",remain"`
+	}
+
+	input := `{
+	["color"] = "red",
+	["name"] = "Raid",
+	["sort"] = {
+		["by"] = "name",
+	},
+	[1] = "dropped",
+}`
+
+	var p profile
+	if err := Unmarshal([]byte(input), &p); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if p.Name != "Raid" {
+		t.Errorf("Name = %v, want Raid", p.Name)
+	}
+	if len(p.Extra) != 2 || p.Extra["color"] != String("red") {
+		t.Errorf("Extra = %v, want color and sort", p.Extra)
+	}
+
+	// Kept fields are written back in key order with the known ones
+	data, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := strings.Replace(input, "\t[1] = \"dropped\",\n", "", 1)
+	if string(data) != want {
+		t.Errorf("Marshal() = %q, want %q", data, want)
+	}
+
+	// A field wins over a kept entry with its key
+	p.Extra["name"] = String("stale")
+	data, _ = Marshal(p)
+	if !strings.Contains(string(data), `["name"] = "Raid"`) || strings.Contains(string(data), "stale") {
+		t.Errorf("Marshal() = %s, want the Name field written", data)
+	}
+}
+
+func TestUnmarshalSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "array part", input: `{ "a", "b" }`, want: []string{"a", "b"}},
+		{name: "explicit keys", input: `{ [1] = "a", [2] = "b" }`, want: []string{"a", "b"}},
+		{name: "mixed", input: `{ "a", [2] = "b", [3] = "c" }`, want: []string{"a", "b", "c"}},
+		{name: "empty", input: `{}`, want: []string{}},
+		{name: "nil", input: `nil`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{"old"}
+			if err := Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	var db Database
+	err := Unmarshal([]byte(`{
+		global = {
+			activeProfile = 42,
+			profiles = {
+				Good = { addons = { Ace3 = true, Bad = "yes" } },
+				Broken = "not a table",
+			},
+		},
+	}`), &db)
+
+	typeErr, ok := err.(*UnmarshalTypeError)
+	if !ok {
+		t.Fatalf("Unmarshal() error = %v, want *UnmarshalTypeError", err)
+	}
+	if typeErr.Path != "global.activeProfile" || typeErr.Value != KindNumber {
+		t.Errorf("error = %v, want number at global.activeProfile", typeErr)
+	}
+
+	// Everything that fits is still decoded
+	good := db.Global.Profiles["Good"]
+	if good == nil || !good.Addons["Ace3"] || len(good.Addons) != 1 {
+		t.Errorf("Good = %+v, want only Ace3", good)
+	}
+	if !good.AutoDeps {
+		t.Error("AutoDeps = false, want true when missing")
+	}
+	if _, ok := db.Global.Profiles["Broken"]; ok {
+		t.Error("Broken profile decoded from a string")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		v     interface{}
+	}{
+		{name: "fraction into int", input: "1.5", v: new(int)},
+		{name: "overflow", input: "300", v: new(int8)},
+		{name: "negative into uint", input: "-1", v: new(uint)},
+		{name: "string into bool", input: `"true"`, v: new(bool)},
+		{name: "table into string", input: "{}", v: new(string)},
+		{name: "too many elements", input: "{ 1, 2, 3 }", v: new([2]int)},
+	}
+	for _, tt := range tests {
+		if _, ok := Unmarshal([]byte(tt.input), tt.v).(*UnmarshalTypeError); !ok {
+			t.Errorf("%s: expected *UnmarshalTypeError", tt.name)
+		}
+	}
+
+	if err := Unmarshal([]byte("1"), 0); err == nil {
+		t.Error("Expected error for non-pointer")
+	}
+}
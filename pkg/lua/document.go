@@ -0,0 +1,594 @@
+package lua
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Document is a SavedVariables file parsed for editing. Edits only rewrite
+// the values they touch; everything else, including comments, formatting,
+// key order and keys this package doesn't know, is kept byte for byte.
+type Document struct {
+	src     string
+	globals []*globalNode
+}
+
+// globalNode is a top-level `Name = value` assignment
+type globalNode struct {
+	name  string
+	start int // offset of the name
+	value valueNode
+}
+
+// valueNode is the source span of a value
+type valueNode struct {
+	start, end int
+	table      *tableNode // set for table constructors
+}
+
+// tableNode holds the fields of a table constructor
+type tableNode struct {
+	close  int // offset of the closing brace
+	fields []*fieldNode
+}
+
+// fieldNode is one entry of a table constructor
+type fieldNode struct {
+	key        Value
+	positional bool
+	start, end int // the entry without its separator
+	sepEnd     int // end including the separator, == end without one
+	value      valueNode
+}
+
+// ParseDocument parses a SavedVariables file made of `Name = value`
+// assignments
+func ParseDocument(src []byte) (*Document, error) {
+	return parseDocument(string(src), Limits{})
+}
+
+func parseDocument(src string, limits Limits) (*Document, error) {
+	doc := &Document{src: src}
+	l := newStringLexer(doc.src, limits)
+	if err := l.readError(); err != nil {
+		return nil, err
+	}
+	p := newParser(l)
+
+	for p.peek().typ != tokenEOF {
+		if p.peek().typ == tokenSemicolon {
+			p.next()
+			continue
+		}
+
+		name, err := p.expect(tokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenEquals); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+
+		doc.globals = append(doc.globals, &globalNode{name: name.value, start: name.offset, value: value})
+	}
+
+	return doc, nil
+}
+
+// parseNode parses a value and records where it and its fields are
+func (p *parser) parseNode() (valueNode, error) {
+	start := p.peek()
+	if start.typ != tokenLBrace {
+		if _, err := p.parseValue(); err != nil {
+			return valueNode{}, err
+		}
+		return valueNode{start: start.offset, end: p.lastEnd()}, nil
+	}
+
+	open := p.next() // consume {
+	if err := p.enter(open); err != nil {
+		return valueNode{}, err
+	}
+	defer p.leave()
+	table := &tableNode{}
+	positional := 0
+
+	for p.peek().typ != tokenRBrace {
+		field := &fieldNode{start: p.peek().offset}
+
+		switch {
+		case p.peek().typ == tokenLBracket:
+			p.next() // consume [
+			keyTok := p.peek()
+			key, err := p.parseValue()
+			if err != nil {
+				return valueNode{}, err
+			}
+			if key.Kind() == KindNil || key.Kind() == KindTable {
+				return valueNode{}, p.errorAt(keyTok, fmt.Sprintf("invalid table key of type %v", key.Kind()))
+			}
+			if _, err := p.expect(tokenRBracket); err != nil {
+				return valueNode{}, err
+			}
+			if _, err := p.expect(tokenEquals); err != nil {
+				return valueNode{}, err
+			}
+			field.key = key
+		case p.peek().typ == tokenIdent && p.peekAt(1).typ == tokenEquals:
+			field.key = String(p.next().value)
+			p.next() // consume =
+		default:
+			positional++
+			field.key = Number(positional)
+			field.positional = true
+		}
+
+		value, err := p.parseNode()
+		if err != nil {
+			return valueNode{}, err
+		}
+		field.value = value
+		field.end = value.end
+		field.sepEnd = value.end
+
+		if sep := p.peek(); sep.typ == tokenComma || sep.typ == tokenSemicolon {
+			p.next()
+			field.sepEnd = sep.end
+		} else if sep.typ == tokenIllegal {
+			return valueNode{}, p.illegal(sep)
+		} else if sep.typ != tokenRBrace {
+			return valueNode{}, p.unexpected(sep, "',' or '}'")
+		}
+
+		table.fields = append(table.fields, field)
+	}
+
+	closing, err := p.expect(tokenRBrace)
+	if err != nil {
+		return valueNode{}, err
+	}
+	table.close = closing.offset
+
+	return valueNode{start: start.offset, end: closing.end, table: table}, nil
+}
+
+// lastEnd returns the end of the last consumed token
+func (p *parser) lastEnd() int {
+	return p.last.end
+}
+
+// Bytes returns the document's source with all edits applied
+func (d *Document) Bytes() []byte {
+	return []byte(d.src)
+}
+
+// Globals returns the names of the top-level assignments in file order
+func (d *Document) Globals() []string {
+	names := make([]string, len(d.globals))
+	for i, g := range d.globals {
+		names[i] = g.name
+	}
+	return names
+}
+
+// Get returns the value at path, or false if there is none
+func (d *Document) Get(path Path) (Value, bool) {
+	node, _, ok := d.lookup(path)
+	if !ok {
+		return nil, false
+	}
+
+	value, err := ParseValue(d.src[node.start:node.end])
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// lookup finds the node at path. If it doesn't exist, it returns the
+// deepest table on the path and the number of keys that were found.
+func (d *Document) lookup(path Path) (*valueNode, int, bool) {
+	if len(path) == 0 {
+		return nil, 0, false
+	}
+
+	global := d.global(path[0])
+	if global == nil {
+		return nil, 0, false
+	}
+
+	node := &global.value
+	for i, key := range path[1:] {
+		if node.table == nil {
+			return node, i + 1, false
+		}
+		field := node.table.field(key)
+		if field == nil {
+			return node, i + 1, false
+		}
+		node = &field.value
+	}
+	return node, len(path), true
+}
+
+func (d *Document) global(name Value) *globalNode {
+	s, ok := name.(String)
+	if !ok {
+		return nil
+	}
+
+	// The last assignment wins, as when the file is run
+	for i := len(d.globals) - 1; i >= 0; i-- {
+		if d.globals[i].name == string(s) {
+			return d.globals[i]
+		}
+	}
+	return nil
+}
+
+// field returns the entry for key; a later entry for the same key wins
+func (t *tableNode) field(key Value) *fieldNode {
+	for i := len(t.fields) - 1; i >= 0; i-- {
+		if t.fields[i].key == key {
+			return t.fields[i]
+		}
+	}
+	return nil
+}
+
+// Set stores v at path, encoded like Marshal does. Missing tables along the
+// path are created; a nil v deletes the entry.
+func (d *Document) Set(path Path, v interface{}) error {
+	value, err := ToValue(v)
+	if err != nil {
+		return err
+	}
+	if value.Kind() == KindNil {
+		return d.Delete(path)
+	}
+
+	if len(path) == 0 {
+		return fmt.Errorf("empty path")
+	}
+	if _, ok := path[0].(String); !ok || !isName(string(path[0].(String))) {
+		return fmt.Errorf("invalid global name in path %v", path)
+	}
+
+	node, found, ok := d.lookup(path)
+	if ok {
+		// Replace the value, indented like the line it starts on
+		text, err := encodeAt(value, d.lineDepth(node.start))
+		if err != nil {
+			return err
+		}
+		replaced, err := parseNodeAt(text, node.start)
+		if err != nil {
+			return err
+		}
+		d.apply(edit{node.start, node.end, text})
+		*node = replaced
+		return nil
+	}
+	if found > 0 && node.table == nil {
+		return fmt.Errorf("cannot set %v: %v is not a table", path, path[:found])
+	}
+
+	// Nest the value in new tables for the keys that are missing
+	for i := len(path) - 1; i > found; i-- {
+		table := NewTable()
+		table.Set(path[i], value)
+		value = table
+	}
+
+	if found > 0 {
+		return d.insertField(node, path[found], value)
+	}
+
+	// A new global goes at the end, as WoW writes them
+	text, err := encodeAt(value, 0)
+	if err != nil {
+		return err
+	}
+	prefix := "\n"
+	if d.src != "" && !strings.HasSuffix(d.src, "\n") {
+		prefix = "\n\n"
+	}
+	text = fmt.Sprintf("%s%s = %s\n", prefix, path[0].(String), text)
+
+	added, err := parseDocument(text, Limits{})
+	if err != nil {
+		return fmt.Errorf("edit produced invalid Lua: %w", err)
+	}
+	offset := len(d.src)
+	d.apply(edit{offset, offset, text})
+	for _, global := range added.globals {
+		global.eachOffset(-1, func(o *int, _ bool) { *o += offset })
+		d.globals = append(d.globals, global)
+	}
+	return nil
+}
+
+// insertField adds key = value at the end of a table
+func (d *Document) insertField(node *valueNode, key, value Value) error {
+	table := node.table
+
+	if key.Kind() == KindNil || key.Kind() == KindTable {
+		return fmt.Errorf("invalid table key of type %v", key.Kind())
+	}
+
+	// A table written over several lines gets the new entry on its own
+	// line above the closing brace
+	closeLine := strings.LastIndexByte(d.src[:table.close], '\n') + 1
+	multiline := strings.TrimSpace(d.src[closeLine:table.close]) == "" && closeLine > node.start
+
+	indent := d.lineIndent(node.start)
+	if multiline {
+		indent = d.src[closeLine:table.close] + "\t"
+		if len(table.fields) > 0 {
+			indent = d.lineIndent(table.fields[len(table.fields)-1].start)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeKey(w, key); err != nil {
+		return err
+	}
+	w.WriteString(" = ")
+	w.Flush()
+	valueStart := buf.Len()
+	if err := writeValue(w, value, strings.Count(indent, "\t")); err != nil {
+		return err
+	}
+	w.Flush()
+	entry := buf.String() + ","
+
+	// The last entry needs a separator before another can follow
+	var edits []edit
+	var last *fieldNode
+	if n := len(table.fields); n > 0 {
+		last = table.fields[n-1]
+	}
+	comma := last != nil && last.sepEnd == last.end
+	if comma {
+		edits = append(edits, edit{last.end, last.end, ","})
+	}
+
+	var at int
+	var prefix string
+	switch {
+	case multiline:
+		at, prefix = closeLine, indent
+		edits = append(edits, edit{closeLine, closeLine, indent + entry + "\n"})
+	case last != nil:
+		// Keep whatever spacing there is before the closing brace
+		at, prefix = last.sepEnd, " "
+		edits = append(edits, edit{last.sepEnd, last.sepEnd, " " + entry})
+	default:
+		at, prefix = table.close, " "
+		edits = append(edits, edit{table.close, table.close, " " + entry + " "})
+	}
+
+	// The new entry starts after the separator added before it
+	start := at + len(prefix)
+	if comma {
+		start++
+	}
+	entryValue, err := parseNodeAt(entry[valueStart:len(entry)-1], start+valueStart)
+	if err != nil {
+		return err
+	}
+
+	d.apply(edits...)
+	if comma {
+		last.sepEnd++
+	}
+	table.fields = append(table.fields, &fieldNode{
+		key:    key,
+		start:  start,
+		end:    start + len(entry) - 1,
+		sepEnd: start + len(entry),
+		value:  entryValue,
+	})
+	return nil
+}
+
+// Delete removes the entry at path. Deleting an entry that doesn't exist
+// is not an error. Array entries can't be deleted since that would
+// renumber the entries after them.
+func (d *Document) Delete(path Path) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty path")
+	}
+
+	_, _, ok := d.lookup(path)
+	if !ok {
+		return nil
+	}
+
+	if len(path) == 1 {
+		global := d.global(path[0])
+		d.apply(d.removal(global.start, global.value.end))
+		for i, g := range d.globals {
+			if g == global {
+				d.globals = append(d.globals[:i], d.globals[i+1:]...)
+				break
+			}
+		}
+		return nil
+	}
+
+	parent, _, _ := d.lookup(path[:len(path)-1])
+	field := parent.table.field(path[len(path)-1])
+	if field.positional {
+		return fmt.Errorf("cannot delete array entry %v", path)
+	}
+
+	d.apply(d.removal(field.start, field.sepEnd))
+	for i, f := range parent.table.fields {
+		if f == field {
+			parent.table.fields = append(parent.table.fields[:i], parent.table.fields[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// removal returns the edit that removes src[start:end], taking whole lines
+// when the entry has them to itself
+func (d *Document) removal(start, end int) edit {
+	lineStart := d.lineStart(start)
+	lineEnd := d.lineEnd(end)
+	if strings.TrimSpace(d.src[lineStart:start]) == "" && isTrailing(d.src[end:lineEnd]) {
+		return edit{lineStart, lineEnd, ""}
+	}
+	for end < len(d.src) && (d.src[end] == ' ' || d.src[end] == '\t') {
+		end++
+	}
+	return edit{start, end, ""}
+}
+
+// isTrailing reports whether s, the rest of a line, holds only whitespace
+// or a line comment
+func isTrailing(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || (strings.HasPrefix(s, "--") && !strings.HasPrefix(s, "--["))
+}
+
+// edit replaces src[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// apply applies edits, given in source order, and moves the nodes after
+// them to their new offsets. Nodes inside replaced text are left as they
+// are for the caller to replace, so the file isn't parsed again.
+func (d *Document) apply(edits ...edit) {
+	var b strings.Builder
+	last := 0
+	for _, e := range edits {
+		b.WriteString(d.src[last:e.start])
+		b.WriteString(e.text)
+		last = e.end
+	}
+	b.WriteString(d.src[last:])
+	d.src = b.String()
+
+	// A span that ends where text is inserted doesn't take it in, while
+	// one that ends where replaced text ends does
+	shift := func(offset *int, end bool) {
+		delta := 0
+		for _, e := range edits {
+			if *offset > e.end || *offset == e.end && (!end || e.start < e.end) {
+				delta += len(e.text) - (e.end - e.start)
+			}
+		}
+		*offset += delta
+	}
+	for _, global := range d.globals {
+		global.eachOffset(edits[0].start, shift)
+	}
+}
+
+// eachOffset calls fn with the offsets of the global and its value that
+// may lie after from; end tells whether the offset ends a span
+func (g *globalNode) eachOffset(from int, fn func(offset *int, end bool)) {
+	if g.value.end <= from {
+		return
+	}
+	fn(&g.start, false)
+	g.value.eachOffset(from, fn)
+}
+
+// eachOffset calls fn with the offsets of the node and its fields that may
+// lie after from; end tells whether the offset ends a span
+func (n *valueNode) eachOffset(from int, fn func(offset *int, end bool)) {
+	if n.end <= from {
+		return
+	}
+	fn(&n.start, false)
+	fn(&n.end, true)
+	if n.table == nil {
+		return
+	}
+	fn(&n.table.close, false)
+	for _, field := range n.table.fields {
+		if field.sepEnd <= from {
+			continue
+		}
+		fn(&field.start, false)
+		fn(&field.end, true)
+		fn(&field.sepEnd, true)
+		field.value.eachOffset(from, fn)
+	}
+}
+
+// parseNodeAt parses the encoded value text that will be inserted at
+// offset and returns its node at that position
+func parseNodeAt(text string, offset int) (valueNode, error) {
+	l := newStringLexer(text, Limits{})
+	if err := l.readError(); err != nil {
+		return valueNode{}, err
+	}
+	p := newParser(l)
+	node, err := p.parseNode()
+	if err == nil && p.peek().typ != tokenEOF {
+		err = p.unexpected(p.peek(), "end of value")
+	}
+	if err != nil {
+		return valueNode{}, fmt.Errorf("edit produced invalid Lua: %w", err)
+	}
+
+	node.eachOffset(-1, func(o *int, _ bool) { *o += offset })
+	return node, nil
+}
+
+// lineStart returns the offset of the start of the line containing offset
+func (d *Document) lineStart(offset int) int {
+	return strings.LastIndexByte(d.src[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset just past the newline ending the line
+// containing offset, or the end of the source
+func (d *Document) lineEnd(offset int) int {
+	if i := strings.IndexByte(d.src[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(d.src)
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func (d *Document) lineIndent(offset int) string {
+	start := d.lineStart(offset)
+	end := start
+	for end < len(d.src) && (d.src[end] == ' ' || d.src[end] == '\t') {
+		end++
+	}
+	return d.src[start:end]
+}
+
+// lineDepth returns the number of tabs indenting the line containing offset
+func (d *Document) lineDepth(offset int) int {
+	return strings.Count(d.lineIndent(offset), "\t")
+}
+
+// encodeAt encodes a value whose first line is indented depth tabs
+func encodeAt(value Value, depth int) (string, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeValue(w, value, depth); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,327 @@
+package lua
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const documentSource = `
+-- Saved by a hand-edited addon
+AddonProfilesDB = {
+	["global"] = {
+		["activeProfile"] = "Default",
+		["profiles"] = {
+			["Raid"] = {
+				["addons"] = {
+					["BigWigs"] = true, -- keep this comment
+					["WeakAuras"] = false,
+				},
+				["futureKey"] = { 1, 2, 3 },
+			},
+		},
+	},
+	["char"] = {
+	},
+}
+AddonProfilesDBVersion = 3
+`
+
+func TestDocumentUntouched(t *testing.T) {
+	for _, file := range []string{"valid_profile.lua", "empty_profile.lua", "wow_format.lua"} {
+		content, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("Failed to read test file: %v", err)
+		}
+
+		doc, err := ParseDocument(content)
+		if err != nil {
+			t.Fatalf("ParseDocument(%s) error = %v", file, err)
+		}
+		if string(doc.Bytes()) != string(content) {
+			t.Errorf("%s: Bytes() changed an unedited document", file)
+		}
+	}
+}
+
+func TestDocumentSet(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		v    interface{}
+		// old and new are the lines that change; everything else must not
+		old string
+		new string
+	}{
+		{
+			name: "replace value",
+			path: `AddonProfilesDB.global.profiles["Raid"].addons["WeakAuras"]`,
+			v:    true,
+			old:  "\t\t\t\t\t[\"WeakAuras\"] = false,\n",
+			new:  "\t\t\t\t\t[\"WeakAuras\"] = true,\n",
+		},
+		{
+			name: "add key",
+			path: `AddonProfilesDB.global.profiles.Raid.addons.Details`,
+			v:    true,
+			old:  "\t\t\t\t\t[\"WeakAuras\"] = false,\n",
+			new:  "\t\t\t\t\t[\"WeakAuras\"] = false,\n\t\t\t\t\t[\"Details\"] = true,\n",
+		},
+		{
+			name: "add to empty table",
+			path: `AddonProfilesDB.char["Me - Realm"].activeProfile`,
+			v:    "PvP",
+			old:  "\t[\"char\"] = {\n\t},\n",
+			new:  "\t[\"char\"] = {\n\t\t[\"Me - Realm\"] = {\n\t\t\t[\"activeProfile\"] = \"PvP\",\n\t\t},\n\t},\n",
+		},
+		{
+			name: "replace table",
+			path: `AddonProfilesDB.global.profiles.Raid.futureKey`,
+			v:    []int{4},
+			old:  "\t\t\t\t[\"futureKey\"] = { 1, 2, 3 },\n",
+			new:  "\t\t\t\t[\"futureKey\"] = {\n\t\t\t\t\t4, -- [1]\n\t\t\t\t},\n",
+		},
+		{
+			name: "add to inline table",
+			path: `AddonProfilesDB.global.profiles.Raid.futureKey.extra`,
+			v:    1,
+			old:  "{ 1, 2, 3 }",
+			new:  "{ 1, 2, 3, [\"extra\"] = 1, }",
+		},
+		{
+			name: "replace global",
+			path: `AddonProfilesDBVersion`,
+			v:    4,
+			old:  "AddonProfilesDBVersion = 3\n",
+			new:  "AddonProfilesDBVersion = 4\n",
+		},
+		{
+			name: "add global",
+			path: `OtherDB.enabled`,
+			v:    true,
+			old:  "AddonProfilesDBVersion = 3\n",
+			new:  "AddonProfilesDBVersion = 3\n\nOtherDB = {\n\t[\"enabled\"] = true,\n}\n",
+		},
+		{
+			name: "delete key",
+			path: `AddonProfilesDB.global.profiles.Raid.addons.BigWigs`,
+			v:    nil,
+			old:  "\t\t\t\t\t[\"BigWigs\"] = true, -- keep this comment\n",
+			new:  "",
+		},
+		{
+			name: "delete global",
+			path: `AddonProfilesDBVersion`,
+			v:    nil,
+			old:  "AddonProfilesDBVersion = 3\n",
+			new:  "",
+		},
+		{
+			name: "delete missing key",
+			path: `AddonProfilesDB.global.profiles.Missing`,
+			v:    nil,
+			old:  "",
+			new:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument([]byte(documentSource))
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+
+			path, err := ParsePath(tt.path)
+			if err != nil {
+				t.Fatalf("ParsePath() error = %v", err)
+			}
+
+			if err := doc.Set(path, tt.v); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			want := strings.Replace(documentSource, tt.old, tt.new, 1)
+			if got := string(doc.Bytes()); got != want {
+				t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+			}
+			checkNodes(t, doc)
+
+			if tt.v == nil {
+				return
+			}
+			got, ok := doc.Get(path)
+			if !ok {
+				t.Fatalf("Get() found nothing after Set()")
+			}
+			wantValue, _ := ToValue(tt.v)
+			if !reflect.DeepEqual(toInterface(got), toInterface(wantValue)) {
+				t.Errorf("Get() = %v, want %v", toInterface(got), toInterface(wantValue))
+			}
+		})
+	}
+}
+
+func TestDocumentEdits(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentSource))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+
+	// Each edit starts from the nodes the previous ones left behind
+	edits := []struct {
+		path string
+		v    interface{}
+	}{
+		{`AddonProfilesDB.global.profiles.Raid.addons.Details`, true},
+		{`AddonProfilesDB.global.profiles.Raid.addons.BigWigs`, nil},
+		{`AddonProfilesDB.global.profiles.Raid.addons.Details`, false},
+		{`AddonProfilesDB.global.profiles.Raid.futureKey.extra`, "x"},
+		{`AddonProfilesDB.global.profiles.Raid.futureKey.more`, 2},
+		{`AddonProfilesDB.char["Me - Realm"].profiles.PvP`, map[string]interface{}{"addons": map[string]bool{"Gladius": true}}},
+		{`AddonProfilesDB.char["Me - Realm"].profiles.PvP.addons.OmniBar`, true},
+		{`AddonProfilesDB.global.activeProfile`, "Raid"},
+		{`AddonProfilesDB.syncedAt`, 1698765440},
+		{`AddonProfilesDBVersion`, nil},
+		{`OtherDB.enabled`, true},
+		{`AddonProfilesDB.global.profiles.Raid`, nil},
+		{`OtherDB.enabled`, false},
+	}
+
+	for _, e := range edits {
+		path, err := ParsePath(e.path)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) error = %v", e.path, err)
+		}
+		if err := doc.Set(path, e.v); err != nil {
+			t.Fatalf("Set(%s) error = %v", e.path, err)
+		}
+		checkNodes(t, doc)
+
+		got, ok := doc.Get(path)
+		if e.v == nil {
+			if ok {
+				t.Errorf("Get(%s) = %v after delete", e.path, got)
+			}
+			continue
+		}
+		wantValue, _ := ToValue(e.v)
+		if !ok || !reflect.DeepEqual(toInterface(got), toInterface(wantValue)) {
+			t.Errorf("Get(%s) = %v, want %v", e.path, got, wantValue)
+		}
+	}
+}
+
+// checkNodes fails the test if the nodes an edit left don't match what
+// parsing the edited source gives
+func checkNodes(t *testing.T, doc *Document) {
+	t.Helper()
+
+	fresh, err := ParseDocument(doc.Bytes())
+	if err != nil {
+		t.Fatalf("ParseDocument() of edited source error = %v\n%s", err, doc.Bytes())
+	}
+	if got, want := dumpNodes(doc), dumpNodes(fresh); got != want {
+		t.Errorf("nodes after edit =\n%s\nwant\n%s\nfor source\n%s", got, want, doc.Bytes())
+	}
+}
+
+// dumpNodes describes the nodes of a document, one per line
+func dumpNodes(doc *Document) string {
+	var b strings.Builder
+	var dump func(node valueNode, indent string)
+	dump = func(node valueNode, indent string) {
+		fmt.Fprintf(&b, "%s[%d:%d]", indent, node.start, node.end)
+		if node.table == nil {
+			b.WriteString("\n")
+			return
+		}
+		fmt.Fprintf(&b, " close %d\n", node.table.close)
+		for _, field := range node.table.fields {
+			fmt.Fprintf(&b, "%s  %v positional=%v [%d:%d:%d]\n", indent, field.key, field.positional, field.start, field.end, field.sepEnd)
+			dump(field.value, indent+"    ")
+		}
+	}
+	for _, global := range doc.globals {
+		fmt.Fprintf(&b, "%s at %d\n", global.name, global.start)
+		dump(global.value, "  ")
+	}
+	return b.String()
+}
+
+func TestDocumentErrors(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentSource))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path Path
+	}{
+		{"index into string", NewPath("AddonProfilesDB", "global", "activeProfile", "x")},
+		{"invalid global", NewPath("not a name")},
+	}
+	for _, tt := range tests {
+		if err := doc.Set(tt.path, true); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	arrayEntry := append(NewPath("AddonProfilesDB", "global", "profiles", "Raid", "futureKey"), Number(1))
+	if err := doc.Delete(arrayEntry); err == nil {
+		t.Error("Expected error deleting an array entry")
+	}
+
+	if string(doc.Bytes()) != documentSource {
+		t.Error("failed edits changed the document")
+	}
+
+	if _, err := ParseDocument([]byte("AddonProfilesDB = {\n\t[\"a\"] = 1\n\t[\"b\"] = 2,\n}")); err == nil {
+		t.Error("Expected error for invalid document")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Path
+		wantErr bool
+	}{
+		{input: "AddonProfilesDB", want: NewPath("AddonProfilesDB")},
+		{input: `DB.global["my profile"].addons`, want: NewPath("DB", "global", "my profile", "addons")},
+		{input: `DB['a'][1][true]._x`, want: Path{String("DB"), String("a"), Number(1), Bool(true), String("_x")}},
+		{input: `DB[-2.5]`, want: Path{String("DB"), Number(-2.5)}},
+		{input: `DB.char.Ærïs["Пётр - Гордунни"]`, want: NewPath("DB", "char", "Ærïs", "Пётр - Гордунни")},
+		{input: "", wantErr: true},
+		{input: "DB.", wantErr: true},
+		{input: "DB..x", wantErr: true},
+		{input: `DB["open`, wantErr: true},
+		{input: `DB[x]`, wantErr: true},
+		{input: `DB["a"]b`, wantErr: true},
+		{input: `["a"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePath(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.String() != tt.want.String() || len(got) != len(tt.want) {
+				t.Errorf("ParsePath() = %v, want %v", got, tt.want)
+			}
+
+			again, err := ParsePath(got.String())
+			if err != nil || again.String() != got.String() {
+				t.Errorf("ParsePath(%q) = %v, %v; want it to read back", got.String(), again, err)
+			}
+		})
+	}
+}
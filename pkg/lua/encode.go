@@ -0,0 +1,389 @@
+package lua
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Marshaler is implemented by types that encode themselves as a Lua value
+type Marshaler interface {
+	MarshalLua() (Value, error)
+}
+
+// Marshal returns the Lua encoding of v in the format WoW writes
+// SavedVariables in. See Encoder.Encode for how Go values are encoded.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encoder writes Lua values to an output stream
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the Lua encoding of v followed by a newline.
+//
+// Tables are written one tab-indented `["key"] = value,` line per field,
+// with array entries first, each followed by a `-- [n]` comment, as WoW
+// does. Structs use the same `lua:"key,omitempty"` and `lua:",remain"`
+// tags as Unmarshal. The keys of structs and maps are sorted so the output
+// is stable, while a *Table keeps its own order. Nil values are left out,
+// as Lua tables can't hold them.
+func (e *Encoder) Encode(v interface{}) error {
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n")
+	return err
+}
+
+// EncodeGlobal writes v as the SavedVariables global name, e.g.
+//
+//	AddonProfilesDB = {
+//	}
+func (e *Encoder) EncodeGlobal(name string, v interface{}) error {
+	if _, err := fmt.Fprintf(e.w, "\n%s = ", name); err != nil {
+		return err
+	}
+	return e.Encode(v)
+}
+
+func (e *Encoder) encode(v interface{}) error {
+	value, err := ToValue(v)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(e.w)
+	if err := writeValue(bw, value, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ToValue converts a Go value to a Lua value the way Marshal encodes it
+func ToValue(v interface{}) (Value, error) {
+	value, err := toValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return Nil{}, nil
+	}
+	return value, nil
+}
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// toValue converts rv, returning a nil Value for values to leave out
+func toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	if rv.Type().Implements(marshalerType) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Interface().(Marshaler).MarshalLua()
+	}
+
+	// Values of this package are taken as they are
+	if rv.Type().Implements(valueType) {
+		if rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return nil, nil
+			}
+		}
+		value := rv.Interface().(Value)
+		if value.Kind() == KindNil {
+			return nil, nil
+		}
+		return value, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return toValue(rv.Elem())
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Number(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Number(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("cannot encode %v as a Lua number", f)
+		}
+		return Number(f), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Struct:
+		return structToTable(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return mapToTable(rv)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return sliceToTable(rv)
+	case reflect.Array:
+		return sliceToTable(rv)
+	default:
+		return nil, fmt.Errorf("cannot encode Go value of type %v", rv.Type())
+	}
+}
+
+func structToTable(rv reflect.Value) (Value, error) {
+	fields := structFields(rv.Type())
+
+	var entries []Field
+	known := make(map[string]bool, len(fields))
+	var remain reflect.Value
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		if f.remain {
+			remain = fv
+			continue
+		}
+		known[f.name] = true
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		value, err := toValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		if value != nil {
+			entries = append(entries, Field{Key: String(f.name), Value: value})
+		}
+	}
+
+	// Entries kept by a remain field are written unless a field took over
+	// the key
+	if remain.IsValid() && remain.Kind() == reflect.Map {
+		iter := remain.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if known[name] {
+				continue
+			}
+			value, err := toValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if value != nil {
+				entries = append(entries, Field{Key: String(name), Value: value})
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key.(String) < entries[j].Key.(String)
+	})
+
+	table := NewTable()
+	for _, entry := range entries {
+		table.Set(entry.Key, entry.Value)
+	}
+	return table, nil
+}
+
+func mapToTable(rv reflect.Value) (Value, error) {
+	type entry struct {
+		key   Value
+		value Value
+	}
+
+	entries := make([]entry, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := toValue(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		if key == nil || key.Kind() == KindTable {
+			return nil, fmt.Errorf("cannot encode map key of type %v", iter.Key().Type())
+		}
+
+		value, err := toValue(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyString(key), err)
+		}
+		if value != nil {
+			entries = append(entries, entry{key, value})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return keyLess(entries[i].key, entries[j].key)
+	})
+
+	table := NewTable()
+	for _, e := range entries {
+		table.Set(e.key, e.value)
+	}
+	return table, nil
+}
+
+// keyLess orders keys as numbers, then strings, then booleans
+func keyLess(a, b Value) bool {
+	if a.Kind() != b.Kind() {
+		return keyRank(a) < keyRank(b)
+	}
+
+	switch a := a.(type) {
+	case Number:
+		return a < b.(Number)
+	case String:
+		return a < b.(String)
+	case Bool:
+		return !bool(a) && bool(b.(Bool))
+	}
+	return false
+}
+
+func keyRank(key Value) int {
+	switch key.Kind() {
+	case KindNumber:
+		return 0
+	case KindString:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func sliceToTable(rv reflect.Value) (Value, error) {
+	table := NewTable()
+	for i := 0; i < rv.Len(); i++ {
+		value, err := toValue(rv.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", i+1, err)
+		}
+
+		// A nil would end the sequence, so later entries get explicit keys
+		if value == nil {
+			continue
+		}
+		if len(table.Array) == i {
+			table.Append(value)
+		} else {
+			table.Set(Number(i+1), value)
+		}
+	}
+	return table, nil
+}
+
+// writeValue writes a Lua value. Tables are written one field per line.
+func writeValue(w *bufio.Writer, value Value, depth int) error {
+	switch v := value.(type) {
+	case *Table:
+		indent := strings.Repeat("\t", depth+1)
+		w.WriteString("{\n")
+		for i, item := range v.Array {
+			w.WriteString(indent)
+			if err := writeValue(w, item, depth+1); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, ", -- [%d]\n", i+1)
+		}
+		for _, field := range v.Fields() {
+			w.WriteString(indent)
+			if err := writeKey(w, field.Key); err != nil {
+				return err
+			}
+			w.WriteString(" = ")
+			if err := writeValue(w, field.Value, depth+1); err != nil {
+				return err
+			}
+			w.WriteString(",\n")
+		}
+		w.WriteString(strings.Repeat("\t", depth))
+		w.WriteString("}")
+	case String:
+		w.WriteString(quoteString(string(v)))
+	case Number:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("cannot encode %v as a Lua number", f)
+		}
+		w.WriteString(v.String())
+	case Bool:
+		w.WriteString(strconv.FormatBool(bool(v)))
+	default:
+		w.WriteString("nil")
+	}
+	return nil
+}
+
+// writeKey writes a table key in brackets, e.g. ["name"] or [1]
+func writeKey(w *bufio.Writer, key Value) error {
+	switch key.Kind() {
+	case KindString, KindNumber, KindBool:
+		w.WriteString("[")
+		if err := writeValue(w, key, 0); err != nil {
+			return err
+		}
+		w.WriteString("]")
+		return nil
+	default:
+		return fmt.Errorf("cannot encode table key of type %v", key.Kind())
+	}
+}
+
+// quoteString returns s as a double-quoted Lua string. UTF-8 text such as
+// character names is written as it is, while control characters and bytes
+// that aren't valid UTF-8 are written as decimal escapes, so the file stays
+// valid UTF-8 with one entry per line and reads back byte for byte.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch c := s[i]; {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\%03d`, c)
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\%03d`, c)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package lua
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	type options struct {
+		Sound  string   `lua:"sound,omitempty"`
+		Volume float64  `lua:"volume"`
+		Tags   []string `lua:"tags"`
+		Hidden string   `lua:"-"`
+	}
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "nil", v: nil, want: "nil"},
+		{name: "bool", v: true, want: "true"},
+		{name: "integer", v: int64(1698765432), want: "1698765432"},
+		{name: "float", v: 0.85, want: "0.85"},
+		{name: "whole float", v: 2.0, want: "2"},
+		{name: "escaped string", v: "say \"hi\"\\\n\x01", want: `"say \"hi\"\\\n\001"`},
+		{name: "UTF-8 string", v: "Ærïs - Argent Dawn, 가나다", want: `"Ærïs - Argent Dawn, 가나다"`},
+		{name: "invalid UTF-8", v: "a\xffb\xc3", want: `"a\255b\195"`},
+		{name: "empty map", v: map[string]bool{}, want: "{\n}"},
+		{name: "nil map", v: map[string]bool(nil), want: "nil"},
+		{
+			name: "sorted keys",
+			v:    map[string]int{"b": 2, "a": 1, "C": 3},
+			want: "{\n\t[\"C\"] = 3,\n\t[\"a\"] = 1,\n\t[\"b\"] = 2,\n}",
+		},
+		{
+			name: "integer keys",
+			v:    map[int]string{10: "ten", 2: "two"},
+			want: "{\n\t[2] = \"two\",\n\t[10] = \"ten\",\n}",
+		},
+		{
+			name: "sequence",
+			v:    []interface{}{"a", 1, nil, true},
+			want: "{\n\t\"a\", -- [1]\n\t1, -- [2]\n\t[4] = true,\n}",
+		},
+		{
+			name: "struct",
+			v:    options{Volume: 0.5, Tags: []string{"x"}, Hidden: "secret"},
+			want: "{\n\t[\"tags\"] = {\n\t\t\"x\", -- [1]\n\t},\n\t[\"volume\"] = 0.5,\n}",
+		},
+		{name: "NaN", v: math.NaN(), wantErr: true},
+		{name: "unsupported", v: make(chan int), wantErr: true},
+		{name: "table key", v: map[Value]bool{NewTable(): true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	type aura struct {
+		ID     string            `lua:"id"`
+		Alpha  float64           `lua:"alpha"`
+		Spells []int             `lua:"spells"`
+		Load   map[string]bool   `lua:"load"`
+		Extra  map[string]string `lua:"extra,omitempty"`
+	}
+
+	in := map[string]*aura{
+		"Interrupts":  {ID: "Interrupts", Alpha: 0.85, Spells: []int{47528, 183752}, Load: map[string]bool{"pvp": false}},
+		"Kicks \"2\"": {ID: "Kicks", Spells: []int{}, Load: map[string]bool{}},
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var out map[string]*aura
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+// TestEncodeFixtures checks that fixtures in WoW's format are written back
+// byte for byte
+func TestEncodeFixtures(t *testing.T) {
+	tests := []struct {
+		file   string
+		global string
+	}{
+		{file: "valid_profile.lua", global: "AddonProfilesDB"},
+		{file: "empty_profile.lua", global: "AddonProfilesDB"},
+		{file: "wow_format.lua", global: "WeakAurasSaved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+
+			_, source, ok := strings.Cut(string(content), tt.global+" = ")
+			if !ok {
+				t.Fatalf("%s not found in %s", tt.global, tt.file)
+			}
+			source = strings.TrimSpace(source)
+
+			value, err := ParseValue(source)
+			if err != nil {
+				t.Fatalf("ParseValue() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf).EncodeGlobal(tt.global, value); err != nil {
+				t.Fatalf("EncodeGlobal() error = %v", err)
+			}
+
+			want := "\n" + tt.global + " = " + source + "\n"
+			if buf.String() != want {
+				t.Errorf("EncodeGlobal() =\n%s\nwant\n%s", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestWriteDatabaseRoundTrip(t *testing.T) {
+	for _, file := range []string{"valid_profile.lua", "empty_profile.lua"} {
+		db, err := ParseFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := Write(&buf, db); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+
+		parsed, err := ParseSimple(buf.String())
+		if err != nil {
+			t.Fatalf("ParseSimple() error = %v", err)
+		}
+		if !reflect.DeepEqual(parsed, db) {
+			t.Errorf("%s: round trip = %+v, want %+v", file, parsed, db)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes where and why a Lua file could not be parsed
+type ParseError struct {
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+	Offset int // byte offset into the input
+
+	// Expected and Got name the wanted and the found token, e.g. "'}'" and
+	// "string \"abc\"". Expected is empty for other errors.
+	Expected string
+	Got      string
+	Message  string
+
+	// Excerpt is the offending line with a caret under the column
+	Excerpt string
+}
+
+func (e *ParseError) Error() string {
+	if e.Expected != "" {
+		return fmt.Sprintf("line %d, column %d: expected %s, got %s", e.Line, e.Column, e.Expected, e.Got)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// newParseError creates an error at the position of tok
+func newParseError(tok token, message, excerpt string) *ParseError {
+	return &ParseError{
+		Line:    tok.line,
+		Column:  tok.col,
+		Offset:  tok.offset,
+		Got:     tok.describe(),
+		Message: message,
+		Excerpt: excerpt,
+	}
+}
+
+// maxExcerpt is the longest line shown in an excerpt; WoW writes some
+// SavedVariables as one very long line
+const maxExcerpt = 80
+
+// excerpt returns the line containing offset with a caret line below it
+func excerpt(input string, offset int) string {
+	offset = min(offset, len(input))
+
+	start := strings.LastIndexByte(input[:offset], '\n') + 1
+	end := strings.IndexByte(input[offset:], '\n')
+	if end < 0 {
+		end = len(input)
+	} else {
+		end += offset
+	}
+
+	// Keep the caret in view on long lines
+	if end-start > maxExcerpt {
+		start = max(start, offset-maxExcerpt/2)
+		end = min(end, start+maxExcerpt)
+	}
+
+	line := strings.TrimRight(input[start:end], "\r")
+
+	// Tabs are copied so the caret lines up however they are displayed
+	var caret strings.Builder
+	for i := start; i < offset; i++ {
+		if input[i] == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+
+	return line + "\n" + caret.String()
+}
@@ -0,0 +1,85 @@
+package lua
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// addSeeds adds the fixtures and a few hand-edited and half-written files
+// to the corpus. Inputs the fuzzers found are kept in testdata/fuzz.
+func addSeeds(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.lua"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(content))
+		f.Add(string(content[:len(content)/2]))
+	}
+
+	for _, seed := range []string{
+		"",
+		"AddonProfilesDB = {",
+		`AddonProfilesDB = { ["global"] = { ["profiles"] = { ["a\`,
+		`AddonProfilesDB = { ["char"] = { ["A - B"] = { ["profiles"] = {} } } }`,
+		"AddonProfilesDB = {{{{{{{{{{{{{{{{{{{{",
+		"AddonProfilesDB = [==[ ]=]",
+		"AddonProfilesDB = { 0x, 1e, -.5, 1..2 }",
+		"AddonProfilesDB = nil; AddonProfilesDB = {}",
+	} {
+		f.Add(seed)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, content string) {
+		db, err := Parse(content)
+		if err == nil && db == nil {
+			t.Fatal("Parse() returned neither a database nor an error")
+		}
+	})
+}
+
+func FuzzParseSimple(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, content string) {
+		db, err := ParseSimple(content)
+		if err != nil {
+			return
+		}
+
+		// Whatever was read must be written and read back the same
+		var buf bytes.Buffer
+		if err := Write(&buf, db); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		again, err := ParseSimple(buf.String())
+		if err != nil {
+			t.Fatalf("ParseSimple() of written file error = %v\n%s", err, buf.String())
+		}
+		if !reflect.DeepEqual(again, db) {
+			t.Errorf("round trip = %+v, want %+v", again, db)
+		}
+	})
+}
+
+func FuzzParseRegex(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, content string) {
+		db, err := parseRegex(content)
+		if err != nil {
+			return
+		}
+		if db.Global.Profiles == nil || db.Char == nil {
+			t.Errorf("parseRegex() = %+v, want maps set", db)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits bound the input the parsers accept, so a damaged or hostile file
+// fails with an error instead of exhausting memory or the stack. A zero
+// field takes the value of DefaultLimits, and a negative one means no
+// limit.
+type Limits struct {
+	// MaxDepth is how deeply tables may be nested
+	MaxDepth int
+	// MaxSize is the largest input in bytes
+	MaxSize int
+}
+
+// DefaultLimits are the limits parsers use unless they are given others.
+// They are far above what addons save: Lua itself stops at 200 nested
+// tables.
+var DefaultLimits = Limits{
+	MaxDepth: 200,
+	MaxSize:  512 << 20,
+}
+
+// ErrTooLarge is returned for input larger than Limits.MaxSize
+var ErrTooLarge = errors.New("input exceeds the size limit")
+
+// withDefaults fills in the zero fields from DefaultLimits
+func (l Limits) withDefaults() Limits {
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultLimits.MaxDepth
+	}
+	if l.MaxSize == 0 {
+		l.MaxSize = DefaultLimits.MaxSize
+	}
+	return l
+}
+
+// tooLarge returns ErrTooLarge with the limit that was exceeded
+func (l Limits) tooLarge() error {
+	return fmt.Errorf("%w of %d bytes", ErrTooLarge, l.MaxSize)
+}
+
+// tooDeep reports whether depth nested tables exceed the limit
+func (l Limits) tooDeep(depth int) bool {
+	return l.MaxDepth > 0 && depth > l.MaxDepth
+}
@@ -2,6 +2,7 @@ package lua
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
@@ -11,24 +12,54 @@ import (
 
 // Profile represents an addon profile
 type Profile struct {
-	Name     string
-	Scope    string // "account" or "character"
-	Addons   map[string]bool
-	AutoDeps bool
-	Created  int64
+	Name     string          `lua:"-"`     // the key the profile is stored under
+	Scope    string          `lua:"scope"` // "account" or "character"
+	Addons   map[string]bool `lua:"addons"`
+	AutoDeps bool            `lua:"autoDeps"`
+	Created  int64           `lua:"created"`
+	// Extra keeps the fields this package doesn't know, e.g. ones a newer
+	// addon version added, so they are written back
+	Extra map[string]Value `lua:",remain"`
 }
 
-// Database represents the parsed AddonProfilesDB structure
+// UnmarshalLua decodes a profile table; a missing autoDeps means true
+func (p *Profile) UnmarshalLua(v Value) error {
+	type profile Profile // without this method
+	decoded := profile{AutoDeps: true}
+	err := UnmarshalValue(v, &decoded)
+	*p = Profile(decoded)
+	return err
+}
+
+// Database represents the parsed AddonProfilesDB structure, with the
+// defaults of fields older addon versions didn't save filled in
 type Database struct {
-	Global struct {
-		ActiveProfile string
-		Profiles      map[string]*Profile
-		Settings      map[string]interface{}
-	}
-	Char map[string]struct {
-		ActiveProfile string
-		Profiles      map[string]*Profile
-	}
+	Global GlobalData          `lua:"global"`
+	Char   map[string]CharData `lua:"char"`
+	Extra  map[string]Value    `lua:",remain"`
+}
+
+// GlobalData holds the account-wide profiles and settings
+type GlobalData struct {
+	ActiveProfile string              `lua:"activeProfile,omitempty"`
+	Profiles      map[string]*Profile `lua:"profiles"`
+	Settings      *Settings           `lua:"settings,omitempty"`
+	Extra         map[string]Value    `lua:",remain"`
+}
+
+// Settings are the addon's account-wide options
+type Settings struct {
+	// HideDefaultAddonsButton hides the addon's button in the game's
+	// AddOns list
+	HideDefaultAddonsButton bool             `lua:"hideDefaultAddonsButton,omitempty"`
+	Extra                   map[string]Value `lua:",remain"`
+}
+
+// CharData holds the profiles of one "Character - Realm"
+type CharData struct {
+	ActiveProfile string              `lua:"activeProfile,omitempty"`
+	Profiles      map[string]*Profile `lua:"profiles"`
+	Extra         map[string]Value    `lua:",remain"`
 }
 
 // Parser handles parsing of Lua SavedVariables files
@@ -45,36 +76,159 @@ func NewParser(content string) *Parser {
 	}
 }
 
+// Parsers that Parse can use
+const (
+	ParserSimple = "simple"
+	ParserRegex  = "regex"
+)
+
+// ParseMode selects what happens when the simple parser fails
+type ParseMode int
+
+const (
+	// ParseLenient falls back to the regex parser, which reads what it can
+	// of a damaged file but may miss profiles or misread them
+	ParseLenient ParseMode = iota
+	// ParseStrict returns the simple parser's error instead
+	ParseStrict
+)
+
+// ParseOptions configure ParseWithOptions and ParseFileWithOptions
+type ParseOptions struct {
+	Mode ParseMode
+	// Limits bound the input; the zero value means DefaultLimits
+	Limits Limits
+	// Compare also runs the regex parser when the simple parser succeeds
+	// and reports where the two disagree
+	Compare bool
+}
+
+// ParseReport tells how a file was parsed
+type ParseReport struct {
+	// Parser is the parser whose result was returned
+	Parser string
+	// Errors are the errors of parsers that failed before it, usually a
+	// *ParseError with the position of the problem. When the simple parser
+	// succeeded, an *UnmarshalTypeError tells of an entry it skipped
+	// because it had the wrong type.
+	Errors []error
+	// Disagreements lists where the regex parser read the file differently,
+	// when ParseOptions.Compare is set
+	Disagreements []Disagreement
+}
+
+// Recovered reports whether the result came from the lenient fallback
+// parser, in which case parts of the file may be missing
+func (r *ParseReport) Recovered() bool {
+	return r.Parser == ParserRegex
+}
+
+// Complete reports whether everything in the file was read, so the
+// database can be written back without losing entries
+func (r *ParseReport) Complete() bool {
+	return len(r.Errors) == 0
+}
+
 // ParseFile parses a Lua SavedVariables file
 func ParseFile(filepath string) (*Database, error) {
-	content, err := os.ReadFile(filepath)
+	db, _, err := ParseFileWithReport(filepath)
+	return db, err
+}
+
+// ParseFileWithReport parses a Lua SavedVariables file and reports which
+// parser was used
+func ParseFileWithReport(filepath string) (*Database, *ParseReport, error) {
+	return ParseFileWithOptions(filepath, ParseOptions{})
+}
+
+// ParseFileWithOptions parses a Lua SavedVariables file like
+// ParseWithOptions. The file is streamed through the simple parser; only
+// the regex parser reads all of it.
+func ParseFileWithOptions(filepath string, opts ParseOptions) (*Database, *ParseReport, error) {
+	file, err := os.Open(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	defer file.Close()
 
-	return Parse(string(content))
+	db, skipped, err := parseSimple(newLexer(file, opts.Limits))
+	return finishParse(db, skipped, err, opts, func() (string, error) {
+		content, err := os.ReadFile(filepath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		return string(content), nil
+	})
 }
 
 // Parse parses Lua content and extracts the database structure
 func Parse(content string) (*Database, error) {
-	// Try simple parser first
-	db, err := ParseSimple(content)
+	db, _, err := ParseWithReport(content)
+	return db, err
+}
+
+// ParseWithReport parses Lua content like Parse and reports which parser
+// succeeded and the errors of those that didn't
+func ParseWithReport(content string) (*Database, *ParseReport, error) {
+	return ParseWithOptions(content, ParseOptions{})
+}
+
+// ParseWithOptions parses Lua content with the simple parser. What happens
+// when it fails depends on opts.Mode, and with opts.Compare the regex
+// parser checks its result.
+func ParseWithOptions(content string, opts ParseOptions) (*Database, *ParseReport, error) {
+	db, skipped, err := parseSimple(newStringLexer(content, opts.Limits))
+	return finishParse(db, skipped, err, opts, func() (string, error) {
+		return content, nil
+	})
+}
+
+// finishParse takes the simple parser's result and runs the regex parser
+// on the content if opts ask for it
+func finishParse(db *Database, skipped, err error, opts ParseOptions, content func() (string, error)) (*Database, *ParseReport, error) {
+	report := &ParseReport{Parser: ParserSimple}
+
 	if err == nil {
-		return db, nil
+		if skipped != nil {
+			report.Errors = append(report.Errors, skipped)
+		}
+		if opts.Compare {
+			text, err := content()
+			if err != nil {
+				return nil, report, err
+			}
+			if other, err := parseRegex(text); err == nil {
+				report.Disagreements = compareDatabases(db, other)
+			}
+		}
+		return db, report, nil
+	}
+	report.Errors = append(report.Errors, err)
+
+	// The regex parser would have to read all of a file that is too large
+	if opts.Mode == ParseStrict || errors.Is(err, ErrTooLarge) {
+		return nil, report, err
+	}
+
+	text, err := content()
+	if err != nil {
+		return nil, report, err
 	}
 
 	// Fallback to regex-based parser (kept for compatibility)
-	return parseRegex(content)
+	report.Parser = ParserRegex
+	db, err = parseRegex(text)
+	if err != nil {
+		return nil, report, err
+	}
+	return db, report, nil
 }
 
 // parseRegex is the old regex-based parser (kept as fallback)
 func parseRegex(content string) (*Database, error) {
 	db := &Database{}
 	db.Global.Profiles = make(map[string]*Profile)
-	db.Char = make(map[string]struct {
-		ActiveProfile string
-		Profiles      map[string]*Profile
-	})
+	db.Char = make(map[string]CharData)
 
 	// Parse global profiles
 	globalProfiles, err := extractTable(content, "AddonProfilesDB", "global", "profiles")
@@ -95,10 +249,7 @@ func parseRegex(content string) (*Database, error) {
 	// Parse character profiles
 	charSection := extractCharSection(content)
 	for charKey, charContent := range charSection {
-		charData := struct {
-			ActiveProfile string
-			Profiles      map[string]*Profile
-		}{
+		charData := CharData{
 			Profiles: make(map[string]*Profile),
 		}
 
@@ -190,12 +341,12 @@ func parseTableContent(content string) map[string]string {
 	result := make(map[string]string)
 
 	// Match table entries: ["key"] = value or ["key"] = { ... }
-	entryPattern := regexp.MustCompile(`\["([^"]+)"\]\s*=\s*(\{[^}]*(?:\{[^}]*\}[^}]*)*\}|[^,\n]+)`)
+	entryPattern := regexp.MustCompile(`\["(` + luaChar + `+)"\]\s*=\s*(\{[^}]*(?:\{[^}]*\}[^}]*)*\}|[^,\n]+)`)
 	matches := entryPattern.FindAllStringSubmatch(content, -1)
 
 	for _, match := range matches {
 		if len(match) >= 3 {
-			key := match[1]
+			key := unescape(match[1])
 			value := strings.TrimSpace(match[2])
 			value = strings.Trim(value, ",")
 			result[key] = value
@@ -212,7 +363,7 @@ func extractString(content string, keys ...string) string {
 	matches := re.FindStringSubmatch(content)
 
 	if len(matches) >= 2 {
-		return matches[1]
+		return unescape(matches[1])
 	}
 
 	return ""
@@ -220,12 +371,12 @@ func extractString(content string, keys ...string) string {
 
 // extractStringFromContent extracts a string from already isolated content
 func extractStringFromContent(content, key string) string {
-	pattern := fmt.Sprintf(`\["%s"\]\s*=\s*"([^"]*)"`, regexp.QuoteMeta(key))
+	pattern := fmt.Sprintf(`\["%s"\]\s*=\s*"(%s*)"`, regexp.QuoteMeta(key), luaChar)
 	re := regexp.MustCompile(pattern)
 	matches := re.FindStringSubmatch(content)
 
 	if len(matches) >= 2 {
-		return matches[1]
+		return unescape(matches[1])
 	}
 
 	// Try without quotes (for booleans/numbers)
@@ -240,19 +391,40 @@ func extractStringFromContent(content, key string) string {
 	return ""
 }
 
+// luaChar matches a character of a double-quoted Lua string, which may be
+// an escape such as \"
+const luaChar = `(?:[^"\\\n]|\\.)`
+
+// unescape decodes the escapes in the contents of a double-quoted string
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	if tok := newStringLexer(`"`+s+`"`, Limits{}).next(); tok.typ == tokenString {
+		return tok.value
+	}
+	return s
+}
+
+var (
+	charPattern    = regexp.MustCompile(`\["char"\]\s*=\s*\{`)
+	charKeyPattern = regexp.MustCompile(`\["(` + luaChar + `+\s+-\s+` + luaChar + `+)"\]\s*=\s*\{`)
+)
+
 // extractCharSection extracts all character sections
 func extractCharSection(content string) map[string]string {
 	result := make(map[string]string)
 
-	// Find the char section
-	charPattern := regexp.MustCompile(`\["char"\]\s*=\s*\{(.*)\}[\s\n]*\}[\s\n]*$`)
-	charMatches := charPattern.FindStringSubmatch(content)
-
-	if len(charMatches) < 2 {
+	// Find the char section; in a half-written file it runs to the end
+	loc := charPattern.FindStringIndex(content)
+	if loc == nil {
 		return result
 	}
 
-	charContent := charMatches[1]
+	charContent := content[loc[1]:]
+	if end := matchingBrace(charContent); end >= 0 {
+		charContent = charContent[:end]
+	}
 
 	// Extract each character entry
 	scanner := bufio.NewScanner(strings.NewReader(charContent))
@@ -264,12 +436,11 @@ func extractCharSection(content string) map[string]string {
 		line := scanner.Text()
 
 		// Check for character key
-		charKeyPattern := regexp.MustCompile(`\["([^"]+\s+-\s+[^"]+)"\]\s*=\s*\{`)
 		if matches := charKeyPattern.FindStringSubmatch(line); len(matches) >= 2 {
 			if currentChar != "" && currentContent.Len() > 0 {
 				result[currentChar] = currentContent.String()
 			}
-			currentChar = matches[1]
+			currentChar = unescape(matches[1])
 			currentContent.Reset()
 			bracketDepth = 1
 			continue
@@ -297,6 +468,39 @@ func extractCharSection(content string) map[string]string {
 	return result
 }
 
+// matchingBrace returns the offset of the } that closes a table whose
+// contents start at content, or -1 if it isn't closed. Braces in strings
+// and comments don't count.
+func matchingBrace(content string) int {
+	depth := 1
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"', '\'':
+			for i++; i < len(content) && content[i] != c && content[i] != '\n'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+		case '-':
+			if strings.HasPrefix(content[i:], "--") {
+				if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+					i += end
+				} else {
+					i = len(content)
+				}
+			}
+		}
+	}
+	return -1
+}
+
 // buildTablePattern builds a regex pattern for nested table access
 func buildTablePattern(keys ...string) string {
 	pattern := regexp.QuoteMeta(keys[0])
@@ -314,6 +518,6 @@ func buildStringPattern(keys ...string) string {
 		pattern += fmt.Sprintf(`\s*=\s*\{[^}]*\["%s"\]`, regexp.QuoteMeta(keys[i]))
 	}
 	lastKey := keys[len(keys)-1]
-	pattern += fmt.Sprintf(`\s*=\s*\{[^}]*\["%s"\]\s*=\s*"([^"]*)"`, regexp.QuoteMeta(lastKey))
+	pattern += fmt.Sprintf(`\s*=\s*\{[^}]*\["%s"\]\s*=\s*"(%s*)"`, regexp.QuoteMeta(lastKey), luaChar)
 	return pattern
 }
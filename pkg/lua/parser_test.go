@@ -1,8 +1,12 @@
 package lua
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestParseFile(t *testing.T) {
@@ -351,3 +355,357 @@ func TestParseTableContent(t *testing.T) {
 		t.Errorf("key2 = %v, want true", result["key2"])
 	}
 }
+
+func TestParseWithReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantParser string
+		wantLine   int
+		wantColumn int
+	}{
+		{
+			name:       "valid profile file",
+			file:       "valid_profile.lua",
+			wantParser: ParserSimple,
+		},
+		{
+			name:       "malformed file",
+			file:       "malformed.lua",
+			wantParser: ParserRegex,
+			wantLine:   4,
+			wantColumn: 14,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, report, err := ParseFileWithReport(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseFileWithReport() error = %v", err)
+			}
+
+			if report.Parser != tt.wantParser {
+				t.Errorf("Parser = %v, want %v", report.Parser, tt.wantParser)
+			}
+			if report.Recovered() != (tt.wantLine != 0) {
+				t.Fatalf("Errors = %v, want recovered %v", report.Errors, tt.wantLine != 0)
+			}
+			if tt.wantLine == 0 {
+				return
+			}
+
+			parseErr, ok := report.Errors[0].(*ParseError)
+			if !ok {
+				t.Fatalf("Errors[0] = %v, want *ParseError", report.Errors[0])
+			}
+			if parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("position = %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+}
+
+func TestParseRegexCharacters(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "valid_profile.lua"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "complete file", content: string(content)},
+		{name: "trailing data", content: string(content) + "\nOtherDB = {\n}\n"},
+		{name: "half-written file", content: string(content[:strings.Index(string(content), `["PvP"]`)+200])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := parseRegex(tt.content)
+			if err != nil {
+				t.Fatalf("parseRegex() error = %v", err)
+			}
+
+			charData, ok := db.Char["TestChar - TestRealm"]
+			if !ok {
+				t.Fatalf("Char = %v, want TestChar - TestRealm", db.Char)
+			}
+			if _, ok := charData.Profiles["PvP"]; !ok {
+				t.Errorf("Profiles = %v, want PvP", charData.Profiles)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	// Without a depth limit this would recurse a million times
+	deep := "AddonProfilesDB = " + strings.Repeat("{", 1<<20)
+	if _, err := ParseSimple(deep); err == nil || !strings.Contains(err.Error(), "nested more than 200 deep") {
+		t.Errorf("ParseSimple() error = %v, want nesting error", err)
+	}
+
+	limits := Limits{MaxDepth: 3, MaxSize: 64}
+
+	nested := "AddonProfilesDB = {{{{}}}}"
+	for name, parse := range map[string]func(string) error{
+		"ParseChunk": func(s string) error { _, err := parseChunk(newStringLexer(s, limits), nil); return err },
+		"ParseGlobal": func(s string) error {
+			_, _, err := parseGlobal(newLexer(strings.NewReader(s), limits), "Other")
+			return err
+		},
+		"ParseDocument": func(s string) error { _, err := parseDocument(s, limits); return err },
+		"ParseWithOptions": func(s string) error {
+			_, _, err := ParseWithOptions(s, ParseOptions{Mode: ParseStrict, Limits: limits})
+			return err
+		},
+	} {
+		err := parse(nested)
+		if parseErr, ok := err.(*ParseError); !ok || !strings.Contains(parseErr.Message, "nested") {
+			t.Errorf("%s() error = %v, want nesting error", name, err)
+		}
+		if err := parse("AddonProfilesDB = {{{}}}"); err != nil {
+			t.Errorf("%s() error = %v at the limit", name, err)
+		}
+	}
+
+	large := "AddonProfilesDB = {\n" + strings.Repeat("\t\"padding\",\n", 10) + "}\n"
+	if _, err := parseChunk(newStringLexer(large, limits), nil); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("ParseChunk() error = %v, want %v", err, ErrTooLarge)
+	}
+	if _, err := parseChunk(newLexer(iotest.OneByteReader(strings.NewReader(large)), limits), nil); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("ParseChunkReader() error = %v, want %v", err, ErrTooLarge)
+	}
+	if _, report, err := ParseWithOptions(large, ParseOptions{Limits: limits}); !errors.Is(err, ErrTooLarge) || report.Parser != ParserSimple {
+		t.Errorf("ParseWithOptions() = %v, %v; want %v without fallback", report.Parser, err, ErrTooLarge)
+	}
+
+	// Negative limits turn the checks off
+	if _, err := parseChunk(newStringLexer(large, Limits{MaxDepth: -1, MaxSize: -1}), nil); err != nil {
+		t.Errorf("ParseChunk() without limits error = %v", err)
+	}
+}
+
+func TestParseUnicodeNames(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "unicode_names.lua"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	db, err := ParseSimple(string(content))
+	if err != nil {
+		t.Fatalf("ParseSimple() error = %v", err)
+	}
+
+	quoted := `Raid "Mythic" \ M+`
+	if db.Global.ActiveProfile != quoted {
+		t.Errorf("ActiveProfile = %q, want %q", db.Global.ActiveProfile, quoted)
+	}
+	for _, name := range []string{"Ærïs", "Пётр", "가나다", quoted} {
+		profile, ok := db.Global.Profiles[name]
+		if !ok || !profile.Addons[name] {
+			t.Errorf("profile %q = %+v, want it with addon %q", name, profile, name)
+		}
+	}
+
+	chars := map[string]string{
+		"Ærïs - Argent Dawn": "Ærïs",
+		"Пётр - Гордунни":    "Пётр",
+		"가나다 - 아즈샤라":         "가나다",
+	}
+	for key, active := range chars {
+		if db.Char[key].ActiveProfile != active {
+			t.Errorf("character %q = %+v, want active profile %q", key, db.Char[key], active)
+		}
+	}
+}
+
+func TestParseRegexUnicodeNames(t *testing.T) {
+	// The regex parser only reads the first of several global profiles and
+	// doesn't follow addon tables nested this deep, so this checks the
+	// active profiles and the names of character profiles
+	content := `AddonProfilesDB = {
+	["global"] = {
+		["activeProfile"] = "Raid \"Mythic\" \\ M+",
+	},
+	["char"] = {
+		["Ærïs - Argent Dawn"] = {
+			["activeProfile"] = "Ærïs",
+			["profiles"] = {
+				["Ærïs"] = {
+					["addons"] = {
+						["Ærïs"] = true,
+					},
+				},
+			},
+		},
+		["Пётр - Гордунни"] = {
+			["activeProfile"] = "Пётр",
+			["profiles"] = {
+				["Пётр"] = {
+					["addons"] = {
+						["Пётр"] = true,
+					},
+				},
+			},
+		},
+		["가나다 - 아즈샤라"] = {
+			["activeProfile"] = "가나다",
+			["profiles"] = {
+				["가나다"] = {
+					["addons"] = {
+						["가나다"] = true,
+					},
+				},
+			},
+		},
+	},
+}
+`
+
+	db, err := parseRegex(content)
+	if err != nil {
+		t.Fatalf("parseRegex() error = %v", err)
+	}
+
+	quoted := `Raid "Mythic" \ M+`
+	if db.Global.ActiveProfile != quoted {
+		t.Errorf("ActiveProfile = %q, want %q", db.Global.ActiveProfile, quoted)
+	}
+
+	chars := map[string]string{
+		"Ærïs - Argent Dawn": "Ærïs",
+		"Пётр - Гордунни":    "Пётр",
+		"가나다 - 아즈샤라":         "가나다",
+	}
+	for key, active := range chars {
+		charData := db.Char[key]
+		if charData.ActiveProfile != active {
+			t.Errorf("character %q = %+v, want active profile %q", key, charData, active)
+		}
+		if _, ok := charData.Profiles[active]; !ok {
+			t.Errorf("character %q profiles = %v, want %q", key, charData.Profiles, active)
+		}
+	}
+}
+
+func TestParseWithReportWrongType(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantPath string
+	}{
+		{
+			name:     "autoDeps string",
+			input:    `AddonProfilesDB = { global = { profiles = { Raid = { autoDeps = "yes", addons = { DBM = true } } } } }`,
+			wantPath: "global.profiles.Raid.autoDeps",
+		},
+		{
+			name:     "addons number",
+			input:    `AddonProfilesDB = { global = { profiles = { Raid = { addons = 5 } } } }`,
+			wantPath: "global.profiles.Raid.addons",
+		},
+		{
+			name:     "addon state string",
+			input:    `AddonProfilesDB = { char = { ["A - B"] = { profiles = { Raid = { addons = { DBM = "on" } } } } } }`,
+			wantPath: `char.A - B.profiles.Raid.addons.DBM`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, report, err := ParseWithReport(tt.input)
+			if err != nil {
+				t.Fatalf("ParseWithReport() error = %v", err)
+			}
+			if db == nil || report.Parser != ParserSimple {
+				t.Fatalf("ParseWithReport() = %v, %+v, want simple parser result", db, report)
+			}
+			if report.Complete() {
+				t.Fatal("Complete() = true, want false")
+			}
+
+			typeErr, ok := report.Errors[0].(*UnmarshalTypeError)
+			if !ok {
+				t.Fatalf("Errors[0] = %v, want *UnmarshalTypeError", report.Errors[0])
+			}
+			if typeErr.Path != tt.wantPath {
+				t.Errorf("Path = %q, want %q", typeErr.Path, tt.wantPath)
+			}
+		})
+	}
+
+	_, report, err := ParseWithReport(`AddonProfilesDB = { global = { profiles = { Raid = { autoDeps = true } } } }`)
+	if err != nil || !report.Complete() {
+		t.Errorf("ParseWithReport() of valid file = %+v, %v, want complete", report, err)
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		opts          ParseOptions
+		wantParser    string
+		wantErr       bool
+		wantDisagrees []string
+	}{
+		{name: "strict valid", file: "valid_profile.lua", opts: ParseOptions{Mode: ParseStrict}, wantParser: ParserSimple},
+		{name: "strict malformed", file: "malformed.lua", opts: ParseOptions{Mode: ParseStrict}, wantParser: ParserSimple, wantErr: true},
+		{name: "lenient malformed", file: "malformed.lua", wantParser: ParserRegex},
+		{name: "compare agreeing", file: "empty_profile.lua", opts: ParseOptions{Compare: true}, wantParser: ParserSimple},
+		{
+			name:       "compare disagreeing",
+			file:       "valid_profile.lua",
+			opts:       ParseOptions{Compare: true},
+			wantParser: ParserSimple,
+			wantDisagrees: []string{
+				"global.profiles.Raiding: simple parser read present, regex parser read missing",
+				`char["TestChar - TestRealm"].profiles.PvP.autoDeps: simple parser read false, regex parser read true`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			db, report, err := ParseWithOptions(string(content), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (db == nil) != tt.wantErr {
+				t.Errorf("ParseWithOptions() db = %v, wantErr %v", db, tt.wantErr)
+			}
+			if report.Parser != tt.wantParser {
+				t.Errorf("Parser = %v, want %v", report.Parser, tt.wantParser)
+			}
+			if report.Recovered() != (tt.wantParser == ParserRegex) {
+				t.Errorf("Recovered() = %v, want %v", report.Recovered(), tt.wantParser == ParserRegex)
+			}
+
+			got := make(map[string]bool)
+			for _, d := range report.Disagreements {
+				got[d.String()] = true
+			}
+			for _, want := range tt.wantDisagrees {
+				if !got[want] {
+					t.Errorf("Disagreements = %v, want %q", report.Disagreements, want)
+				}
+			}
+			if len(tt.wantDisagrees) == 0 && len(report.Disagreements) > 0 {
+				t.Errorf("Disagreements = %v, want none", report.Disagreements)
+			}
+
+			// Files give the same result
+			_, fileReport, err := ParseFileWithOptions(filepath.Join("testdata", tt.file), tt.opts)
+			if (err != nil) != tt.wantErr || fileReport.Parser != report.Parser || len(fileReport.Disagreements) != len(report.Disagreements) {
+				t.Errorf("ParseFileWithOptions() = %+v, %v; want %+v", fileReport, err, report)
+			}
+		})
+	}
+}
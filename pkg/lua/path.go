@@ -0,0 +1,141 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Path addresses a value in a SavedVariables file: the global name
+// followed by table keys, e.g.
+//
+//	AddonProfilesDB.global.profiles["Raid"].addons["WeakAuras"]
+type Path []Value
+
+// NewPath creates a path of string keys
+func NewPath(keys ...string) Path {
+	path := make(Path, len(keys))
+	for i, key := range keys {
+		path[i] = String(key)
+	}
+	return path
+}
+
+// ParsePath parses a path written as Lua indexing: names joined by dots
+// and keys in brackets, such as ["Raid"], [1] or [true]
+func ParsePath(s string) (Path, error) {
+	var path Path
+
+	for pos := 0; pos < len(s); {
+		ch := s[pos]
+
+		switch {
+		case ch == '.' && len(path) > 0:
+			pos++
+			if nameLen(s[pos:], true) == 0 {
+				return nil, fmt.Errorf("invalid path %q: expected name after '.'", s)
+			}
+		case nameLen(s[pos:], true) > 0:
+			if len(path) > 0 && s[pos-1] != '.' {
+				return nil, fmt.Errorf("invalid path %q: expected '.' or '[' at %d", s, pos)
+			}
+			start := pos
+			for pos < len(s) {
+				n := nameLen(s[pos:], pos == start)
+				if n == 0 {
+					break
+				}
+				pos += n
+			}
+			path = append(path, String(s[start:pos]))
+		case ch == '[' && len(path) > 0:
+			pos++
+			key, n, err := parsePathKey(s[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", s, err)
+			}
+			pos += n
+			if pos >= len(s) || s[pos] != ']' {
+				return nil, fmt.Errorf("invalid path %q: expected ']' at %d", s, pos)
+			}
+			pos++
+			path = append(path, key)
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q at %d", s, ch, pos)
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid path %q: empty", s)
+	}
+	return path, nil
+}
+
+// parsePathKey parses the key at the start of s, the part of a path after
+// a '[', and returns it with its length
+func parsePathKey(s string) (Value, int, error) {
+	tok := newStringLexer(s, Limits{}).next()
+	if tok.offset != 0 {
+		return nil, 0, fmt.Errorf("expected key")
+	}
+
+	switch tok.typ {
+	case tokenString:
+		return String(tok.value), tok.end, nil
+	case tokenNumber:
+		n, err := parseNumber(tok.value)
+		if err != nil {
+			return nil, 0, err
+		}
+		return Number(n), tok.end, nil
+	case tokenBool:
+		return Bool(tok.value == "true"), tok.end, nil
+	case tokenIllegal:
+		return nil, 0, fmt.Errorf("%s", tok.value)
+	default:
+		return nil, 0, fmt.Errorf("invalid key %s", tok.describe())
+	}
+}
+
+// String formats the path as ParsePath reads it
+func (p Path) String() string {
+	var b strings.Builder
+	for i, key := range p {
+		if s, ok := key.(String); ok && isName(string(s)) {
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(string(s))
+			continue
+		}
+
+		b.WriteByte('[')
+		switch key := key.(type) {
+		case String:
+			b.WriteString(quoteString(string(key)))
+		default:
+			b.WriteString(keyString(key))
+		}
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+// isName reports whether s can be written as a bare Lua name. Only ASCII
+// names are, so standard Lua can read what is written.
+func isName(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i]) && !isDigit(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	switch s {
+	case "and", "break", "do", "else", "elseif", "end", "false", "for", "function",
+		"if", "in", "local", "nil", "not", "or", "repeat", "return", "then", "true",
+		"until", "while":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package lua
+
+// fillDefaults fills in the fields older versions of the addon didn't save,
+// so decoding only has to know the current layout: a missing autoDeps
+// means true, and a missing scope follows from where the profile is stored
+func fillDefaults(db *Table) {
+	eachProfile(db, func(profile *Table, scope string) {
+		if profile.Field("autoDeps") == nil {
+			profile.Set(String("autoDeps"), Bool(true))
+		}
+		if profile.Field("scope") == nil {
+			profile.Set(String("scope"), String(scope))
+		}
+	})
+}
+
+// eachProfile calls fn with every profile table of a database and the
+// scope of where it is stored
+func eachProfile(db *Table, fn func(profile *Table, scope string)) {
+	profiles := func(owner Value, scope string) {
+		table, ok := owner.(*Table)
+		if !ok {
+			return
+		}
+		list, ok := table.Field("profiles").(*Table)
+		if !ok {
+			return
+		}
+		for _, field := range list.Fields() {
+			if profile, ok := field.Value.(*Table); ok {
+				fn(profile, scope)
+			}
+		}
+	}
+
+	profiles(db.Field("global"), "account")
+	if chars, ok := db.Field("char").(*Table); ok {
+		for _, field := range chars.Fields() {
+			profiles(field.Value, "character")
+		}
+	}
+}
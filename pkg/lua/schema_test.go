@@ -0,0 +1,91 @@
+package lua
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantScope string
+		wantDeps  bool
+	}{
+		{
+			name:      "profile without autoDeps and scope",
+			input:     `AddonProfilesDB = { char = { ["A - B"] = { profiles = { Raid = { addons = {} } } } } }`,
+			wantScope: "character",
+			wantDeps:  true,
+		},
+		{
+			name:      "scope read from the file",
+			input:     `AddonProfilesDB = { char = { ["A - B"] = { profiles = { Raid = { scope = "account", autoDeps = false } } } } }`,
+			wantScope: "account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := ParseSimple(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSimple() error = %v", err)
+			}
+
+			profile := db.Char["A - B"].Profiles["Raid"]
+			if profile == nil {
+				t.Fatal("profile Raid missing")
+			}
+			if profile.Scope != tt.wantScope {
+				t.Errorf("Scope = %v, want %v", profile.Scope, tt.wantScope)
+			}
+			if profile.AutoDeps != tt.wantDeps {
+				t.Errorf("AutoDeps = %v, want %v", profile.AutoDeps, tt.wantDeps)
+			}
+		})
+	}
+}
+
+func TestParseUnknownFields(t *testing.T) {
+	path := filepath.Join("testdata", "unknown_fields.lua")
+	db, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	if db.Global.Profiles["Default"] == nil || db.Char["TestChar - TestRealm"].Profiles["PvP"] == nil {
+		t.Fatalf("profiles = %v, %v; want Default and PvP", db.Global.Profiles, db.Char)
+	}
+	if db.Global.Settings == nil || !db.Global.Settings.HideDefaultAddonsButton {
+		t.Errorf("Settings = %+v, want hideDefaultAddonsButton", db.Global.Settings)
+	}
+
+	// Fields this package doesn't know are kept
+	if db.Global.Profiles["Default"].Extra["icon"] == nil {
+		t.Errorf("Default Extra = %v, want icon", db.Global.Profiles["Default"].Extra)
+	}
+	if db.Global.Settings.Extra["minimap"] == nil {
+		t.Errorf("Settings Extra = %v, want minimap", db.Global.Settings.Extra)
+	}
+	if db.Global.Extra["sharedWith"] == nil {
+		t.Errorf("Global Extra = %v, want sharedWith", db.Global.Extra)
+	}
+	if db.Extra["syncedAt"] == nil {
+		t.Errorf("Extra = %v, want syncedAt", db.Extra)
+	}
+
+	// and written back as they were
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, db); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := buf.String(); got != "\n"+string(want) {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
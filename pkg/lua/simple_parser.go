@@ -1,12 +1,18 @@
 package lua
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Simple recursive descent parser for WoW SavedVariables format
-// This is specifically tailored to our AddonProfilesDB structure
+// Simple recursive descent parser for WoW SavedVariables format.
+// It covers the data subset of Lua that WoW writes: nil, booleans,
+// numbers, strings and table constructors with any kind of field.
 
 type tokenType int
 
@@ -23,130 +29,676 @@ const (
 	tokenBool
 	tokenNil
 	tokenIdent
+	tokenSemicolon
+	tokenIllegal // value holds the reason
 )
 
+// tokenNames are the names of token types used in error messages
+var tokenNames = map[tokenType]string{
+	tokenEOF:       "end of file",
+	tokenLBrace:    "'{'",
+	tokenRBrace:    "'}'",
+	tokenLBracket:  "'['",
+	tokenRBracket:  "']'",
+	tokenComma:     "','",
+	tokenEquals:    "'='",
+	tokenString:    "string",
+	tokenNumber:    "number",
+	tokenBool:      "boolean",
+	tokenNil:       "nil",
+	tokenIdent:     "name",
+	tokenSemicolon: "';'",
+	tokenIllegal:   "invalid token",
+}
+
+func (t tokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
 type token struct {
 	typ   tokenType
 	value string
+
+	// Position of the first byte; line and col are 1-based, col counts bytes
+	offset int
+	line   int
+	col    int
+	// end is the offset just past the token
+	end int
+}
+
+// describe returns the token as shown in error messages, e.g. string "abc"
+func (t token) describe() string {
+	switch t.typ {
+	case tokenString:
+		value := t.value
+		if len(value) > 20 {
+			value = value[:20] + "..."
+		}
+		return fmt.Sprintf("string %q", value)
+	case tokenNumber, tokenIdent:
+		return fmt.Sprintf("%v %s", t.typ, t.value)
+	default:
+		return t.typ.String()
+	}
 }
 
+// readSize is how much input the lexer reads at a time
+const readSize = 64 * 1024
+
+// lexer turns its input into tokens one at a time, so only a window of a
+// large SavedVariables file is held in memory
 type lexer struct {
-	input  string
-	pos    int
-	tokens []token
+	r   io.Reader
+	err error // the error that ended reading, io.EOF at the end
+
+	// buf holds the input from offset base on. Bytes before keep are
+	// dropped when more input is read.
+	buf  []byte
+	base int
+	keep int
+
+	pos int
+	tok token // the last token lexed
+
+	// start is the offset of the token being lexed
+	start int
+	// line and lineStart track positions up to scanned
+	line      int
+	lineStart int
+	scanned   int
+	// recent are the line starts of the last tokens; the parser may still
+	// report an error on them, so their lines are kept for the excerpt
+	recent [3]int
+
+	// skip leaves token values out, for values that are skipped unread
+	skip bool
+
+	limits Limits
 }
 
-func newLexer(input string) *lexer {
-	return &lexer{
-		input: input,
-		pos:   0,
+// newLexer returns a lexer that reads from r within limits
+func newLexer(r io.Reader, limits Limits) *lexer {
+	return &lexer{r: r, line: 1, limits: limits.withDefaults()}
+}
+
+// newStringLexer returns a lexer for input that is already in memory
+func newStringLexer(input string, limits Limits) *lexer {
+	l := &lexer{err: io.EOF, line: 1, limits: limits.withDefaults()}
+	if max := l.limits.MaxSize; max > 0 && len(input) > max {
+		l.err = ErrTooLarge
+		return l
 	}
+	l.buf = []byte(input)
+	return l
 }
 
-func (l *lexer) lex() []token {
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
+// at returns the byte at offset i, reading more input as needed. It
+// reports false past the end of the input.
+func (l *lexer) at(i int) (byte, bool) {
+	for i-l.base >= len(l.buf) {
+		if !l.fill() {
+			return 0, false
+		}
+	}
+	return l.buf[i-l.base], true
+}
+
+// fill reads more input and reports whether there was any
+func (l *lexer) fill() bool {
+	if l.err != nil {
+		return false
+	}
+
+	// Make room by dropping what is no longer needed, or else grow
+	if drop := l.keep - l.base; drop > 0 && drop >= len(l.buf)/2 {
+		n := copy(l.buf, l.buf[drop:])
+		l.buf = l.buf[:n]
+		l.base = l.keep
+	}
+	if cap(l.buf)-len(l.buf) < readSize/2 {
+		buf := make([]byte, len(l.buf), 2*cap(l.buf)+readSize)
+		copy(buf, l.buf)
+		l.buf = buf
+	}
+
+	for {
+		n, err := l.r.Read(l.buf[len(l.buf):cap(l.buf)])
+		if max := l.limits.MaxSize; max > 0 && l.base+len(l.buf)+n > max {
+			l.err = ErrTooLarge
+			return false
+		}
+		l.buf = l.buf[:len(l.buf)+n]
+		if err != nil {
+			l.err = err
+		}
+		if n > 0 {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// text returns the input from start to the current position
+func (l *lexer) text(start int) string {
+	return string(l.buf[start-l.base : l.pos-l.base])
+}
+
+// next lexes the next token. At the end of the input, or when reading
+// fails, it returns EOF tokens.
+func (l *lexer) next() token {
+	for {
+		ch, ok := l.at(l.pos)
+		if !ok {
+			break
+		}
+		l.start = l.pos
 
 		// Skip whitespace and comments
-		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' {
+		if isSpace(ch) {
 			l.pos++
 			continue
 		}
 
-		if ch == '-' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '-' {
-			// Skip comment
-			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
-				l.pos++
+		if ch == '-' && l.peekByte(1) == '-' {
+			if l.skipComment() {
+				return l.tok
 			}
 			continue
 		}
 
 		switch ch {
 		case '{':
-			l.tokens = append(l.tokens, token{tokenLBrace, "{"})
 			l.pos++
+			l.emit(tokenLBrace, "{")
 		case '}':
-			l.tokens = append(l.tokens, token{tokenRBrace, "}"})
 			l.pos++
+			l.emit(tokenRBrace, "}")
 		case '[':
-			l.tokens = append(l.tokens, token{tokenLBracket, "["})
-			l.pos++
+			if next := l.peekByte(1); next == '[' || next == '=' {
+				l.lexLongString()
+			} else {
+				l.pos++
+				l.emit(tokenLBracket, "[")
+			}
 		case ']':
-			l.tokens = append(l.tokens, token{tokenRBracket, "]"})
 			l.pos++
+			l.emit(tokenRBracket, "]")
 		case ',':
-			l.tokens = append(l.tokens, token{tokenComma, ","})
 			l.pos++
+			l.emit(tokenComma, ",")
+		case ';':
+			l.pos++
+			l.emit(tokenSemicolon, ";")
 		case '=':
-			l.tokens = append(l.tokens, token{tokenEquals, "="})
 			l.pos++
-		case '"':
+			l.emit(tokenEquals, "=")
+		case '"', '\'':
 			l.lexString()
 		default:
-			if isDigit(ch) || (ch == '-' && l.pos+1 < len(l.input) && isDigit(l.input[l.pos+1])) {
+			if isDigit(ch) || (ch == '.' && isDigit(l.peekByte(1))) ||
+				(ch == '-' && (isDigit(l.peekByte(1)) || (l.peekByte(1) == '.' && isDigit(l.peekByte(2))))) {
 				l.lexNumber()
-			} else if isAlpha(ch) {
+			} else if isAlpha(ch) || ch == '_' || ch >= utf8.RuneSelf {
 				l.lexIdent()
 			} else {
 				l.pos++
+				l.emit(tokenIllegal, fmt.Sprintf("unexpected character %q", ch))
 			}
 		}
+		return l.tok
 	}
 
-	l.tokens = append(l.tokens, token{tokenEOF, ""})
-	return l.tokens
+	l.start = l.pos
+	l.emit(tokenEOF, "")
+	return l.tok
+}
+
+// readError returns the error that ended the input early, if any
+func (l *lexer) readError() error {
+	switch l.err {
+	case nil, io.EOF:
+		return nil
+	case ErrTooLarge:
+		return l.limits.tooLarge()
+	default:
+		return fmt.Errorf("failed to read input: %w", l.err)
+	}
+}
+
+// emit sets the token that starts at l.start and ends at l.pos
+func (l *lexer) emit(typ tokenType, value string) {
+	if skipped := l.buf[l.scanned-l.base : l.start-l.base]; len(skipped) > 0 {
+		if n := bytes.Count(skipped, []byte{'\n'}); n > 0 {
+			l.line += n
+			l.lineStart = l.scanned + bytes.LastIndexByte(skipped, '\n') + 1
+		}
+		l.scanned = l.start
+	}
+
+	l.keep = l.recent[0]
+	copy(l.recent[:], l.recent[1:])
+	l.recent[len(l.recent)-1] = l.lineStart
+
+	l.tok = token{
+		typ:    typ,
+		value:  value,
+		offset: l.start,
+		line:   l.line,
+		col:    l.start - l.lineStart + 1,
+		end:    l.pos,
+	}
+}
+
+// peekByte returns the byte offset bytes ahead, or 0 past the end
+func (l *lexer) peekByte(offset int) byte {
+	ch, _ := l.at(l.pos + offset)
+	return ch
+}
+
+// excerpt returns the excerpt shown for an error at offset
+func (l *lexer) excerpt(offset int) string {
+	if offset < l.base {
+		return ""
+	}
+
+	// Read the rest of the line as far as it is shown, keeping the start
+	l.keep = l.base
+	for i := offset; i < offset+maxExcerpt; i++ {
+		if ch, ok := l.at(i); !ok || ch == '\n' {
+			break
+		}
+	}
+	return excerpt(string(l.buf), offset-l.base)
+}
+
+// skipComment skips a -- line comment or a --[[ block ]] comment and
+// reports whether it emitted an error token
+func (l *lexer) skipComment() bool {
+	start := l.pos
+	l.pos += 2 // skip --
+
+	if l.peekByte(0) == '[' {
+		if level, ok := l.longBracketLevel(); ok {
+			if !l.readLongBracket(level) {
+				l.start = start
+				l.emit(tokenIllegal, "unfinished long comment")
+				return true
+			}
+			return false
+		}
+	}
+
+	for {
+		if ch, ok := l.at(l.pos); !ok || ch == '\n' {
+			return false
+		}
+		l.pos++
+	}
+}
+
+// longBracketLevel reports whether a long bracket such as [[ or [==[
+// starts at the current position, and its level (the number of =)
+func (l *lexer) longBracketLevel() (int, bool) {
+	level := 0
+	for l.peekByte(1+level) == '=' {
+		level++
+	}
+	return level, l.peekByte(1+level) == '['
+}
+
+// readLongBracket consumes a long bracket of the given level, leaving
+// l.start at its contents. A newline right after the opening bracket is
+// skipped.
+func (l *lexer) readLongBracket(level int) bool {
+	l.pos += level + 2 // skip [==[
+
+	if l.peekByte(0) == '\r' && l.peekByte(1) == '\n' {
+		l.pos += 2
+	} else if l.peekByte(0) == '\n' {
+		l.pos++
+	}
+	l.start = l.pos
+
+	for {
+		ch, ok := l.at(l.pos)
+		if !ok {
+			return false
+		}
+		l.pos++
+		if ch != ']' {
+			continue
+		}
+
+		n := 0
+		for n < level && l.peekByte(n) == '=' {
+			n++
+		}
+		if n == level && l.peekByte(n) == ']' {
+			l.pos += level + 1
+			return true
+		}
+	}
+}
+
+func (l *lexer) lexLongString() {
+	level, ok := l.longBracketLevel()
+	if !ok {
+		l.pos++
+		l.emit(tokenIllegal, "invalid long string delimiter")
+		return
+	}
+
+	start := l.start
+	if !l.readLongBracket(level) {
+		l.start = start
+		l.emit(tokenIllegal, "unfinished long string")
+		return
+	}
+
+	var value string
+	if !l.skip {
+		value = string(l.buf[l.start-l.base : l.pos-level-2-l.base])
+	}
+	l.start = start
+	l.emit(tokenString, value)
 }
 
 func (l *lexer) lexString() {
+	quote, _ := l.at(l.pos)
 	l.pos++ // skip opening quote
-	start := l.pos
 
-	for l.pos < len(l.input) && l.input[l.pos] != '"' {
-		if l.input[l.pos] == '\\' {
-			l.pos++ // skip escape
+	if l.skip {
+		l.skipString(quote)
+		return
+	}
+
+	var b strings.Builder
+	for {
+		ch, ok := l.at(l.pos)
+		if !ok || ch == '\n' {
+			l.emit(tokenIllegal, "unfinished string")
+			return
+		}
+
+		if ch == quote {
+			l.pos++ // skip closing quote
+			break
+		}
+
+		if ch != '\\' {
+			b.WriteByte(ch)
+			l.pos++
+			continue
+		}
+
+		escape := l.pos
+		l.pos++ // skip backslash
+		if _, ok := l.at(l.pos); !ok {
+			l.emit(tokenIllegal, "unfinished string")
+			return
+		}
+
+		if !l.decodeEscape(&b) {
+			l.start = escape
+			l.emit(tokenIllegal, fmt.Sprintf("invalid escape sequence %s", l.text(escape)))
+			return
+		}
+	}
+
+	l.emit(tokenString, b.String())
+}
+
+// skipString finds the end of a string without decoding it. Escapes are
+// only checked as far as needed to find the closing quote.
+func (l *lexer) skipString(quote byte) {
+	for {
+		ch, ok := l.at(l.pos)
+		if !ok || ch == '\n' {
+			l.emit(tokenIllegal, "unfinished string")
+			return
+		}
+		l.pos++
+
+		switch ch {
+		case quote:
+			l.emit(tokenString, "")
+			return
+		case '\\':
+			// Skip the escaped character, or both bytes of an escaped \r\n,
+			// or \z and the whitespace after it
+			switch l.peekByte(0) {
+			case 'z':
+				l.pos++
+				for isSpace(l.peekByte(0)) {
+					l.pos++
+				}
+			case '\r':
+				if l.peekByte(1) == '\n' {
+					l.pos++
+				}
+				l.pos++
+			default:
+				l.pos++
+			}
+		}
+	}
+}
+
+// decodeEscape decodes the escape sequence after a backslash, covering the
+// escapes of Lua 5.1 that WoW uses and the \x, \z and \u{...} of later
+// versions. On failure l.pos is left after the invalid sequence.
+func (l *lexer) decodeEscape(b *strings.Builder) bool {
+	ch := l.peekByte(0)
+
+	switch ch {
+	case 'a':
+		b.WriteByte('\a')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'v':
+		b.WriteByte('\v')
+	case '\\', '"', '\'':
+		b.WriteByte(ch)
+	case '\n':
+		// An escaped line break continues the string on the next line
+		b.WriteByte('\n')
+		if l.peekByte(1) == '\r' {
+			l.pos++
+		}
+	case '\r':
+		b.WriteByte('\n')
+		if l.peekByte(1) == '\n' {
+			l.pos++
+		}
+	case 'x':
+		// \xXX: exactly two hexadecimal digits
+		l.pos++
+		for i := 0; i < 2; i++ {
+			if !isHexDigit(l.peekByte(i)) {
+				l.pos += i
+				return false
+			}
+		}
+		b.WriteByte(hexValue(l.peekByte(0))<<4 | hexValue(l.peekByte(1)))
+		l.pos += 2
+		return true
+	case 'z':
+		// \z skips the whitespace that follows, line breaks included
+		for isSpace(l.peekByte(1)) {
+			l.pos++
+		}
+	case 'u':
+		return l.decodeUnicode(b)
+	default:
+		if !isDigit(ch) {
+			l.pos++
+			return false
+		}
+
+		// \ddd: up to three decimal digits
+		value := 0
+		for digits := 0; digits < 3 && isDigit(l.peekByte(0)); digits++ {
+			value = value*10 + int(l.peekByte(0)-'0')
+			l.pos++
+		}
+		if value > 255 {
+			return false
+		}
+		b.WriteByte(byte(value))
+		return true
+	}
+
+	l.pos++
+	return true
+}
+
+// decodeUnicode decodes a \u{XXX} escape, the code point in hexadecimal,
+// as UTF-8
+func (l *lexer) decodeUnicode(b *strings.Builder) bool {
+	l.pos++ // skip u
+	if l.peekByte(0) != '{' {
+		return false
+	}
+	l.pos++
+
+	value, digits := 0, 0
+	for ; isHexDigit(l.peekByte(0)); digits++ {
+		// Stop growing once too large, but consume the digits
+		if value <= unicode.MaxRune {
+			value = value<<4 | int(hexValue(l.peekByte(0)))
 		}
 		l.pos++
 	}
+	if l.peekByte(0) != '}' {
+		return false
+	}
+	l.pos++
 
-	value := l.input[start:l.pos]
-	l.pos++ // skip closing quote
-	l.tokens = append(l.tokens, token{tokenString, value})
+	// Surrogates and values past U+10FFFF have no valid UTF-8 encoding
+	if digits == 0 || value > unicode.MaxRune || !utf8.ValidRune(rune(value)) {
+		return false
+	}
+	b.WriteRune(rune(value))
+	return true
 }
 
 func (l *lexer) lexNumber() {
 	start := l.pos
 
-	if l.input[l.pos] == '-' {
+	if l.peekByte(0) == '-' {
 		l.pos++
 	}
 
-	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
-		l.pos++
+	if l.peekByte(0) == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') {
+		l.pos += 2
+		for isHexDigit(l.peekByte(0)) {
+			l.pos++
+		}
+	} else {
+		for isDigit(l.peekByte(0)) || l.peekByte(0) == '.' {
+			l.pos++
+		}
+
+		if l.peekByte(0) == 'e' || l.peekByte(0) == 'E' {
+			l.pos++
+			if l.peekByte(0) == '+' || l.peekByte(0) == '-' {
+				l.pos++
+			}
+			for isDigit(l.peekByte(0)) {
+				l.pos++
+			}
+		}
 	}
 
-	value := l.input[start:l.pos]
-	l.tokens = append(l.tokens, token{tokenNumber, value})
+	if l.skip {
+		l.emit(tokenNumber, "")
+		return
+	}
+
+	value := l.text(start)
+	if _, err := parseNumber(value); err != nil {
+		l.emit(tokenIllegal, fmt.Sprintf("malformed number %q", value))
+		return
+	}
+	l.emit(tokenNumber, value)
+}
+
+// parseNumber converts a decimal or hexadecimal Lua number
+func parseNumber(text string) (float64, error) {
+	digits := strings.TrimPrefix(text, "-")
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		n, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil {
+			return 0, err
+		}
+		if digits != text {
+			return -float64(n), nil
+		}
+		return float64(n), nil
+	}
+
+	// strconv accepts forms Lua doesn't, like "inf" and "1_000"
+	for i := 0; i < len(digits); i++ {
+		if c := digits[i]; !isDigit(c) && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			return 0, fmt.Errorf("invalid number")
+		}
+	}
+	return strconv.ParseFloat(text, 64)
 }
 
 func (l *lexer) lexIdent() {
 	start := l.pos
 
-	for l.pos < len(l.input) && (isAlpha(l.input[l.pos]) || isDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
-		l.pos++
+	for {
+		l.at(l.pos + utf8.UTFMax - 1) // read the whole character
+		rest := l.buf[l.pos-l.base : min(l.pos-l.base+utf8.UTFMax, len(l.buf))]
+		n := nameLen(string(rest), l.pos == start)
+		if n == 0 {
+			break
+		}
+		l.pos += n
 	}
 
-	value := l.input[start:l.pos]
+	if l.pos == start {
+		r, size := utf8.DecodeRune(l.buf[l.pos-l.base:])
+		if r == utf8.RuneError {
+			l.pos++
+			l.emit(tokenIllegal, fmt.Sprintf("invalid UTF-8 byte %#x", l.buf[start-l.base]))
+			return
+		}
+		l.pos += size
+		l.emit(tokenIllegal, fmt.Sprintf("unexpected character %q", r))
+		return
+	}
 
-	// Check for keywords
-	switch value {
+	// Check for keywords; the conversion doesn't allocate
+	switch string(l.buf[start-l.base : l.pos-l.base]) {
 	case "true":
-		l.tokens = append(l.tokens, token{tokenBool, "true"})
+		l.emit(tokenBool, "true")
 	case "false":
-		l.tokens = append(l.tokens, token{tokenBool, "false"})
+		l.emit(tokenBool, "false")
 	case "nil":
-		l.tokens = append(l.tokens, token{tokenNil, "nil"})
+		l.emit(tokenNil, "nil")
 	default:
-		l.tokens = append(l.tokens, token{tokenIdent, value})
+		if l.skip {
+			l.emit(tokenIdent, "")
+		} else {
+			l.emit(tokenIdent, l.text(start))
+		}
 	}
 }
 
@@ -154,58 +706,165 @@ func isDigit(ch byte) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
 func isAlpha(ch byte) bool {
 	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
 }
 
-type parser struct {
-	tokens []token
-	pos    int
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v'
 }
 
-func newParser(tokens []token) *parser {
-	return &parser{
-		tokens: tokens,
-		pos:    0,
+// hexValue returns the value of a hexadecimal digit
+func hexValue(ch byte) byte {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
 	}
 }
 
+// nameLen returns the length of the character at the start of s if it
+// can be part of a name, or 0. Lua names are ASCII, but letters of any
+// script are accepted, as hand-edited files use them for character names.
+func nameLen(s string, first bool) int {
+	if s == "" {
+		return 0
+	}
+
+	if ch := s[0]; ch < utf8.RuneSelf {
+		if isAlpha(ch) || ch == '_' || (!first && isDigit(ch)) {
+			return 1
+		}
+		return 0
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	if unicode.IsLetter(r) || (!first && (unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))) {
+		return size
+	}
+	return 0
+}
+
+type parser struct {
+	lex    *lexer
+	ahead  [2]token // tokens peeked but not consumed yet
+	nahead int
+	last   token // the last consumed token
+	depth  int   // how many tables are open
+}
+
+func newParser(lex *lexer) *parser {
+	return &parser{lex: lex}
+}
+
 func (p *parser) peek() token {
-	if p.pos < len(p.tokens) {
-		return p.tokens[p.pos]
+	return p.peekAt(0)
+}
+
+// peekAt returns the token offset tokens ahead. Past the end it returns
+// the EOF token. The parser looks at most two tokens ahead.
+func (p *parser) peekAt(offset int) token {
+	for p.nahead <= offset {
+		if p.nahead > 0 && p.ahead[p.nahead-1].typ == tokenEOF {
+			return p.ahead[p.nahead-1]
+		}
+		p.ahead[p.nahead] = p.lex.next()
+		p.nahead++
 	}
-	return token{tokenEOF, ""}
+	return p.ahead[offset]
 }
 
 func (p *parser) next() token {
 	tok := p.peek()
-	p.pos++
+	if tok.typ != tokenEOF {
+		p.ahead[0] = p.ahead[1]
+		p.nahead--
+	}
+	p.last = tok
 	return tok
 }
 
 func (p *parser) expect(typ tokenType) (token, error) {
 	tok := p.next()
+	if tok.typ == tokenIllegal {
+		return tok, p.illegal(tok)
+	}
 	if tok.typ != typ {
-		return tok, fmt.Errorf("expected %v, got %v", typ, tok.typ)
+		return tok, p.unexpected(tok, typ.String())
 	}
 	return tok, nil
 }
 
-func (p *parser) parseTable() (map[string]interface{}, error) {
-	result := make(map[string]interface{})
+// enter opens the table starting at tok, failing if that nests tables too
+// deeply. Each successful enter is paired with a leave.
+func (p *parser) enter(tok token) error {
+	if p.lex.limits.tooDeep(p.depth + 1) {
+		return p.tooDeep(tok)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// tooDeep returns the error for a table at tok that is nested too deeply
+func (p *parser) tooDeep(tok token) *ParseError {
+	return p.errorAt(tok, fmt.Sprintf("tables nested more than %d deep", p.lex.limits.MaxDepth))
+}
+
+// errorAt returns an error at the position of tok
+func (p *parser) errorAt(tok token, message string) *ParseError {
+	return newParseError(tok, message, p.lex.excerpt(tok.offset))
+}
+
+// unexpected returns an error for finding tok instead of expected
+func (p *parser) unexpected(tok token, expected string) *ParseError {
+	err := p.errorAt(tok, "")
+	err.Expected = expected
+	return err
+}
+
+// illegal returns the lexer's error for an invalid token
+func (p *parser) illegal(tok token) *ParseError {
+	return p.errorAt(tok, tok.value)
+}
 
-	if _, err := p.expect(tokenLBrace); err != nil {
+// parseTable parses a table constructor:
+//
+//	{ [key] = value, name = value, value; ... }
+func (p *parser) parseTable() (*Table, error) {
+	table := NewTable()
+
+	open, err := p.expect(tokenLBrace)
+	if err != nil {
 		return nil, err
 	}
+	if err := p.enter(open); err != nil {
+		return nil, err
+	}
+	defer p.leave()
 
-	for p.peek().typ != tokenRBrace && p.peek().typ != tokenEOF {
-		// Parse key
-		if p.peek().typ == tokenLBracket {
+	for p.peek().typ != tokenRBrace {
+		switch {
+		case p.peek().typ == tokenLBracket:
 			p.next() // consume [
-			keyTok, err := p.expect(tokenString)
+			keyTok := p.peek()
+			key, err := p.parseValue()
 			if err != nil {
 				return nil, err
 			}
+			if key.Kind() == KindNil || key.Kind() == KindTable {
+				return nil, p.errorAt(keyTok, fmt.Sprintf("invalid table key of type %v", key.Kind()))
+			}
 			if _, err := p.expect(tokenRBracket); err != nil {
 				return nil, err
 			}
@@ -213,18 +872,35 @@ func (p *parser) parseTable() (map[string]interface{}, error) {
 				return nil, err
 			}
 
-			// Parse value
 			value, err := p.parseValue()
 			if err != nil {
 				return nil, err
 			}
+			table.Set(key, value)
+		case p.peek().typ == tokenIdent && p.peekAt(1).typ == tokenEquals:
+			name := p.next().value
+			p.next() // consume =
 
-			result[keyTok.value] = value
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			table.Set(String(name), value)
+		default:
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			table.Append(value)
 		}
 
-		// Optional comma
-		if p.peek().typ == tokenComma {
+		// Fields are separated by , or ; and the last one may have one too
+		if sep := p.peek(); sep.typ == tokenComma || sep.typ == tokenSemicolon {
 			p.next()
+		} else if sep.typ == tokenIllegal {
+			return nil, p.illegal(sep)
+		} else if sep.typ != tokenRBrace {
+			return nil, p.unexpected(sep, "',' or '}'")
 		}
 	}
 
@@ -232,10 +908,10 @@ func (p *parser) parseTable() (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	return result, nil
+	return table, nil
 }
 
-func (p *parser) parseValue() (interface{}, error) {
+func (p *parser) parseValue() (Value, error) {
 	tok := p.peek()
 
 	switch tok.typ {
@@ -243,151 +919,161 @@ func (p *parser) parseValue() (interface{}, error) {
 		return p.parseTable()
 	case tokenString:
 		p.next()
-		return tok.value, nil
+		return String(tok.value), nil
 	case tokenNumber:
 		p.next()
-		num, _ := strconv.ParseInt(tok.value, 10, 64)
-		return num, nil
+		num, err := parseNumber(tok.value)
+		if err != nil {
+			return nil, p.errorAt(tok, fmt.Sprintf("malformed number %q", tok.value))
+		}
+		return Number(num), nil
 	case tokenBool:
 		p.next()
-		return tok.value == "true", nil
+		return Bool(tok.value == "true"), nil
 	case tokenNil:
 		p.next()
-		return nil, nil
+		return Nil{}, nil
+	case tokenIllegal:
+		p.next()
+		return nil, p.illegal(tok)
 	default:
-		return nil, fmt.Errorf("unexpected token: %v", tok.typ)
+		return nil, p.unexpected(tok, "value")
 	}
 }
 
-// ParseSimple uses the simple parser
-func ParseSimple(content string) (*Database, error) {
-	// Tokenize
-	lexer := newLexer(content)
-	tokens := lexer.lex()
-
-	// Parse
-	parser := newParser(tokens)
-
-	// Skip to AddonProfilesDB =
-	for parser.peek().typ != tokenEOF {
-		if parser.peek().typ == tokenIdent && parser.peek().value == "AddonProfilesDB" {
-			parser.next() // consume identifier
-			parser.expect(tokenEquals)
-			break
-		}
-		parser.next()
+// skipValue consumes a value without building it. Tables are only checked
+// for matching braces, so their contents are read but not kept.
+func (p *parser) skipValue() error {
+	if p.peek().typ != tokenLBrace {
+		_, err := p.parseValue()
+		return err
 	}
 
-	// Parse the main table
-	mainTable, err := parser.parseTable()
-	if err != nil {
-		return nil, err
-	}
+	p.lex.skip = true
+	defer func() { p.lex.skip = false }()
 
-	// Convert to Database structure
-	db := &Database{}
-	db.Global.Profiles = make(map[string]*Profile)
-	db.Char = make(map[string]struct {
-		ActiveProfile string
-		Profiles      map[string]*Profile
-	})
-
-	// Parse global section
-	if globalRaw, ok := mainTable["global"]; ok {
-		if globalMap, ok := globalRaw.(map[string]interface{}); ok {
-			// Active profile
-			if activeRaw, ok := globalMap["activeProfile"]; ok {
-				if active, ok := activeRaw.(string); ok {
-					db.Global.ActiveProfile = active
-				}
+	depth := 0
+	for {
+		tok := p.next()
+		switch tok.typ {
+		case tokenLBrace:
+			depth++
+			if p.lex.limits.tooDeep(p.depth + depth) {
+				return p.tooDeep(tok)
 			}
-
-			// Profiles
-			if profilesRaw, ok := globalMap["profiles"]; ok {
-				if profilesMap, ok := profilesRaw.(map[string]interface{}); ok {
-					for profileName, profileRaw := range profilesMap {
-						if profileMap, ok := profileRaw.(map[string]interface{}); ok {
-							profile := convertToProfile(profileName, "account", profileMap)
-							db.Global.Profiles[profileName] = profile
-						}
-					}
-				}
+		case tokenRBrace:
+			depth--
+			if depth == 0 {
+				return nil
 			}
+		case tokenIllegal:
+			return p.illegal(tok)
+		case tokenEOF:
+			return p.unexpected(tok, tokenRBrace.String())
 		}
 	}
+}
 
-	// Parse char section
-	if charRaw, ok := mainTable["char"]; ok {
-		if charMap, ok := charRaw.(map[string]interface{}); ok {
-			for charKey, charDataRaw := range charMap {
-				if charDataMap, ok := charDataRaw.(map[string]interface{}); ok {
-					charData := struct {
-						ActiveProfile string
-						Profiles      map[string]*Profile
-					}{
-						Profiles: make(map[string]*Profile),
-					}
-
-					// Active profile
-					if activeRaw, ok := charDataMap["activeProfile"]; ok {
-						if active, ok := activeRaw.(string); ok {
-							charData.ActiveProfile = active
-						}
-					}
-
-					// Profiles
-					if profilesRaw, ok := charDataMap["profiles"]; ok {
-						if profilesMap, ok := profilesRaw.(map[string]interface{}); ok {
-							for profileName, profileRaw := range profilesMap {
-								if profileMap, ok := profileRaw.(map[string]interface{}); ok {
-									profile := convertToProfile(profileName, "character", profileMap)
-									charData.Profiles[profileName] = profile
-								}
-							}
-						}
-					}
-
-					db.Char[charKey] = charData
-				}
-			}
-		}
+// ParseValue parses a single Lua value, such as the table on the right of
+// a SavedVariables assignment. Syntax errors are returned as *ParseError.
+func ParseValue(content string) (Value, error) {
+	l := newStringLexer(content, Limits{})
+	if err := l.readError(); err != nil {
+		return nil, err
+	}
+	parser := newParser(l)
+
+	value, err := parser.parseValue()
+	if err != nil {
+		return nil, err
 	}
 
-	return db, nil
+	if tok := parser.peek(); tok.typ != tokenEOF {
+		return nil, parser.unexpected(tok, tokenEOF.String())
+	}
+
+	return value, nil
+}
+
+// ParseSimple uses the simple parser. Entries of the wrong type are
+// skipped; ParseWithReport reports them.
+func ParseSimple(content string) (*Database, error) {
+	db, _, err := parseSimple(newStringLexer(content, Limits{}))
+	return db, err
+}
+
+// ParseSimpleReader uses the simple parser on a file read from r. Only
+// AddonProfilesDB is decoded; other globals are skipped unread.
+func ParseSimpleReader(r io.Reader) (*Database, error) {
+	db, _, err := parseSimple(newLexer(r, Limits{}))
+	return db, err
 }
 
-func convertToProfile(name, scope string, profileMap map[string]interface{}) *Profile {
-	profile := &Profile{
-		Name:     name,
-		Scope:    scope,
-		Addons:   make(map[string]bool),
-		AutoDeps: true, // default
+// parseSimple decodes AddonProfilesDB from l. Entries of the wrong type,
+// e.g. an autoDeps that isn't a boolean, are left out of the database and
+// the first of them is returned as skipped.
+func parseSimple(l *lexer) (db *Database, skipped error, err error) {
+	value, ok, err := parseGlobal(l, "AddonProfilesDB")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("AddonProfilesDB not found")
 	}
 
-	// Parse addons
-	if addonsRaw, ok := profileMap["addons"]; ok {
-		if addonsMap, ok := addonsRaw.(map[string]interface{}); ok {
-			for addonName, enabledRaw := range addonsMap {
-				if enabled, ok := enabledRaw.(bool); ok {
-					profile.Addons[addonName] = enabled
-				}
-			}
-		}
+	// WoW saves a variable the addon never set as nil
+	table, ok := value.(*Table)
+	if !ok && value.Kind() != KindNil {
+		return nil, nil, fmt.Errorf("AddonProfilesDB is a %v, not a table", value.Kind())
 	}
+	if !ok {
+		table = NewTable()
+	}
+
+	fillDefaults(table)
 
-	// Parse autoDeps
-	if autoDepsRaw, ok := profileMap["autoDeps"]; ok {
-		if autoDeps, ok := autoDepsRaw.(bool); ok {
-			profile.AutoDeps = autoDeps
+	db = &Database{}
+	if err := UnmarshalValue(table, db); err != nil {
+		if _, ok := err.(*UnmarshalTypeError); !ok {
+			return nil, nil, err
 		}
+		skipped = err
 	}
 
-	// Parse created
-	if createdRaw, ok := profileMap["created"]; ok {
-		if created, ok := createdRaw.(int64); ok {
-			profile.Created = created
+	if db.Global.Profiles == nil {
+		db.Global.Profiles = make(map[string]*Profile)
+	}
+	if db.Char == nil {
+		db.Char = make(map[string]CharData)
+	}
+
+	// Profiles are named by their key, and scoped by where they are stored
+	// unless they say otherwise
+	fillProfiles(db.Global.Profiles, "account")
+	for charKey, charData := range db.Char {
+		if charData.Profiles == nil {
+			charData.Profiles = make(map[string]*Profile)
+			db.Char[charKey] = charData
 		}
+		fillProfiles(charData.Profiles, "character")
 	}
 
-	return profile
+	return db, skipped, nil
+}
+
+// fillProfiles sets the name and any missing scope of decoded profiles
+func fillProfiles(profiles map[string]*Profile, scope string) {
+	for name, profile := range profiles {
+		if profile == nil {
+			delete(profiles, name)
+			continue
+		}
+		profile.Name = name
+		if profile.Scope == "" {
+			profile.Scope = scope
+		}
+		if profile.Addons == nil {
+			profile.Addons = make(map[string]bool)
+		}
+	}
 }
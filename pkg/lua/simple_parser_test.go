@@ -0,0 +1,293 @@
+package lua
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "nil", input: "nil", want: nil},
+		{name: "boolean", input: "false", want: false},
+		{name: "integer", input: "42", want: int64(42)},
+		{name: "negative", input: "-7", want: int64(-7)},
+		{name: "float", input: "0.25", want: 0.25},
+		{name: "leading dot", input: ".5", want: 0.5},
+		{name: "exponent", input: "1.5e3", want: int64(1500)},
+		{name: "negative exponent", input: "25E-2", want: 0.25},
+		{name: "hex", input: "0xFF", want: int64(255)},
+		{name: "double quoted", input: `"Deadly Boss Mods"`, want: "Deadly Boss Mods"},
+		{name: "single quoted", input: `'it''s'`, wantErr: true},
+		{name: "single quoted escape", input: `'it\'s'`, want: "it's"},
+		{name: "escapes", input: `"a\tb\n\"c\"\\"`, want: "a\tb\n\"c\"\\"},
+		{name: "decimal escape", input: `"\65\066\0677"`, want: "ABC7"},
+		{name: "escaped newline", input: "\"a\\\nb\"", want: "a\nb"},
+		{name: "hex escape", input: `"\x41\x7a"`, want: "Az"},
+		{name: "skipped whitespace", input: "\"a\\z\n\t  b\"", want: "ab"},
+		{name: "unicode escape", input: `"\u{48}\u{E9}\u{AC00}"`, want: "Hé가"},
+		{name: "UTF-8 as decimal escapes", input: `"\195\134r\195\175s"`, want: "Ærïs"},
+		{name: "accented", input: `"Ærïs - Argent Dawn"`, want: "Ærïs - Argent Dawn"},
+		{name: "cyrillic", input: `"Пётр - Гордунни"`, want: "Пётр - Гордунни"},
+		{name: "korean", input: `"가나다 - 아즈샤라"`, want: "가나다 - 아즈샤라"},
+		{name: "escaped quotes", input: `"Raid \"Mythic\""`, want: `Raid "Mythic"`},
+		{name: "long string", input: "[[\nline 1\nline \"2\"]]", want: "line 1\nline \"2\""},
+		{name: "long string with level", input: "[==[a]]b]==]", want: "a]]b"},
+		{name: "array", input: `{"a", "b"; "c",}`, want: []interface{}{"a", "b", "c"}},
+		{name: "empty table", input: "{}", want: map[string]interface{}{}},
+		{name: "non-ASCII names", input: "{ Ærïs = 1, Пётр2 = 2 }", want: map[string]interface{}{"Ærïs": int64(1), "Пётр2": int64(2)}},
+		{
+			name:  "keys",
+			input: `{ name = "x", ["quoted key"] = 1, [2] = true, [1.5] = "f" }`,
+			want: map[string]interface{}{
+				"name":       "x",
+				"quoted key": int64(1),
+				"2":          true,
+				"1.5":        "f",
+			},
+		},
+		{
+			name:  "mixed array and keys",
+			input: `{ "first", _private = 1, "second" }`,
+			want: map[string]interface{}{
+				"1":        "first",
+				"2":        "second",
+				"_private": int64(1),
+			},
+		},
+		{
+			name: "comments",
+			input: `-- header
+			{ --[[ block
+			comment ]] a = 1, --[==[ ]] ]==] b = 2 -- trailing
+			}`,
+			want: map[string]interface{}{"a": int64(1), "b": int64(2)},
+		},
+		{name: "unterminated string", input: `"abc`, wantErr: true},
+		{name: "unterminated table", input: `{ a = 1`, wantErr: true},
+		{name: "missing separator", input: `{ a = 1 b = 2 }`, wantErr: true},
+		{name: "nil key", input: `{ [nil] = 1 }`, wantErr: true},
+		{name: "invalid escape", input: `"\q"`, wantErr: true},
+		{name: "escape out of range", input: `"\256"`, wantErr: true},
+		{name: "unexpected character", input: `{ a = @ }`, wantErr: true},
+		{name: "trailing tokens", input: `1 2`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ParseValue(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := toInterface(value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValueTable(t *testing.T) {
+	value, err := ParseValue(`{ "a", "b", z = 1, y = 2, [2] = "B", x = 3, y = 4 }`)
+	if err != nil {
+		t.Fatalf("ParseValue() error = %v", err)
+	}
+
+	table, ok := value.(*Table)
+	if !ok {
+		t.Fatalf("ParseValue() = %T, want *Table", value)
+	}
+
+	if table.Len() != 2 || table.Get(Number(2)) != String("B") {
+		t.Errorf("Array = %v, want [a B]", table.Array)
+	}
+
+	// Keys keep source order; a repeated key keeps its first position
+	var keys []string
+	for _, field := range table.Fields() {
+		keys = append(keys, keyString(field.Key))
+	}
+	if want := []string{"z", "y", "x"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if got := table.Field("y"); got != Number(4) {
+		t.Errorf("y = %v, want %v", got, 4)
+	}
+
+	table.Delete(String("z"))
+	if table.Field("z") != nil || table.Field("x") != Number(3) {
+		t.Errorf("after Delete = %v, want z removed and x kept", table.Fields())
+	}
+}
+
+func TestParseSimpleOtherForms(t *testing.T) {
+	content := `
+-- written by a newer version
+AddonProfilesDB = {
+	global = {
+		activeProfile = 'Raid',
+		profiles = {
+			Raid = {
+				name = "Raid",
+				scope = "account",
+				addons = { ["DBM-Core"] = true, BigWigs = false; },
+				autoDeps = true,
+				created = 1.7e9,
+			},
+		},
+		settings = { scale = 0.85, order = { "a", "b" } },
+	},
+}
+`
+	db, err := ParseSimple(content)
+	if err != nil {
+		t.Fatalf("ParseSimple() error = %v", err)
+	}
+
+	if db.Global.ActiveProfile != "Raid" {
+		t.Errorf("ActiveProfile = %v, want %v", db.Global.ActiveProfile, "Raid")
+	}
+
+	profile := db.Global.Profiles["Raid"]
+	if profile == nil {
+		t.Fatal("profile Raid missing")
+	}
+	if !profile.Addons["DBM-Core"] || profile.Addons["BigWigs"] || profile.Created != 1700000000 {
+		t.Errorf("profile = %+v, want DBM-Core enabled and created 1700000000", profile)
+	}
+
+	// Settings this package doesn't know are kept as they are
+	settings := db.Global.Settings
+	if settings == nil || settings.Extra["scale"] != Number(0.85) {
+		t.Fatalf("Settings = %+v, want scale %v", settings, 0.85)
+	}
+	if want := []interface{}{"a", "b"}; !reflect.DeepEqual(toInterface(settings.Extra["order"]), want) {
+		t.Errorf("order = %v, want %v", toInterface(settings.Extra["order"]), want)
+	}
+}
+
+func TestParseValueErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		column  int
+		wantErr string
+	}{
+		{
+			name:    "missing separator",
+			input:   "{\n\ta = 1\n\tb = 2\n}",
+			line:    3,
+			column:  2,
+			wantErr: "line 3, column 2: expected ',' or '}', got name b",
+		},
+		{
+			name:    "unterminated table",
+			input:   "{ a = 1,",
+			line:    1,
+			column:  9,
+			wantErr: "line 1, column 9: expected value, got end of file",
+		},
+		{
+			name:    "invalid escape",
+			input:   `{ "ok", "bad \q" }`,
+			line:    1,
+			column:  14,
+			wantErr: `line 1, column 14: invalid escape sequence \q`,
+		},
+		{
+			name:    "unfinished string",
+			input:   "{\n  \"abc\n}",
+			line:    2,
+			column:  3,
+			wantErr: "line 2, column 3: unfinished string",
+		},
+		{
+			name:    "position after long string",
+			input:   "{ [[a\nb]], @ }",
+			line:    2,
+			column:  6,
+			wantErr: "line 2, column 6: unexpected character '@'",
+		},
+		{
+			name:    "invalid hex escape",
+			input:   `"\xZ1"`,
+			line:    1,
+			column:  2,
+			wantErr: `line 1, column 2: invalid escape sequence \x`,
+		},
+		{
+			name:    "code point too large",
+			input:   `"\u{110000}"`,
+			line:    1,
+			column:  2,
+			wantErr: `line 1, column 2: invalid escape sequence \u{110000}`,
+		},
+		{
+			name:    "surrogate",
+			input:   `"\u{D800}"`,
+			line:    1,
+			column:  2,
+			wantErr: `line 1, column 2: invalid escape sequence \u{D800}`,
+		},
+		{
+			name:    "non-letter character",
+			input:   "{ … }",
+			line:    1,
+			column:  3,
+			wantErr: "line 1, column 3: unexpected character '…'",
+		},
+		{
+			name:    "invalid UTF-8",
+			input:   "{ \xff }",
+			line:    1,
+			column:  3,
+			wantErr: "line 1, column 3: invalid UTF-8 byte 0xff",
+		},
+		{
+			name:    "invalid key",
+			input:   "{ [nil] = 1 }",
+			line:    1,
+			column:  4,
+			wantErr: "line 1, column 4: invalid table key of type nil",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseValue(tt.input)
+
+			parseErr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("ParseValue() error = %v, want *ParseError", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.line, tt.column)
+			}
+			if parseErr.Error() != tt.wantErr {
+				t.Errorf("Error() = %q, want %q", parseErr.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseErrorExcerpt(t *testing.T) {
+	_, err := ParseValue("{\n\tname = \"x\",\n\tother = @,\n}")
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("ParseValue() error = %v, want *ParseError", err)
+	}
+
+	want := "\tother = @,\n\t        ^"
+	if parseErr.Excerpt != want {
+		t.Errorf("Excerpt = %q, want %q", parseErr.Excerpt, want)
+	}
+}
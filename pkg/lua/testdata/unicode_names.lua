@@ -0,0 +1,56 @@
+AddonProfilesDB = {
+	["global"] = {
+		["activeProfile"] = "Raid \"Mythic\" \\ M+",
+		["profiles"] = {
+			["Ærïs"] = {
+				["addons"] = {
+					["Ærïs"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765432,
+				["scope"] = "account",
+			},
+			["Пётр"] = {
+				["addons"] = {
+					["Пётр"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765433,
+				["scope"] = "account",
+			},
+			["가나다"] = {
+				["addons"] = {
+					["가나다"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765434,
+				["scope"] = "account",
+			},
+			["Raid \"Mythic\" \\ M+"] = {
+				["addons"] = {
+					["Raid \"Mythic\" \\ M+"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765435,
+				["scope"] = "account",
+			},
+		},
+	},
+	["char"] = {
+		["Ærïs - Argent Dawn"] = {
+			["activeProfile"] = "Ærïs",
+			["profiles"] = {
+			},
+		},
+		["Пётр - Гордунни"] = {
+			["activeProfile"] = "Пётр",
+			["profiles"] = {
+			},
+		},
+		["가나다 - 아즈샤라"] = {
+			["activeProfile"] = "가나다",
+			["profiles"] = {
+			},
+		},
+	},
+}
@@ -0,0 +1,41 @@
+AddonProfilesDB = {
+	["char"] = {
+		["TestChar - TestRealm"] = {
+			["profiles"] = {
+				["PvP"] = {
+					["addons"] = {
+						["Gladius"] = true,
+					},
+					["autoDeps"] = false,
+					["created"] = 1698765434,
+					["scope"] = "character",
+				},
+			},
+		},
+	},
+	["global"] = {
+		["activeProfile"] = "Default",
+		["profiles"] = {
+			["Default"] = {
+				["addons"] = {
+					["Ace3"] = true,
+					["Details"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765432,
+				["icon"] = "Interface\\Icons\\INV_Misc_Book_09",
+				["scope"] = "account",
+			},
+		},
+		["settings"] = {
+			["hideDefaultAddonsButton"] = true,
+			["minimap"] = {
+				["hide"] = false,
+			},
+		},
+		["sharedWith"] = {
+			"Alt - Realm", -- [1]
+		},
+	},
+	["syncedAt"] = 1698765440,
+}
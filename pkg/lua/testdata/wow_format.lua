@@ -0,0 +1,35 @@
+
+WeakAurasSaved = {
+	["displays"] = {
+		["Interrupts"] = {
+			["id"] = "Interrupts",
+			["alpha"] = 0.85,
+			["xOffset"] = -12.5,
+			["triggers"] = {
+				{
+					["trigger"] = {
+						["spellIds"] = {
+							47528, -- [1]
+							183752, -- [2]
+						},
+						["event"] = "Combat Log",
+					},
+				}, -- [1]
+				["activeTriggerMode"] = -10,
+			},
+			["load"] = {
+				["class"] = {
+					["multi"] = {
+					},
+				},
+			},
+			["desc"] = "Tracks \"kicks\"\nand stops",
+			["sparse"] = {
+				[3] = "third",
+				[10] = true,
+			},
+		},
+	},
+	["dbVersion"] = 76,
+	["login"] = true,
+}
@@ -0,0 +1,224 @@
+package lua
+
+import (
+	"math"
+	"strconv"
+)
+
+// Kind identifies the type of a Lua value
+type Kind int
+
+const (
+	KindNil Kind = iota
+	KindBool
+	KindNumber
+	KindString
+	KindTable
+)
+
+// String returns the Lua name of the kind
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "boolean"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindTable:
+		return "table"
+	default:
+		return "nil"
+	}
+}
+
+// Value is a Lua value as found in SavedVariables: Nil, Bool, Number,
+// String or *Table
+type Value interface {
+	Kind() Kind
+}
+
+// Nil is the Lua nil value
+type Nil struct{}
+
+// Bool is a Lua boolean
+type Bool bool
+
+// Number is a Lua number. Lua 5.1, which WoW uses, has no separate
+// integer type.
+type Number float64
+
+// String is a Lua string. Lua strings are byte strings and need not be
+// valid UTF-8.
+type String string
+
+func (Nil) Kind() Kind    { return KindNil }
+func (Bool) Kind() Kind   { return KindBool }
+func (Number) Kind() Kind { return KindNumber }
+func (String) Kind() Kind { return KindString }
+func (*Table) Kind() Kind { return KindTable }
+
+// Int returns the number as an integer and whether it is one
+func (n Number) Int() (int64, bool) {
+	f := float64(n)
+	if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// String formats the number the way Lua prints it: integers without a
+// fraction, other numbers with as few digits as round-trip
+func (n Number) String() string {
+	if i, ok := n.Int(); ok {
+		return strconv.FormatInt(i, 10)
+	}
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+// Field is a keyed entry of a table
+type Field struct {
+	Key   Value
+	Value Value
+}
+
+// Table is a Lua table. Entries without a key, e.g. {"a", "b"}, form the
+// array part; keyed entries are kept in source order.
+type Table struct {
+	Array  []Value
+	fields []Field
+	index  map[Value]int
+}
+
+// NewTable creates an empty table
+func NewTable() *Table {
+	return &Table{}
+}
+
+// Append adds a value to the array part
+func (t *Table) Append(v Value) {
+	t.Array = append(t.Array, v)
+}
+
+// Set sets a keyed entry. An existing key keeps its position; a new key is
+// added at the end. A key that is an index of the array part replaces
+// that element.
+func (t *Table) Set(key, v Value) {
+	if i, ok := t.arrayIndex(key); ok {
+		t.Array[i] = v
+		return
+	}
+
+	if t.index == nil {
+		t.index = make(map[Value]int)
+	}
+	if i, ok := t.index[key]; ok {
+		t.fields[i].Value = v
+		return
+	}
+	t.index[key] = len(t.fields)
+	t.fields = append(t.fields, Field{Key: key, Value: v})
+}
+
+// Get returns the value of a key, or nil if the table doesn't have it
+func (t *Table) Get(key Value) Value {
+	if i, ok := t.arrayIndex(key); ok {
+		return t.Array[i]
+	}
+	if i, ok := t.index[key]; ok {
+		return t.fields[i].Value
+	}
+	return nil
+}
+
+// Field returns the value of a string key, or nil
+func (t *Table) Field(name string) Value {
+	return t.Get(String(name))
+}
+
+// Delete removes a keyed entry
+func (t *Table) Delete(key Value) {
+	i, ok := t.index[key]
+	if !ok {
+		return
+	}
+
+	t.fields = append(t.fields[:i], t.fields[i+1:]...)
+	delete(t.index, key)
+	for j := i; j < len(t.fields); j++ {
+		t.index[t.fields[j].Key] = j
+	}
+}
+
+// Fields returns the keyed entries in order
+func (t *Table) Fields() []Field {
+	return t.fields
+}
+
+// Len returns the length of the array part
+func (t *Table) Len() int {
+	return len(t.Array)
+}
+
+// arrayIndex maps a key like Number(1) to its position in the array part
+func (t *Table) arrayIndex(key Value) (int, bool) {
+	n, ok := key.(Number)
+	if !ok {
+		return 0, false
+	}
+	i, ok := n.Int()
+	if !ok || i < 1 || i > int64(len(t.Array)) {
+		return 0, false
+	}
+	return int(i - 1), true
+}
+
+// toInterface converts a value to plain Go values: nil, bool, int64 or
+// float64, string, []interface{} for pure arrays and map[string]interface{}
+// for other tables
+func toInterface(v Value) interface{} {
+	switch v := v.(type) {
+	case Bool:
+		return bool(v)
+	case Number:
+		if i, ok := v.Int(); ok {
+			return i
+		}
+		return float64(v)
+	case String:
+		return string(v)
+	case *Table:
+		if len(v.fields) == 0 && len(v.Array) > 0 {
+			list := make([]interface{}, len(v.Array))
+			for i, item := range v.Array {
+				list[i] = toInterface(item)
+			}
+			return list
+		}
+
+		m := make(map[string]interface{}, len(v.Array)+len(v.fields))
+		for i, item := range v.Array {
+			m[strconv.Itoa(i+1)] = toInterface(item)
+		}
+		for _, field := range v.fields {
+			m[keyString(field.Key)] = toInterface(field.Value)
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// keyString returns a table key as text
+func keyString(key Value) string {
+	switch k := key.(type) {
+	case String:
+		return string(k)
+	case Number:
+		return k.String()
+	case Bool:
+		return strconv.FormatBool(bool(k))
+	default:
+		return ""
+	}
+}
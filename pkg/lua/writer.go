@@ -0,0 +1,42 @@
+package lua
+
+import (
+	"io"
+)
+
+// Write serializes a database as an AddonProfilesDB SavedVariables file,
+// in the tab-indented format WoW writes, using the struct encoding of
+// Marshal. Keys are sorted so the output is stable.
+func Write(w io.Writer, db *Database) error {
+	return NewEncoder(w).EncodeGlobal("AddonProfilesDB", savedDatabase(db))
+}
+
+// savedDatabase returns a copy of db in the form the addon saves it:
+// profile lists and addon lists are written even when empty, and profiles
+// without a scope take the one of where they are stored
+func savedDatabase(db *Database) *Database {
+	saved := *db
+	saved.Global.Profiles = savedProfiles(db.Global.Profiles, "account")
+	saved.Char = make(map[string]CharData, len(db.Char))
+	for key, charData := range db.Char {
+		charData.Profiles = savedProfiles(charData.Profiles, "character")
+		saved.Char[key] = charData
+	}
+	return &saved
+}
+
+// savedProfiles returns copies of profiles in their saved form
+func savedProfiles(profiles map[string]*Profile, scope string) map[string]*Profile {
+	saved := make(map[string]*Profile, len(profiles))
+	for name, profile := range profiles {
+		p := *profile
+		if p.Scope == "" {
+			p.Scope = scope
+		}
+		if p.Addons == nil {
+			p.Addons = map[string]bool{}
+		}
+		saved[name] = &p
+	}
+	return saved
+}
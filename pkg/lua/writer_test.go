@@ -0,0 +1,149 @@
+package lua
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	db := &Database{}
+	db.Global.ActiveProfile = "Default"
+	db.Global.Profiles = map[string]*Profile{
+		"Default": {
+			Name:     "Default",
+			Addons:   map[string]bool{"Details": true, "Ace3": true},
+			AutoDeps: true,
+			Created:  1698765432,
+		},
+	}
+	db.Global.Settings = &Settings{HideDefaultAddonsButton: true}
+	db.Char = map[string]CharData{
+		"TestChar - TestRealm": {Profiles: map[string]*Profile{}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, db); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `
+AddonProfilesDB = {
+	["char"] = {
+		["TestChar - TestRealm"] = {
+			["profiles"] = {
+			},
+		},
+	},
+	["global"] = {
+		["activeProfile"] = "Default",
+		["profiles"] = {
+			["Default"] = {
+				["addons"] = {
+					["Ace3"] = true,
+					["Details"] = true,
+				},
+				["autoDeps"] = true,
+				["created"] = 1698765432,
+				["scope"] = "account",
+			},
+		},
+		["settings"] = {
+			["hideDefaultAddonsButton"] = true,
+		},
+	},
+}
+`
+	if buf.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// The defaults are filled in the written copy only
+	if scope := db.Global.Profiles["Default"].Scope; scope != "" {
+		t.Errorf("Scope = %q after Write(), want it unchanged", scope)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	db, err := ParseFile(filepath.Join("testdata", "valid_profile.lua"))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	// Names that need escaping survive the trip
+	db.Global.Profiles[`Mythic "+"`] = &Profile{
+		Name:    `Mythic "+"`,
+		Addons:  map[string]bool{"BigWigs": true},
+		Created: 1698765435,
+	}
+	order := NewTable()
+	order.Append(String("Raiding"))
+	order.Append(String("Default"))
+	db.Global.Settings.Extra = map[string]Value{"scale": Number(0.85), "order": order}
+
+	// Fields of newer addon versions are kept
+	db.Extra = map[string]Value{"syncedAt": Number(1698765440)}
+	db.Global.Profiles["Raiding"].Extra = map[string]Value{"icon": String("raid")}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, db); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	parsed, err := ParseSimple(buf.String())
+	if err != nil {
+		t.Fatalf("ParseSimple() error = %v", err)
+	}
+
+	if parsed.Global.ActiveProfile != db.Global.ActiveProfile {
+		t.Errorf("ActiveProfile = %v, want %v", parsed.Global.ActiveProfile, db.Global.ActiveProfile)
+	}
+	if !parsed.Global.Settings.HideDefaultAddonsButton {
+		t.Errorf("Settings = %+v, want hideDefaultAddonsButton", parsed.Global.Settings)
+	}
+	if parsed.Global.Settings.Extra["scale"] != Number(0.85) {
+		t.Errorf("scale = %v, want %v", parsed.Global.Settings.Extra["scale"], 0.85)
+	}
+	if order, ok := parsed.Global.Settings.Extra["order"].(*Table); !ok || order.Len() != 2 || order.Array[0] != String("Raiding") {
+		t.Errorf("order = %v, want [Raiding Default]", parsed.Global.Settings.Extra["order"])
+	}
+	if parsed.Extra["syncedAt"] != Number(1698765440) {
+		t.Errorf("Extra = %v, want syncedAt", parsed.Extra)
+	}
+	if parsed.Global.Profiles["Raiding"].Extra["icon"] != String("raid") {
+		t.Errorf("Raiding Extra = %v, want icon", parsed.Global.Profiles["Raiding"].Extra)
+	}
+	if len(parsed.Global.Profiles) != len(db.Global.Profiles) {
+		t.Errorf("Expected %d global profiles, got %d", len(db.Global.Profiles), len(parsed.Global.Profiles))
+	}
+
+	for name, want := range db.Global.Profiles {
+		got, ok := parsed.Global.Profiles[name]
+		if !ok {
+			t.Errorf("profile %q missing", name)
+			continue
+		}
+		if got.AutoDeps != want.AutoDeps || got.Created != want.Created || got.Scope != "account" {
+			t.Errorf("profile %q = %+v, want %+v", name, got, want)
+		}
+		if len(got.Addons) != len(want.Addons) {
+			t.Errorf("profile %q addons = %v, want %v", name, got.Addons, want.Addons)
+		}
+		for addon, enabled := range want.Addons {
+			if got.Addons[addon] != enabled {
+				t.Errorf("profile %q addon %s = %v, want %v", name, addon, got.Addons[addon], enabled)
+			}
+		}
+	}
+
+	char, ok := parsed.Char["TestChar - TestRealm"]
+	if !ok {
+		t.Fatal("character TestChar - TestRealm missing")
+	}
+	if char.ActiveProfile != "PvP" || len(char.Profiles["PvP"].Addons) != 2 {
+		t.Errorf("character = %+v, want PvP with 2 addons", char)
+	}
+	if char.Profiles["PvP"].AutoDeps {
+		t.Error("PvP AutoDeps = true, want false")
+	}
+}
@@ -1,14 +1,24 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
 )
 
+// clientPollInterval is how often a pending apply checks whether WoW exited
+const clientPollInterval = 2 * time.Second
+
 // ActionPanel displays profile actions and info
 type ActionPanel struct {
 	mainWindow   *MainWindow
@@ -17,6 +27,14 @@ type ActionPanel struct {
 	scopeLabel   *widget.Label
 	countLabel   *widget.Label
 	applyBtn     *widget.Button
+	cancelBtn    *widget.Button
+	newBtn       *widget.Button
+	renameBtn    *widget.Button
+	deleteBtn    *widget.Button
+	activateBtn  *widget.Button
+
+	// cancelPending stops a pending "apply when WoW exits", if any
+	cancelPending context.CancelFunc
 }
 
 // NewActionPanel creates a new action panel
@@ -33,8 +51,30 @@ func NewActionPanel(mw *MainWindow) *ActionPanel {
 	})
 	ap.applyBtn.Disable()
 
+	ap.cancelBtn = widget.NewButton("Cancel Pending Apply", func() {
+		ap.cancelPendingApply()
+		ap.mainWindow.setStatus("Pending apply cancelled")
+	})
+	ap.cancelBtn.Hide()
+
+	ap.newBtn = widget.NewButton("New Profile...", func() {
+		ap.newProfile()
+	})
+	ap.renameBtn = widget.NewButton("Rename...", func() {
+		ap.renameProfile()
+	})
+	ap.deleteBtn = widget.NewButton("Delete", func() {
+		ap.deleteProfile()
+	})
+	ap.activateBtn = widget.NewButton("Set Active", func() {
+		ap.activateProfile()
+	})
+	ap.renameBtn.Disable()
+	ap.deleteBtn.Disable()
+	ap.activateBtn.Disable()
+
 	// Info text explaining workflow
-	infoLabel := widget.NewLabel("Create and manage profiles\nin-game using the addon.\n\nThis tool lets you apply\nprofiles outside of WoW.")
+	infoLabel := widget.NewLabel("Profiles are shared with the\nin-game addon. Edit them here\nwhile WoW is closed.")
 	infoLabel.Wrapping = fyne.TextWrapWord
 
 	profileNameLabel := widget.NewLabel("Profile Name:")
@@ -59,6 +99,11 @@ func NewActionPanel(mw *MainWindow) *ActionPanel {
 		ap.countLabel,
 		widget.NewSeparator(),
 		ap.applyBtn,
+		ap.cancelBtn,
+		widget.NewSeparator(),
+		ap.newBtn,
+		container.NewGridWithColumns(2, ap.renameBtn, ap.deleteBtn),
+		ap.activateBtn,
 	)
 
 	return ap
@@ -71,55 +116,238 @@ func (ap *ActionPanel) Container() *fyne.Container {
 
 // Refresh updates the action panel
 func (ap *ActionPanel) Refresh() {
-	profile, scope := ap.mainWindow.profilePanel.GetSelectedProfile()
+	item := ap.mainWindow.profilePanel.GetSelectedItem()
 
-	if profile == nil {
+	if item == nil {
 		ap.profileLabel.SetText("No profile selected")
 		ap.scopeLabel.SetText("")
 		ap.countLabel.SetText("")
 		ap.applyBtn.Disable()
+		ap.renameBtn.Disable()
+		ap.deleteBtn.Disable()
+		ap.activateBtn.Disable()
 		return
 	}
 
+	profile := item.Profile
 	ap.profileLabel.SetText(profile.Name)
-	ap.scopeLabel.SetText(scope)
+	ap.scopeLabel.SetText(describeScope(item))
 	ap.countLabel.SetText(fmt.Sprintf("%d addons", len(profile.Addons)))
 	ap.applyBtn.Enable()
+	ap.renameBtn.Enable()
+	ap.deleteBtn.Enable()
+	if item.IsActive {
+		ap.activateBtn.Disable()
+	} else {
+		ap.activateBtn.Enable()
+	}
 }
 
 // applyProfile applies the selected profile
 func (ap *ActionPanel) applyProfile() {
-	profile, scope := ap.mainWindow.profilePanel.GetSelectedProfile()
-	if profile == nil {
+	item := ap.mainWindow.profilePanel.GetSelectedItem()
+	if item == nil {
+		return
+	}
+
+	ap.confirmApply(item)
+}
+
+// confirmApply previews applying a profile entry and applies it once
+// confirmed
+func (ap *ActionPanel) confirmApply(item *ProfileItem) {
+	profile := item.Profile
+
+	mgr := ap.mainWindow.GetManager()
+	if mgr == nil {
+		dialog.ShowError(fmt.Errorf("WoW manager not initialized"), ap.mainWindow.GetWindow())
 		return
 	}
 
-	// Confirmation dialog
-	dialog.ShowConfirm(
+	target := "your account's AddOns.txt file"
+	if item.CharacterKey != "" {
+		target = fmt.Sprintf("the AddOns.txt file of %s", item.CharacterKey)
+	}
+
+	diff, err := diffProfileItem(mgr, item)
+	if err != nil {
+		dialog.ShowError(err, ap.mainWindow.GetWindow())
+		return
+	}
+
+	// Confirmation dialog with a preview of the changes
+	message := widget.NewLabel(fmt.Sprintf("Apply profile '%s' (%s)?\n\nThis will update %s.\nA backup will be created automatically.",
+		profile.Name, describeScope(item), target))
+
+	confirm := dialog.NewCustomConfirm(
 		"Apply Profile",
-		fmt.Sprintf("Apply profile '%s' (%s)?\n\nThis will update your AddOns.txt file.\nA backup will be created automatically.",
-			profile.Name, scope),
+		"Apply",
+		"Cancel",
+		container.NewBorder(message, nil, nil, nil, newDiffView(diff, "AddOns.txt already matches this profile.")),
 		func(confirmed bool) {
 			if !confirmed {
 				return
 			}
 
-			mgr := ap.mainWindow.GetManager()
-			if mgr == nil {
-				dialog.ShowError(fmt.Errorf("WoW manager not initialized"), ap.mainWindow.GetWindow())
-				return
-			}
-
-			if err := mgr.ApplyProfile(profile); err != nil {
+			result, err := applyProfileItem(mgr, item)
+			if err != nil {
+				var running *wow.ClientRunningError
+				if errors.As(err, &running) {
+					ap.offerApplyOnExit(mgr, item)
+					return
+				}
 				dialog.ShowError(err, ap.mainWindow.GetWindow())
 				return
 			}
 
-			ap.mainWindow.setStatus(fmt.Sprintf("Profile '%s' applied successfully", profile.Name))
-			dialog.ShowInformation("Success",
-				fmt.Sprintf("Profile '%s' has been applied.\n\nYour addons will be updated when you start WoW.", profile.Name),
-				ap.mainWindow.GetWindow())
+			ap.showApplied(profile.Name, result)
 		},
 		ap.mainWindow.GetWindow(),
 	)
+	confirm.Resize(fyne.NewSize(480, 520))
+	confirm.Show()
+}
+
+// showApplied reports a successfully applied profile
+func (ap *ActionPanel) showApplied(name string, result *wow.ApplyResult) {
+	ap.mainWindow.setStatus(fmt.Sprintf("Profile '%s' applied successfully", name))
+	dialog.ShowInformation("Success",
+		fmt.Sprintf("Profile '%s' has been applied.\n\nYour addons will be updated when you start WoW.", name)+
+			formatDependencyReport(result.Dependencies),
+		ap.mainWindow.GetWindow())
+}
+
+// offerApplyOnExit asks whether to apply a profile once the running WoW
+// client exits, since WoW would overwrite AddOns.txt on logout
+func (ap *ActionPanel) offerApplyOnExit(mgr *wow.Manager, item *ProfileItem) {
+	dialog.ShowConfirm("World of Warcraft Is Running",
+		fmt.Sprintf("WoW rewrites AddOns.txt when you log out, so changes made now would be lost.\n\n"+
+			"Apply '%s' automatically when WoW exits?", item.Profile.Name),
+		func(confirmed bool) {
+			if confirmed {
+				ap.applyOnExit(mgr, item)
+			}
+		},
+		ap.mainWindow.GetWindow())
+}
+
+// applyOnExit waits in the background for WoW to exit and then applies
+// the profile. Only one apply can be pending; a new one replaces it.
+func (ap *ActionPanel) applyOnExit(mgr *wow.Manager, item *ProfileItem) {
+	ap.cancelPendingApply()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ap.cancelPending = cancel
+	ap.cancelBtn.Show()
+	ap.mainWindow.setStatus(fmt.Sprintf("Waiting for WoW to exit to apply '%s'...", item.Profile.Name))
+
+	go func() {
+		if err := mgr.WaitForClientExit(ctx, clientPollInterval); err != nil {
+			// Cancelled
+			return
+		}
+
+		// Apply on the UI goroutine so a cancel can't race with it
+		fyne.Do(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			ap.cancelPendingApply()
+
+			result, err := applyProfileItem(mgr, item)
+			if err != nil {
+				dialog.ShowError(err, ap.mainWindow.GetWindow())
+				return
+			}
+			ap.showApplied(item.Profile.Name, result)
+		})
+	}()
+}
+
+// cancelPendingApply stops waiting to apply a profile
+func (ap *ActionPanel) cancelPendingApply() {
+	if ap.cancelPending != nil {
+		ap.cancelPending()
+		ap.cancelPending = nil
+	}
+	ap.cancelBtn.Hide()
+}
+
+// profileCharacter returns the character whose AddOns.txt a profile entry
+// applies to, or nil for account profiles
+func profileCharacter(mgr *wow.Manager, item *ProfileItem) (*wow.Character, error) {
+	if item.CharacterKey == "" {
+		return nil, nil
+	}
+
+	char, err := mgr.FindCharacter(item.CharacterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &char, nil
+}
+
+// diffProfileItem previews applying a profile entry to its AddOns.txt
+func diffProfileItem(mgr *wow.Manager, item *ProfileItem) (*wow.ProfileDiff, error) {
+	char, err := profileCharacter(mgr, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return mgr.DiffProfile(item.Profile, char)
+}
+
+// applyProfileItem applies a profile to the AddOns.txt its scope belongs to:
+// account profiles update the account file and character profiles update
+// the file of the character that owns them
+func applyProfileItem(mgr *wow.Manager, item *ProfileItem) (*wow.ApplyResult, error) {
+	char, err := profileCharacter(mgr, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if char == nil {
+		return mgr.ApplyProfile(item.Profile)
+	}
+	return mgr.ApplyProfileToCharacter(item.Profile, *char)
+}
+
+// describeScope returns the scope text shown for a profile
+func describeScope(item *ProfileItem) string {
+	if item.CharacterKey == "" {
+		return item.Scope
+	}
+	return fmt.Sprintf("%s: %s", item.Scope, item.CharacterKey)
+}
+
+// formatDependencyReport describes the dependencies pulled in by AutoDeps
+func formatDependencyReport(report *wow.DependencyReport) string {
+	if report == nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	if len(report.Added) > 0 {
+		fmt.Fprintf(&b, "\n\nEnabled as dependencies:\n%s", strings.Join(report.Added, ", "))
+	}
+
+	if len(report.Missing) > 0 {
+		var addons []string
+		for addon := range report.Missing {
+			addons = append(addons, addon)
+		}
+		sort.Strings(addons)
+
+		b.WriteString("\n\nMissing dependencies:")
+		for _, addon := range addons {
+			fmt.Fprintf(&b, "\n%s requires %s", addon, strings.Join(report.Missing[addon], ", "))
+		}
+	}
+
+	for _, cycle := range report.Cycles {
+		fmt.Fprintf(&b, "\n\nDependency cycle: %s", strings.Join(cycle, " → "))
+	}
+
+	return b.String()
 }
@@ -1,15 +1,22 @@
 package ui
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
 )
 
-// AddonPanel displays the addon list for selected profile
+// AddonPanel displays the addon list for selected profile. In edit mode
+// the checks can be toggled; changes are kept as pending until they are
+// saved to the profile, applied once, or discarded.
 type AddonPanel struct {
 	mainWindow  *MainWindow
 	container   *fyne.Container
@@ -17,6 +24,18 @@ type AddonPanel struct {
 	searchEntry *widget.Entry
 	addons      []AddonItem
 	filtered    []AddonItem
+
+	editBtn     *widget.Button
+	dirtyLabel  *widget.Label
+	bulkBar     *fyne.Container
+	editBar     *fyne.Container
+	saveBtn     *widget.Button
+	oneOffBtn   *widget.Button
+	discardBtn  *widget.Button
+	editing     bool
+	editingItem *ProfileItem
+	// pending holds the addons whose state differs from the profile
+	pending map[string]bool
 }
 
 // AddonItem represents an addon
@@ -31,6 +50,8 @@ func NewAddonPanel(mw *MainWindow) *AddonPanel {
 		mainWindow: mw,
 		addons:     []AddonItem{},
 		filtered:   []AddonItem{},
+		pending:    make(map[string]bool),
+		dirtyLabel: widget.NewLabel(""),
 	}
 
 	ap.searchEntry = widget.NewEntry()
@@ -50,19 +71,73 @@ func NewAddonPanel(mw *MainWindow) *AddonPanel {
 			check := obj.(*widget.Check)
 			if id < len(ap.filtered) {
 				addon := ap.filtered[id]
+
+				// Clear the handler first, SetChecked would trigger it
+				check.OnChanged = nil
 				check.SetText(addon.Name)
-				check.SetChecked(addon.Enabled)
-				check.Disable() // Read-only
+				check.SetChecked(ap.isEnabled(addon))
+
+				if !ap.editing {
+					check.Disable() // Read-only
+					return
+				}
+
+				check.Enable()
+				name := addon.Name
+				check.OnChanged = func(enabled bool) {
+					ap.setEnabled(name, enabled)
+					ap.updateDirty()
+				}
 			}
 		},
 	)
 
+	ap.editBtn = widget.NewButton("Edit", func() {
+		if ap.editing {
+			ap.confirmDiscard(ap.stopEditing)
+		} else {
+			ap.startEditing()
+		}
+	})
+	ap.editBtn.Disable()
+
+	ap.bulkBar = container.NewHBox(
+		widget.NewLabel("Select:"),
+		widget.NewButton("All", func() {
+			ap.setAll(ap.addons, true)
+		}),
+		widget.NewButton("None", func() {
+			ap.setAll(ap.addons, false)
+		}),
+		widget.NewButton("Filtered", func() {
+			ap.setAll(ap.filtered, true)
+		}),
+	)
+	ap.bulkBar.Hide()
+
+	ap.saveBtn = widget.NewButton("Save to Profile", func() {
+		ap.save()
+	})
+	ap.saveBtn.Importance = widget.HighImportance
+	ap.oneOffBtn = widget.NewButton("Apply as One-off", func() {
+		ap.applyOneOff()
+	})
+	ap.discardBtn = widget.NewButton("Discard", func() {
+		ap.confirmDiscard(ap.stopEditing)
+	})
+	ap.editBar = container.NewVBox(
+		widget.NewSeparator(),
+		container.NewGridWithColumns(3, ap.saveBtn, ap.oneOffBtn, ap.discardBtn),
+	)
+	ap.editBar.Hide()
+
 	ap.container = container.NewBorder(
 		container.NewVBox(
-			widget.NewLabel("AddOns"),
+			container.NewHBox(widget.NewLabel("AddOns"), ap.dirtyLabel, layout.NewSpacer(), ap.editBtn),
 			ap.searchEntry,
+			ap.bulkBar,
 		),
-		nil,
+		ap.editBar,
 		nil,
 		nil,
 		ap.addonList,
@@ -78,23 +153,48 @@ func (ap *AddonPanel) Container() *fyne.Container {
 
 // Refresh reloads the addon list
 func (ap *AddonPanel) Refresh() {
-	profile, _ := ap.mainWindow.profilePanel.GetSelectedProfile()
-	if profile == nil {
+	item := ap.mainWindow.profilePanel.GetSelectedItem()
+
+	// Pending changes belong to the profile they were made on
+	if ap.editing && (item == nil || ap.editingItem == nil || item.ID != ap.editingItem.ID) {
+		if len(ap.pending) > 0 {
+			ap.mainWindow.setStatus(fmt.Sprintf("Discarded unsaved changes to '%s'", ap.editingItem.Name))
+		}
+		ap.stopEditing()
+		return
+	}
+
+	if item == nil {
 		ap.addons = []AddonItem{}
 		ap.filtered = []AddonItem{}
+		ap.editBtn.Disable()
 		ap.addonList.Refresh()
 		return
 	}
+	ap.editBtn.Enable()
 
 	// Convert map to sorted slice
 	ap.addons = []AddonItem{}
-	for name, enabled := range profile.Addons {
+	for name, enabled := range item.Profile.Addons {
 		ap.addons = append(ap.addons, AddonItem{
 			Name:    name,
 			Enabled: enabled,
 		})
 	}
 
+	// While editing, offer every installed addon, not just the profile's
+	if ap.editing {
+		catalog, err := ap.mainWindow.GetManager().GetInstalledAddons()
+		if err != nil {
+			ap.mainWindow.setStatus(fmt.Sprintf("Error loading installed addons: %v", err))
+		}
+		for _, name := range catalog.Names() {
+			if _, ok := item.Profile.Addons[name]; !ok {
+				ap.addons = append(ap.addons, AddonItem{Name: name})
+			}
+		}
+	}
+
 	sort.Slice(ap.addons, func(i, j int) bool {
 		return ap.addons[i].Name < ap.addons[j].Name
 	})
@@ -118,3 +218,170 @@ func (ap *AddonPanel) filterAddons(search string) {
 
 	ap.addonList.Refresh()
 }
+
+// startEditing switches the list to edit mode for the selected profile
+func (ap *AddonPanel) startEditing() {
+	item := ap.mainWindow.profilePanel.GetSelectedItem()
+	if item == nil {
+		return
+	}
+
+	ap.editing = true
+	ap.editingItem = item
+	ap.pending = make(map[string]bool)
+	ap.editBtn.SetText("Done")
+	ap.bulkBar.Show()
+	ap.editBar.Show()
+	ap.updateDirty()
+	ap.Refresh()
+}
+
+// stopEditing leaves edit mode and drops pending changes
+func (ap *AddonPanel) stopEditing() {
+	ap.editing = false
+	ap.editingItem = nil
+	ap.pending = make(map[string]bool)
+	ap.editBtn.SetText("Edit")
+	ap.bulkBar.Hide()
+	ap.editBar.Hide()
+	ap.updateDirty()
+	ap.Refresh()
+}
+
+// confirmDiscard runs discard right away if nothing is pending, and after
+// confirmation otherwise
+func (ap *AddonPanel) confirmDiscard(discard func()) {
+	if len(ap.pending) == 0 {
+		discard()
+		return
+	}
+
+	dialog.ShowConfirm("Discard Changes",
+		fmt.Sprintf("Discard %d unsaved changes to '%s'?", len(ap.pending), ap.editingItem.Name),
+		func(confirmed bool) {
+			if confirmed {
+				discard()
+			}
+		}, ap.mainWindow.GetWindow())
+}
+
+// isEnabled returns an addon's state including pending changes
+func (ap *AddonPanel) isEnabled(addon AddonItem) bool {
+	if enabled, ok := ap.pending[addon.Name]; ok {
+		return enabled
+	}
+	return addon.Enabled
+}
+
+// setEnabled records a pending change, dropping it again if it matches
+// the profile
+func (ap *AddonPanel) setEnabled(name string, enabled bool) {
+	if ap.editingItem == nil {
+		return
+	}
+
+	if ap.editingItem.Profile.Addons[name] == enabled {
+		delete(ap.pending, name)
+	} else {
+		ap.pending[name] = enabled
+	}
+}
+
+// setAll sets the state of every addon in addons
+func (ap *AddonPanel) setAll(addons []AddonItem, enabled bool) {
+	for _, addon := range addons {
+		ap.setEnabled(addon.Name, enabled)
+	}
+	ap.updateDirty()
+	ap.addonList.Refresh()
+}
+
+// updateDirty shows whether there are unsaved changes
+func (ap *AddonPanel) updateDirty() {
+	if len(ap.pending) == 0 {
+		ap.dirtyLabel.SetText("")
+		ap.saveBtn.Disable()
+		ap.oneOffBtn.Disable()
+		return
+	}
+
+	ap.dirtyLabel.SetText(fmt.Sprintf("● %d unsaved", len(ap.pending)))
+	ap.saveBtn.Enable()
+	ap.oneOffBtn.Enable()
+}
+
+// editedAddons returns the enabled addons of the profile with the pending
+// changes applied
+func (ap *AddonPanel) editedAddons() map[string]bool {
+	addons := make(map[string]bool)
+	for name, enabled := range ap.editingItem.Profile.Addons {
+		if enabled {
+			addons[name] = true
+		}
+	}
+	for name, enabled := range ap.pending {
+		if enabled {
+			addons[name] = true
+		} else {
+			delete(addons, name)
+		}
+	}
+	return addons
+}
+
+// save writes the pending changes to the profile in SavedVariables
+func (ap *AddonPanel) save() {
+	item := ap.editingItem
+	if item == nil {
+		return
+	}
+
+	mgr := ap.mainWindow.GetManager()
+	if mgr == nil {
+		dialog.ShowError(fmt.Errorf("WoW manager not initialized"), ap.mainWindow.GetWindow())
+		return
+	}
+
+	char, err := profileCharacter(mgr, item)
+	if err != nil {
+		dialog.ShowError(err, ap.mainWindow.GetWindow())
+		return
+	}
+
+	count := len(ap.pending)
+	if err := mgr.SetProfileAddons(item.Name, char, ap.editedAddons()); err != nil {
+		dialog.ShowError(err, ap.mainWindow.GetWindow())
+		return
+	}
+
+	ap.mainWindow.setStatus(fmt.Sprintf("Saved %d changes to profile '%s'", count, item.Name))
+
+	// Reload the profile but stay in edit mode
+	ap.pending = make(map[string]bool)
+	ap.updateDirty()
+	ap.mainWindow.refresh()
+	if selected := ap.mainWindow.profilePanel.GetSelectedItem(); selected != nil && selected.ID == item.ID {
+		ap.editingItem = selected
+		ap.Refresh()
+	}
+}
+
+// applyOneOff applies the edited addon set to AddOns.txt without changing
+// the saved profile
+func (ap *AddonPanel) applyOneOff() {
+	item := ap.editingItem
+	if item == nil {
+		return
+	}
+
+	edited := *item
+	edited.Profile = &lua.Profile{
+		Name:     item.Name + " (edited)",
+		Scope:    item.Profile.Scope,
+		Addons:   ap.editedAddons(),
+		AutoDeps: item.Profile.AutoDeps,
+		Created:  item.Profile.Created,
+	}
+
+	ap.mainWindow.actionPanel.confirmApply(&edited)
+}
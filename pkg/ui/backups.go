@@ -0,0 +1,272 @@
+package ui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
+)
+
+// accountTarget is the backup target entry for the account's AddOns.txt
+const accountTarget = "Account"
+
+// BackupsWindow lists AddOns.txt backups and restores, diffs and pins them
+type BackupsWindow struct {
+	mainWindow *MainWindow
+	window     fyne.Window
+
+	targetSelect *widget.Select
+	list         *widget.List
+	detailLabel  *widget.Label
+	restoreBtn   *widget.Button
+	diffBtn      *widget.Button
+	pinBtn       *widget.Button
+
+	// characters maps target entries to characters
+	characters map[string]wow.Character
+	backups    []wow.Backup
+	selected   int
+}
+
+// showBackups opens the backup browser
+func (mw *MainWindow) showBackups() {
+	if mw.manager == nil || mw.manager.SelectedAccount() == "" {
+		dialog.ShowError(fmt.Errorf("WoW manager not initialized"), mw.window)
+		return
+	}
+
+	bw := NewBackupsWindow(mw)
+	bw.window.Show()
+}
+
+// NewBackupsWindow creates the backup browser for the selected account
+func NewBackupsWindow(mw *MainWindow) *BackupsWindow {
+	bw := &BackupsWindow{
+		mainWindow:  mw,
+		detailLabel: widget.NewLabel("Select a backup"),
+		characters:  make(map[string]wow.Character),
+		selected:    -1,
+	}
+
+	bw.window = mw.app.NewWindow(fmt.Sprintf("Backups - %s", mw.manager.SelectedAccount()))
+	bw.window.Resize(fyne.NewSize(640, 480))
+
+	options := []string{accountTarget}
+	if chars, err := mw.manager.GetCharacters(); err == nil {
+		for _, char := range chars {
+			options = append(options, char.Key())
+			bw.characters[char.Key()] = char
+		}
+	}
+
+	bw.targetSelect = widget.NewSelect(options, func(string) {
+		bw.reload()
+	})
+
+	bw.list = widget.NewList(
+		func() int {
+			return len(bw.backups)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(formatBackup(bw.backups[id]))
+		},
+	)
+	bw.list.OnSelected = func(id widget.ListItemID) {
+		bw.selected = id
+		bw.updateDetails()
+	}
+	bw.list.OnUnselected = func(widget.ListItemID) {
+		bw.selected = -1
+		bw.updateDetails()
+	}
+
+	bw.restoreBtn = widget.NewButton("Restore", func() {
+		bw.restore()
+	})
+	bw.diffBtn = widget.NewButton("Show Changes", func() {
+		bw.showDiff()
+	})
+	bw.pinBtn = widget.NewButton("Pin", func() {
+		bw.togglePin()
+	})
+
+	header := container.NewBorder(nil, nil, widget.NewLabel("AddOns.txt of:"), nil, bw.targetSelect)
+	footer := container.NewVBox(
+		widget.NewSeparator(),
+		bw.detailLabel,
+		container.NewHBox(bw.restoreBtn, bw.diffBtn, bw.pinBtn),
+	)
+
+	bw.window.SetContent(container.NewBorder(header, footer, nil, nil, bw.list))
+
+	// Selecting the target loads the list
+	bw.targetSelect.SetSelected(accountTarget)
+
+	return bw
+}
+
+// target returns the character whose backups are shown, or nil for the
+// account
+func (bw *BackupsWindow) target() *wow.Character {
+	char, ok := bw.characters[bw.targetSelect.Selected]
+	if !ok {
+		return nil
+	}
+	return &char
+}
+
+// reload lists the backups of the selected target
+func (bw *BackupsWindow) reload() {
+	bw.list.UnselectAll()
+	bw.selected = -1
+
+	backups, err := bw.mainWindow.manager.ListBackups(bw.target())
+	if err != nil {
+		dialog.ShowError(err, bw.window)
+		backups = nil
+	}
+	bw.backups = backups
+
+	bw.list.Refresh()
+	bw.updateDetails()
+}
+
+// updateDetails shows the selected backup and enables its actions
+func (bw *BackupsWindow) updateDetails() {
+	backup := bw.selectedBackup()
+	if backup == nil {
+		if len(bw.backups) == 0 {
+			bw.detailLabel.SetText("No backups yet. One is taken every time a profile is applied.")
+		} else {
+			bw.detailLabel.SetText("Select a backup")
+		}
+		bw.restoreBtn.Disable()
+		bw.diffBtn.Disable()
+		bw.pinBtn.Disable()
+		bw.pinBtn.SetText("Pin")
+		return
+	}
+
+	detail := backup.Name
+	if backup.Reason != "" {
+		detail += "\n" + backup.Reason
+	}
+	if backup.Pinned {
+		detail += "\nPinned: kept regardless of the backup limit"
+	}
+	bw.detailLabel.SetText(detail)
+
+	bw.restoreBtn.Enable()
+	bw.diffBtn.Enable()
+	bw.pinBtn.Enable()
+	if backup.Pinned {
+		bw.pinBtn.SetText("Unpin")
+	} else {
+		bw.pinBtn.SetText("Pin")
+	}
+}
+
+// selectedBackup returns the selected backup, or nil
+func (bw *BackupsWindow) selectedBackup() *wow.Backup {
+	if bw.selected < 0 || bw.selected >= len(bw.backups) {
+		return nil
+	}
+	return &bw.backups[bw.selected]
+}
+
+// restore confirms and restores the selected backup
+func (bw *BackupsWindow) restore() {
+	backup := bw.selectedBackup()
+	if backup == nil {
+		return
+	}
+	name := backup.Name
+
+	diff, err := bw.mainWindow.manager.DiffBackup(bw.target(), name)
+	if err != nil {
+		dialog.ShowError(err, bw.window)
+		return
+	}
+
+	message := widget.NewLabel(fmt.Sprintf("Restore the backup from %s?\n\nThe current AddOns.txt will be backed up first.",
+		backup.Created.Format("2006-01-02 15:04:05")))
+
+	confirm := dialog.NewCustomConfirm(
+		"Restore Backup",
+		"Restore",
+		"Cancel",
+		container.NewBorder(message, nil, nil, nil, newDiffView(diff, "AddOns.txt already matches this backup.")),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			if err := bw.mainWindow.manager.RestoreBackup(bw.target(), name); err != nil {
+				dialog.ShowError(err, bw.window)
+				return
+			}
+
+			bw.mainWindow.setStatus(fmt.Sprintf("Restored %s", name))
+			bw.mainWindow.refresh()
+			bw.reload()
+		},
+		bw.window,
+	)
+	confirm.Resize(fyne.NewSize(480, 480))
+	confirm.Show()
+}
+
+// showDiff shows what restoring the selected backup would change
+func (bw *BackupsWindow) showDiff() {
+	backup := bw.selectedBackup()
+	if backup == nil {
+		return
+	}
+
+	diff, err := bw.mainWindow.manager.DiffBackup(bw.target(), backup.Name)
+	if err != nil {
+		dialog.ShowError(err, bw.window)
+		return
+	}
+
+	d := dialog.NewCustom("Changes on Restore", "Close",
+		newDiffView(diff, "AddOns.txt already matches this backup."), bw.window)
+	d.Resize(fyne.NewSize(480, 480))
+	d.Show()
+}
+
+// togglePin pins or unpins the selected backup
+func (bw *BackupsWindow) togglePin() {
+	backup := bw.selectedBackup()
+	if backup == nil {
+		return
+	}
+
+	if err := bw.mainWindow.manager.PinBackup(bw.target(), backup.Name, !backup.Pinned); err != nil {
+		dialog.ShowError(err, bw.window)
+		return
+	}
+
+	bw.reload()
+}
+
+// formatBackup returns the list text of a backup
+func formatBackup(backup wow.Backup) string {
+	text := backup.Created.Format("2006-01-02 15:04:05")
+	if backup.Profile != "" {
+		text += fmt.Sprintf("  -  %s", backup.Profile)
+	} else if backup.Reason != "" {
+		text += fmt.Sprintf("  -  %s", backup.Reason)
+	}
+	if backup.Pinned {
+		text += "  (pinned)"
+	}
+	return text
+}
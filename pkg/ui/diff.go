@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
+)
+
+// newDiffView renders a diff as counts with expandable addon lists.
+// unchanged is shown instead of the lists when nothing would change.
+func newDiffView(diff *wow.ProfileDiff, unchanged string) fyne.CanvasObject {
+	summary := widget.NewLabel(fmt.Sprintf(
+		"%d enabled, %d disabled, %d added, %d removed, %d unchanged",
+		len(diff.Enabled), len(diff.Disabled), len(diff.Added), len(diff.Removed), len(diff.Unchanged)))
+	summary.TextStyle = fyne.TextStyle{Bold: true}
+
+	if !diff.HasChanges() {
+		return container.NewVBox(summary, widget.NewLabel(unchanged))
+	}
+
+	sections := []struct {
+		title string
+		names []string
+	}{
+		{"Disabled → Enabled", diff.Enabled},
+		{"Enabled → Disabled", diff.Disabled},
+		{"Added", diff.Added},
+		{"Removed from AddOns.txt", diff.Removed},
+		{"Unchanged", diff.Unchanged},
+	}
+
+	accordion := widget.NewAccordion()
+	for _, section := range sections {
+		if len(section.names) == 0 {
+			continue
+		}
+
+		names := section.names
+		if section.title == "Added" {
+			// Added addons can be written either way, so show their state
+			names = make([]string, 0, len(section.names))
+			for _, name := range section.names {
+				if diff.Result[name] {
+					names = append(names, name+" (enabled)")
+				} else {
+					names = append(names, name+" (disabled)")
+				}
+			}
+		}
+
+		label := widget.NewLabel(strings.Join(names, "\n"))
+		accordion.Append(widget.NewAccordionItem(
+			fmt.Sprintf("%s (%d)", section.title, len(section.names)), label))
+	}
+
+	// Open the first section so the most important change is visible
+	accordion.Open(0)
+
+	details := []fyne.CanvasObject{summary}
+	if report := formatDependencyReport(diff.Dependencies); report != "" {
+		details = append(details, widget.NewLabel(strings.TrimSpace(report)))
+	}
+
+	return container.NewBorder(
+		container.NewVBox(details...),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(accordion),
+	)
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -10,6 +11,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/jmervine/AddonProfiles-GUI/pkg/config"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
 	"github.com/jmervine/AddonProfiles-GUI/pkg/version"
 	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
 )
@@ -28,8 +30,39 @@ type MainWindow struct {
 	addonPanel   *AddonPanel
 	actionPanel  *ActionPanel
 
-	statusLabel  *widget.Label
-	wowPathLabel *widget.Label
+	statusLabel   *widget.Label
+	wowPathLabel  *widget.Label
+	accountSelect *widget.Select
+	flavorSelect  *widget.Select
+
+	// accountOptions maps account picker entries to account names
+	accountOptions map[string]string
+	// flavorOptions maps flavor picker entries to client directories
+	flavorOptions map[string]string
+	// updatingPickers suppresses OnChanged while the pickers are rebuilt
+	updatingPickers bool
+	// shownWarnings holds the manager warnings already shown in a dialog
+	shownWarnings map[string]bool
+}
+
+// warningWriter shows the manager's warnings in the main window
+type warningWriter struct {
+	mw *MainWindow
+}
+
+// Write shows each warning line in the status bar and, the first time it
+// is seen, in a dialog so a later status can't hide it
+func (w warningWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSpace(string(p)), "\n") {
+		if line == "" {
+			continue
+		}
+		line := line
+		fyne.Do(func() {
+			w.mw.showWarning(line)
+		})
+	}
+	return len(p), nil
 }
 
 // NewMainWindow creates a new main window
@@ -44,6 +77,9 @@ func NewMainWindow(app fyne.App, cfg *config.Config) *MainWindow {
 	mw.window.Resize(fyne.NewSize(1000, 600))
 	mw.window.CenterOnScreen()
 
+	// Pick a client for install roots configured before flavors existed
+	mw.ensureFlavor()
+
 	// Check if WoW path is configured
 	if cfg.WowInstallPath == "" {
 		mw.showWowPathDialog()
@@ -93,6 +129,8 @@ func (mw *MainWindow) selectWowPath() {
 
 		// Save path
 		mw.config.WowInstallPath = path
+		mw.config.Flavor = ""
+		mw.ensureFlavor()
 		if err := mw.config.Save(); err != nil {
 			dialog.ShowError(err, mw.window)
 			return
@@ -103,7 +141,11 @@ func (mw *MainWindow) selectWowPath() {
 			mw.wowPathLabel.SetText("WoW Installation: " + path)
 		}
 
+		mw.config.SelectedAccount = ""
 		mw.initializeManager()
+		mw.refreshFlavors()
+		mw.refreshAccounts()
+		mw.profilePanel.ClearSelection()
 		mw.refresh()
 		mw.setStatus("WoW directory configured successfully")
 	}, mw.window)
@@ -115,14 +157,13 @@ func (mw *MainWindow) initializeManager() {
 		return
 	}
 
-	// Auto-select first account if none selected
-	if mw.config.SelectedAccount == "" {
-		mgr := wow.NewManager(mw.config.WowInstallPath, "", 5)
-		accounts, err := mgr.GetAccounts()
-		if err == nil && len(accounts) > 0 {
-			mw.config.SelectedAccount = accounts[0]
-			mw.config.Save()
-		}
+	// Auto-select first account if none is selected or it no longer exists
+	mgr := wow.NewManager(mw.config.WowInstallPath, "", mw.config.BackupCount)
+	mgr.SetFlavor(mw.config.Flavor)
+	accounts, err := mgr.GetAccounts()
+	if err == nil && len(accounts) > 0 && !containsString(accounts, mw.config.SelectedAccount) {
+		mw.config.SelectedAccount = accounts[0]
+		mw.config.Save()
 	}
 
 	mw.manager = wow.NewManager(
@@ -130,18 +171,245 @@ func (mw *MainWindow) initializeManager() {
 		mw.config.SelectedAccount,
 		mw.config.BackupCount,
 	)
+	mw.manager.SetFlavor(mw.config.Flavor)
+	mw.manager.SetWarningOutput(warningWriter{mw: mw})
+
+	mode, err := wow.ParseApplyMode(mw.config.ApplyMode)
+	if err != nil {
+		mode = wow.ApplyModeMerge
+	}
+	mw.manager.SetApplyMode(mode)
+
+	if mw.config.StrictParsing {
+		mw.manager.SetParseOptions(lua.ParseOptions{Mode: lua.ParseStrict})
+	}
+}
+
+// ensureFlavor selects a client when the install path is a root folder
+// containing _retail_, _classic_ and so on, and none is configured yet
+func (mw *MainWindow) ensureFlavor() {
+	if mw.config.WowInstallPath == "" || mw.config.Flavor != "" {
+		return
+	}
+
+	if flavor := wow.DefaultFlavor(mw.config.WowInstallPath); flavor != "" {
+		mw.config.Flavor = flavor
+		mw.config.Save()
+	}
+}
+
+// refreshFlavors rebuilds the flavor picker from the install root
+func (mw *MainWindow) refreshFlavors() {
+	if mw.flavorSelect == nil {
+		return
+	}
+
+	mw.updatingPickers = true
+	defer func() { mw.updatingPickers = false }()
+
+	mw.flavorOptions = make(map[string]string)
+
+	var flavors []wow.Flavor
+	if mw.config.WowInstallPath != "" {
+		flavors, _ = wow.DetectFlavors(mw.config.WowInstallPath)
+	}
+
+	var options []string
+	selected := ""
+	for _, flavor := range flavors {
+		options = append(options, flavor.Name)
+		mw.flavorOptions[flavor.Name] = flavor.Dir
+		if flavor.Dir == mw.config.Flavor {
+			selected = flavor.Name
+		}
+	}
+
+	mw.flavorSelect.SetOptions(options)
+	if selected != "" {
+		mw.flavorSelect.SetSelected(selected)
+	} else {
+		mw.flavorSelect.ClearSelected()
+	}
+
+	if len(options) > 1 {
+		mw.flavorSelect.Enable()
+	} else {
+		mw.flavorSelect.Disable()
+	}
+}
+
+// selectFlavor switches to another client and reloads accounts and panels
+func (mw *MainWindow) selectFlavor(option string) {
+	if mw.updatingPickers {
+		return
+	}
+
+	flavor, ok := mw.flavorOptions[option]
+	if !ok || flavor == mw.config.Flavor {
+		return
+	}
+
+	mw.config.Flavor = flavor
+	if err := mw.config.Save(); err != nil {
+		dialog.ShowError(err, mw.window)
+	}
+
+	mw.initializeManager()
+	mw.refreshAccounts()
+	mw.profilePanel.ClearSelection()
+	mw.refresh()
+	mw.setStatus(fmt.Sprintf("Switched to %s", option))
+}
+
+// refreshAccounts rebuilds the account picker from the WTF directory
+func (mw *MainWindow) refreshAccounts() {
+	if mw.accountSelect == nil {
+		return
+	}
+
+	mw.updatingPickers = true
+	defer func() { mw.updatingPickers = false }()
+
+	mw.accountOptions = make(map[string]string)
+	if mw.config.WowInstallPath == "" {
+		mw.accountSelect.SetOptions(nil)
+		mw.accountSelect.ClearSelected()
+		mw.accountSelect.Disable()
+		return
+	}
+
+	mgr := wow.NewManager(mw.config.WowInstallPath, "", mw.config.BackupCount)
+	mgr.SetFlavor(mw.config.Flavor)
+	summaries, err := mgr.GetAccountSummaries()
+	if err != nil {
+		mw.setStatus(fmt.Sprintf("Error loading accounts: %v", err))
+		mw.accountSelect.SetOptions(nil)
+		mw.accountSelect.ClearSelected()
+		mw.accountSelect.Disable()
+		return
+	}
+
+	var options []string
+	selected := ""
+	for _, summary := range summaries {
+		option := formatAccountSummary(summary)
+		options = append(options, option)
+		mw.accountOptions[option] = summary.Name
+		if summary.Name == mw.config.SelectedAccount {
+			selected = option
+		}
+	}
+
+	mw.accountSelect.SetOptions(options)
+	if selected != "" {
+		mw.accountSelect.SetSelected(selected)
+	} else {
+		mw.accountSelect.ClearSelected()
+	}
+
+	if len(options) > 1 {
+		mw.accountSelect.Enable()
+	} else {
+		mw.accountSelect.Disable()
+	}
+}
+
+// selectAccount switches to another account and reloads every panel
+func (mw *MainWindow) selectAccount(option string) {
+	if mw.updatingPickers {
+		return
+	}
+
+	account, ok := mw.accountOptions[option]
+	if !ok || account == mw.config.SelectedAccount {
+		return
+	}
+
+	mw.config.SelectedAccount = account
+	if err := mw.config.Save(); err != nil {
+		dialog.ShowError(err, mw.window)
+	}
+
+	mw.initializeManager()
+	mw.profilePanel.ClearSelection()
+	mw.refresh()
+	mw.setStatus(fmt.Sprintf("Switched to account %s", account))
+}
+
+// formatAccountSummary returns the account picker text for an account
+func formatAccountSummary(summary wow.AccountSummary) string {
+	switch {
+	case !summary.HasProfilesDB:
+		return fmt.Sprintf("%s (no AddonProfilesDB.lua)", summary.Name)
+	case summary.Err != nil:
+		return fmt.Sprintf("%s (unreadable AddonProfilesDB.lua)", summary.Name)
+	case summary.ProfileCount == 1:
+		return fmt.Sprintf("%s (1 profile)", summary.Name)
+	default:
+		return fmt.Sprintf("%s (%d profiles)", summary.Name, summary.ProfileCount)
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleApplyMode switches between merging into and replacing AddOns.txt
+func (mw *MainWindow) toggleApplyMode(item *fyne.MenuItem) {
+	mode := wow.ApplyModeMerge
+	if item.Checked {
+		mode = wow.ApplyModeReplace
+	}
+
+	mw.config.ApplyMode = mode.String()
+	if err := mw.config.Save(); err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+
+	if mw.manager != nil {
+		mw.manager.SetApplyMode(mode)
+	}
+
+	item.Checked = mode == wow.ApplyModeMerge
+	mw.window.MainMenu().Refresh()
+
+	if item.Checked {
+		mw.setStatus("AddOns not in a profile will be kept and disabled")
+	} else {
+		mw.setStatus("AddOns not in a profile will be removed from AddOns.txt")
+	}
 }
 
 // setupUI sets up the main UI layout
 func (mw *MainWindow) setupUI() {
 	// Create menu
+	keepAddonsItem := fyne.NewMenuItem("Keep AddOns Not in Profile", nil)
+	keepAddonsItem.Checked = mw.config.ApplyMode != "replace"
+	keepAddonsItem.Action = func() {
+		mw.toggleApplyMode(keepAddonsItem)
+	}
+
 	fileMenu := fyne.NewMenu("File",
 		fyne.NewMenuItem("Select WoW Installation", func() {
 			mw.selectWowPath()
 		}),
 		fyne.NewMenuItem("Refresh", func() {
+			mw.refreshFlavors()
+			mw.refreshAccounts()
 			mw.refresh()
 		}),
+		fyne.NewMenuItem("Backups...", func() {
+			mw.showBackups()
+		}),
+		fyne.NewMenuItemSeparator(),
+		keepAddonsItem,
 		fyne.NewMenuItemSeparator(),
 		fyne.NewMenuItem("Exit", func() {
 			mw.app.Quit()
@@ -173,11 +441,25 @@ func (mw *MainWindow) setupUI() {
 		mw.selectWowPath()
 	})
 
+	mw.flavorSelect = widget.NewSelect(nil, mw.selectFlavor)
+	mw.flavorSelect.PlaceHolder = "Single client"
+	mw.refreshFlavors()
+
+	mw.accountSelect = widget.NewSelect(nil, mw.selectAccount)
+	mw.accountSelect.PlaceHolder = "No accounts found"
+	mw.refreshAccounts()
+
 	header := container.NewBorder(
 		nil,
 		nil,
 		mw.wowPathLabel,
-		changePathBtn,
+		container.NewHBox(
+			widget.NewLabel("Game:"),
+			mw.flavorSelect,
+			widget.NewLabel("Account:"),
+			mw.accountSelect,
+			changePathBtn,
+		),
 		nil,
 	)
 
@@ -231,6 +513,20 @@ func (mw *MainWindow) setStatus(text string) {
 	mw.statusLabel.SetText(text)
 }
 
+// showWarning shows a manager warning
+func (mw *MainWindow) showWarning(text string) {
+	mw.setStatus(text)
+	if mw.shownWarnings[text] {
+		return
+	}
+	if mw.shownWarnings == nil {
+		mw.shownWarnings = make(map[string]bool)
+	}
+	mw.shownWarnings[text] = true
+
+	dialog.ShowInformation("Warning", strings.TrimPrefix(text, "Warning: "), mw.window)
+}
+
 // showAbout shows the about dialog
 func (mw *MainWindow) showAbout() {
 	dialog.ShowInformation("About",
@@ -0,0 +1,175 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/wow"
+)
+
+// newProfile saves the enabled addons of an AddOns.txt as a new profile of
+// the account or a character
+func (ap *ActionPanel) newProfile() {
+	mgr := ap.mainWindow.GetManager()
+	if mgr == nil {
+		dialog.ShowError(fmt.Errorf("WoW manager not initialized"), ap.mainWindow.GetWindow())
+		return
+	}
+
+	// Characters can only be targeted once they have a folder, i.e. have
+	// logged in at least once
+	characters := make(map[string]wow.Character)
+	options := []string{accountTarget}
+	if chars, err := mgr.GetCharacters(); err == nil {
+		for _, char := range chars {
+			options = append(options, char.Key())
+			characters[char.Key()] = char
+		}
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Profile name")
+
+	targetSelect := widget.NewSelect(options, nil)
+	targetSelect.SetSelected(accountTarget)
+
+	// Start from the selected character's profiles
+	if item := ap.mainWindow.profilePanel.GetSelectedItem(); item != nil && item.CharacterKey != "" {
+		if _, ok := characters[item.CharacterKey]; ok {
+			targetSelect.SetSelected(item.CharacterKey)
+		}
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		{Text: "Save to", Widget: targetSelect, HintText: "Uses the addons enabled in its AddOns.txt"},
+	}
+
+	form := dialog.NewForm("New Profile", "Create", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		var char *wow.Character
+		charKey := ""
+		if c, ok := characters[targetSelect.Selected]; ok {
+			char = &c
+			charKey = c.Key()
+		}
+
+		profile, err := mgr.CreateProfileFromAddons(nameEntry.Text, char)
+		if err != nil {
+			dialog.ShowError(err, ap.mainWindow.GetWindow())
+			return
+		}
+
+		ap.mainWindow.setStatus(fmt.Sprintf("Created profile '%s' with %d addons", profile.Name, len(profile.Addons)))
+		ap.mainWindow.refresh()
+		ap.mainWindow.profilePanel.SelectProfile(charKey, profile.Name)
+	}, ap.mainWindow.GetWindow())
+	form.Resize(fyne.NewSize(400, 200))
+	form.Show()
+}
+
+// renameProfile renames the selected profile
+func (ap *ActionPanel) renameProfile() {
+	item, mgr, char, ok := ap.selectedForEdit()
+	if !ok {
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(item.Name)
+
+	form := dialog.NewForm("Rename Profile", "Rename", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Name", nameEntry)},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			if err := mgr.RenameProfile(item.Name, nameEntry.Text, char); err != nil {
+				dialog.ShowError(err, ap.mainWindow.GetWindow())
+				return
+			}
+
+			ap.mainWindow.setStatus(fmt.Sprintf("Renamed profile '%s'", item.Name))
+			ap.mainWindow.refresh()
+			ap.mainWindow.profilePanel.SelectProfile(item.CharacterKey, strings.TrimSpace(nameEntry.Text))
+		}, ap.mainWindow.GetWindow())
+	form.Resize(fyne.NewSize(400, 150))
+	form.Show()
+}
+
+// deleteProfile deletes the selected profile after confirmation
+func (ap *ActionPanel) deleteProfile() {
+	item, mgr, char, ok := ap.selectedForEdit()
+	if !ok {
+		return
+	}
+
+	dialog.ShowConfirm("Delete Profile",
+		fmt.Sprintf("Delete profile '%s' (%s)?\n\nA backup of AddonProfilesDB.lua is kept.", item.Name, describeScope(item)),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			if err := mgr.DeleteProfile(item.Name, char); err != nil {
+				dialog.ShowError(err, ap.mainWindow.GetWindow())
+				return
+			}
+
+			ap.mainWindow.setStatus(fmt.Sprintf("Deleted profile '%s'", item.Name))
+			ap.mainWindow.profilePanel.ClearSelection()
+			ap.mainWindow.refresh()
+		}, ap.mainWindow.GetWindow())
+}
+
+// activateProfile marks the selected profile as the active one
+func (ap *ActionPanel) activateProfile() {
+	item, mgr, char, ok := ap.selectedForEdit()
+	if !ok {
+		return
+	}
+
+	if err := mgr.SetActiveProfile(item.Name, char); err != nil {
+		dialog.ShowError(err, ap.mainWindow.GetWindow())
+		return
+	}
+
+	ap.mainWindow.setStatus(fmt.Sprintf("Profile '%s' is now active", item.Name))
+	ap.mainWindow.refresh()
+}
+
+// selectedForEdit returns the selected profile with the manager and the
+// character it belongs to, showing an error if it can't be edited
+func (ap *ActionPanel) selectedForEdit() (*ProfileItem, *wow.Manager, *wow.Character, bool) {
+	item := ap.mainWindow.profilePanel.GetSelectedItem()
+	if item == nil {
+		return nil, nil, nil, false
+	}
+
+	mgr := ap.mainWindow.GetManager()
+	if mgr == nil {
+		dialog.ShowError(fmt.Errorf("WoW manager not initialized"), ap.mainWindow.GetWindow())
+		return nil, nil, nil, false
+	}
+
+	// Character profiles are stored by key, so an unknown folder is fine
+	var char *wow.Character
+	if item.CharacterKey != "" {
+		c, err := wow.ParseCharacterKey(item.CharacterKey)
+		if err != nil {
+			dialog.ShowError(err, ap.mainWindow.GetWindow())
+			return nil, nil, nil, false
+		}
+		char = &c
+	}
+
+	return item, mgr, char, true
+}
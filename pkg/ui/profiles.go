@@ -2,66 +2,92 @@ package ui
 
 import (
 	"fmt"
+	"sort"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
 )
 
-// ProfilePanel displays the profile list
+// ProfilePanel displays the profile list grouped by scope
 type ProfilePanel struct {
-	mainWindow      *MainWindow
-	container       *fyne.Container
-	profileList     *widget.List
-	selectedProfile *lua.Profile
-	selectedScope   string
-	profiles        []*ProfileItem
+	mainWindow   *MainWindow
+	container    *fyne.Container
+	profileTree  *widget.Tree
+	selectedItem *ProfileItem
+	groups       []*ProfileGroup
+	items        map[widget.TreeNodeID]*ProfileItem
+	// parseWarning is the last damaged-file warning shown, so it isn't
+	// shown again on every refresh
+	parseWarning string
+}
+
+// ProfileGroup is a scope heading in the profile tree: the account or a
+// single "Character - Realm" key
+type ProfileGroup struct {
+	ID       widget.TreeNodeID
+	Title    string
+	Profiles []*ProfileItem
 }
 
 // ProfileItem represents a profile in the list
 type ProfileItem struct {
-	Name     string
-	Scope    string
-	IsActive bool
-	Profile  *lua.Profile
+	ID           widget.TreeNodeID
+	Name         string
+	Scope        string
+	CharacterKey string // "Character - Realm" for character profiles
+	IsActive     bool
+	Profile      *lua.Profile
 }
 
 // NewProfilePanel creates a new profile panel
 func NewProfilePanel(mw *MainWindow) *ProfilePanel {
 	pp := &ProfilePanel{
 		mainWindow: mw,
-		profiles:   []*ProfileItem{},
+		groups:     []*ProfileGroup{},
+		items:      make(map[widget.TreeNodeID]*ProfileItem),
 	}
 
-	pp.profileList = widget.NewList(
-		func() int {
-			return len(pp.profiles)
-		},
-		func() fyne.CanvasObject {
+	pp.profileTree = widget.NewTree(
+		pp.childIDs,
+		pp.isGroup,
+		func(branch bool) fyne.CanvasObject {
 			return widget.NewLabel("Profile Name")
 		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
+		func(id widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
 			label := obj.(*widget.Label)
-			if id < len(pp.profiles) {
-				item := pp.profiles[id]
+			if group := pp.findGroup(id); group != nil {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(fmt.Sprintf("%s (%d)", group.Title, len(group.Profiles)))
+				return
+			}
+
+			if item, ok := pp.items[id]; ok {
 				prefix := ""
 				if item.IsActive {
 					prefix = "* "
 				}
-				label.SetText(fmt.Sprintf("%s%s (%s)", prefix, item.Name, item.Scope))
+				label.TextStyle = fyne.TextStyle{}
+				label.SetText(prefix + item.Name)
 			}
 		},
 	)
 
-	pp.profileList.OnSelected = func(id widget.ListItemID) {
-		if id < len(pp.profiles) {
-			pp.selectedProfile = pp.profiles[id].Profile
-			pp.selectedScope = pp.profiles[id].Scope
-			mw.addonPanel.Refresh()
-			mw.actionPanel.Refresh()
+	pp.profileTree.OnSelected = func(id widget.TreeNodeID) {
+		item, ok := pp.items[id]
+		if !ok {
+			// Groups only expand and collapse
+			pp.profileTree.Unselect(id)
+			pp.profileTree.ToggleBranch(id)
+			return
 		}
+
+		pp.selectedItem = item
+		mw.addonPanel.Refresh()
+		mw.actionPanel.Refresh()
 	}
 
 	refreshBtn := widget.NewButton("Refresh Profiles", func() {
@@ -73,7 +99,7 @@ func NewProfilePanel(mw *MainWindow) *ProfilePanel {
 		refreshBtn,
 		nil,
 		nil,
-		pp.profileList,
+		pp.profileTree,
 	)
 
 	return pp
@@ -84,6 +110,43 @@ func (pp *ProfilePanel) Container() *fyne.Container {
 	return pp.container
 }
 
+// childIDs returns the tree children of a node
+func (pp *ProfilePanel) childIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	if id == "" {
+		ids := make([]widget.TreeNodeID, 0, len(pp.groups))
+		for _, group := range pp.groups {
+			ids = append(ids, group.ID)
+		}
+		return ids
+	}
+
+	group := pp.findGroup(id)
+	if group == nil {
+		return nil
+	}
+
+	ids := make([]widget.TreeNodeID, 0, len(group.Profiles))
+	for _, item := range group.Profiles {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+// isGroup reports whether a tree node is a scope heading
+func (pp *ProfilePanel) isGroup(id widget.TreeNodeID) bool {
+	return id == "" || pp.findGroup(id) != nil
+}
+
+// findGroup returns the group with the given tree ID
+func (pp *ProfilePanel) findGroup(id widget.TreeNodeID) *ProfileGroup {
+	for _, group := range pp.groups {
+		if group.ID == id {
+			return group
+		}
+	}
+	return nil
+}
+
 // Refresh reloads the profile list
 func (pp *ProfilePanel) Refresh() {
 	mgr := pp.mainWindow.GetManager()
@@ -91,32 +154,143 @@ func (pp *ProfilePanel) Refresh() {
 		return
 	}
 
-	db, err := mgr.LoadProfiles()
+	db, report, err := mgr.LoadProfilesWithReport()
 	if err != nil {
 		pp.mainWindow.setStatus(fmt.Sprintf("Error loading profiles: %v", err))
 		return
 	}
 
-	pp.profiles = []*ProfileItem{}
+	pp.groups = []*ProfileGroup{}
+	pp.items = make(map[widget.TreeNodeID]*ProfileItem)
 
 	// Add global profiles
+	accountGroup := &ProfileGroup{ID: "account", Title: "Account"}
 	for name, profile := range db.Global.Profiles {
-		pp.profiles = append(pp.profiles, &ProfileItem{
+		accountGroup.Profiles = append(accountGroup.Profiles, &ProfileItem{
+			ID:       profileItemID("", name),
 			Name:     name,
 			Scope:    "account",
 			IsActive: name == db.Global.ActiveProfile,
 			Profile:  profile,
 		})
 	}
+	pp.addGroup(accountGroup)
+
+	// Add character profiles, one group per "Character - Realm" key
+	var charKeys []string
+	for charKey := range db.Char {
+		charKeys = append(charKeys, charKey)
+	}
+	sort.Strings(charKeys)
+
+	for _, charKey := range charKeys {
+		charData := db.Char[charKey]
+		group := &ProfileGroup{ID: "char/" + charKey, Title: charKey}
+		for name, profile := range charData.Profiles {
+			group.Profiles = append(group.Profiles, &ProfileItem{
+				ID:           profileItemID(charKey, name),
+				Name:         name,
+				Scope:        "character",
+				CharacterKey: charKey,
+				IsActive:     name == charData.ActiveProfile,
+				Profile:      profile,
+			})
+		}
+		if len(group.Profiles) > 0 {
+			pp.addGroup(group)
+		}
+	}
+
+	// Keep the selection if the profile still exists
+	if pp.selectedItem != nil {
+		if item, ok := pp.items[pp.selectedItem.ID]; ok {
+			pp.selectedItem = item
+		} else {
+			pp.selectedItem = nil
+			pp.profileTree.UnselectAll()
+		}
+	}
 
-	// Add character profiles (current character only for simplicity)
-	// You could expand this to show all characters
+	pp.profileTree.Refresh()
+	pp.profileTree.OpenAllBranches()
+	pp.mainWindow.setStatus(fmt.Sprintf("Loaded %d profiles", len(pp.items)))
 
-	pp.profileList.Refresh()
-	pp.mainWindow.setStatus(fmt.Sprintf("Loaded %d profiles", len(pp.profiles)))
+	// Last, so a warning about why edits are refused stays in the status bar
+	pp.warnDamaged(report)
+}
+
+// addGroup sorts a group's profiles and registers them for lookup
+func (pp *ProfilePanel) addGroup(group *ProfileGroup) {
+	sort.Slice(group.Profiles, func(i, j int) bool {
+		return group.Profiles[i].Name < group.Profiles[j].Name
+	})
+
+	for _, item := range group.Profiles {
+		pp.items[item.ID] = item
+	}
+	pp.groups = append(pp.groups, group)
+}
+
+// SelectProfile selects a profile of the account, or of charKey if set,
+// once it's in the tree
+func (pp *ProfilePanel) SelectProfile(charKey, name string) {
+	id := profileItemID(charKey, name)
+	if _, ok := pp.items[id]; ok {
+		pp.profileTree.Select(id)
+	}
+}
+
+// profileItemID returns the tree node of an account or character profile
+func profileItemID(charKey, name string) widget.TreeNodeID {
+	if charKey == "" {
+		return "account/" + name
+	}
+	return "char/" + charKey + "/" + name
+}
+
+// ClearSelection drops the selected profile, e.g. after switching accounts
+func (pp *ProfilePanel) ClearSelection() {
+	pp.selectedItem = nil
+	pp.profileTree.UnselectAll()
 }
 
 // GetSelectedProfile returns the currently selected profile
 func (pp *ProfilePanel) GetSelectedProfile() (*lua.Profile, string) {
-	return pp.selectedProfile, pp.selectedScope
+	if pp.selectedItem == nil {
+		return nil, ""
+	}
+	return pp.selectedItem.Profile, pp.selectedItem.Scope
+}
+
+// GetSelectedItem returns the selected list entry, including its character
+func (pp *ProfilePanel) GetSelectedItem() *ProfileItem {
+	return pp.selectedItem
+}
+
+// warnDamaged tells the user where AddonProfilesDB.lua is damaged when it
+// could only be read by the lenient fallback parser
+func (pp *ProfilePanel) warnDamaged(report *lua.ParseReport) {
+	if !report.Recovered() {
+		pp.parseWarning = ""
+		if !report.Complete() {
+			pp.mainWindow.setStatus(fmt.Sprintf("AddonProfilesDB.lua has an entry of the wrong type (%v); profiles can't be edited until it is fixed", report.Errors[0]))
+		}
+		return
+	}
+
+	err := report.Errors[0]
+	pp.mainWindow.setStatus(fmt.Sprintf("AddonProfilesDB.lua is damaged (%v), read with the lenient fallback parser; some profiles may be missing", err))
+	if err.Error() == pp.parseWarning {
+		return
+	}
+	pp.parseWarning = err.Error()
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("AddonProfilesDB.lua could not be read completely:\n%v\n\nIt was read with the lenient fallback parser, so some profiles may be\nmissing, and profiles can't be edited until the file is fixed or\nrestored from a backup.", err)),
+	)
+	if parseErr, ok := err.(*lua.ParseError); ok {
+		content.Add(widget.NewLabelWithStyle(parseErr.Excerpt, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true}))
+	}
+
+	dialog.ShowCustom("Damaged Profiles File", "OK", content, pp.mainWindow.GetWindow())
 }
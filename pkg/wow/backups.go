@@ -0,0 +1,277 @@
+package wow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/fsutil"
+)
+
+// backupTimeFormat is the timestamp suffix of AddOns.txt backups
+const backupTimeFormat = "20060102_150405"
+
+// Backup is a saved copy of an AddOns.txt file
+type Backup struct {
+	Name    string    // file name, e.g. AddOns.txt.backup.20240101_120000
+	Path    string    // full path of the backup file
+	Created time.Time // when the backup was taken
+	Profile string    // profile whose apply triggered the backup, if any
+	Reason  string    // why the backup was taken, if known
+	Pinned  bool      // pinned backups are never pruned
+}
+
+// backupMeta is what the backup index records about a single backup
+type backupMeta struct {
+	Profile string `json:"profile,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Pinned  bool   `json:"pinned,omitempty"`
+}
+
+// AddOnsPath returns the AddOns.txt of a character, or of the selected
+// account when char is nil
+func (m *Manager) AddOnsPath(char *Character) string {
+	if char != nil {
+		return m.characterAddonsPath(*char)
+	}
+	return filepath.Join(m.accountPath(), "AddOns.txt")
+}
+
+// ListBackups returns the backups of a character's AddOns.txt, or of the
+// account's when char is nil, newest first
+func (m *Manager) ListBackups(char *Character) ([]Backup, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	return listBackups(m.AddOnsPath(char))
+}
+
+// RestoreBackup copies a backup over the AddOns.txt it was taken from.
+// The current file is backed up first so the restore can be undone.
+func (m *Manager) RestoreBackup(char *Character, name string) error {
+	if m.selectedAccount == "" {
+		return fmt.Errorf("no account selected")
+	}
+
+	if err := m.checkClient(); err != nil {
+		return err
+	}
+
+	addonsPath := m.AddOnsPath(char)
+	backup, err := findBackup(addonsPath, name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	meta := backupMeta{Profile: backup.Profile, Reason: fmt.Sprintf("Restored %s", backup.Name)}
+	if err := m.createBackup(addonsPath, meta); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(addonsPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	if err := m.cleanupBackups(addonsPath); err != nil {
+		// Warn but don't fail
+		m.warnf("failed to cleanup old backups: %v", err)
+	}
+
+	return nil
+}
+
+// DiffBackup computes what restoring a backup would change in the
+// current AddOns.txt, without writing anything
+func (m *Manager) DiffBackup(char *Character, name string) (*ProfileDiff, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	addonsPath := m.AddOnsPath(char)
+	backup, err := findBackup(addonsPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := readAddOns(addonsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AddOns.txt: %w", err)
+	}
+
+	after, err := readAddOnsEntries(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	diff := diffAddOns(before, after)
+	diff.Path = addonsPath
+	return diff, nil
+}
+
+// PinBackup pins or unpins a backup. Pinned backups don't count towards
+// the backup limit and are never removed by cleanup.
+func (m *Manager) PinBackup(char *Character, name string, pinned bool) error {
+	if m.selectedAccount == "" {
+		return fmt.Errorf("no account selected")
+	}
+
+	addonsPath := m.AddOnsPath(char)
+	if _, err := findBackup(addonsPath, name); err != nil {
+		return err
+	}
+
+	index, err := loadBackupIndex(addonsPath)
+	if err != nil {
+		return err
+	}
+
+	meta := index[name]
+	meta.Pinned = pinned
+	if meta == (backupMeta{}) {
+		delete(index, name)
+	} else {
+		index[name] = meta
+	}
+
+	if err := saveBackupIndex(addonsPath, index); err != nil {
+		return err
+	}
+
+	if !pinned {
+		// The backup may now be over the limit
+		if err := m.cleanupBackups(addonsPath); err != nil {
+			m.warnf("failed to cleanup old backups: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// listBackups finds the backups of addonsPath, newest first
+func listBackups(addonsPath string) ([]Backup, error) {
+	dir := filepath.Dir(addonsPath)
+	prefix := filepath.Base(addonsPath) + ".backup."
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	index, err := loadBackupIndex(addonsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		meta := index[entry.Name()]
+		backup := Backup{
+			Name:    entry.Name(),
+			Path:    filepath.Join(dir, entry.Name()),
+			Profile: meta.Profile,
+			Reason:  meta.Reason,
+			Pinned:  meta.Pinned,
+		}
+
+		// Prefer the timestamp in the name, it survives copying
+		stamp := strings.TrimPrefix(entry.Name(), prefix)
+		if len(stamp) > len(backupTimeFormat) && stamp[len(backupTimeFormat)] == '_' {
+			// Same-second backups get a _N suffix
+			stamp = stamp[:len(backupTimeFormat)]
+		}
+		created, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			// A backup removed since the directory was read is skipped
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			created = info.ModTime()
+		}
+		backup.Created = created
+
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Created.Equal(backups[j].Created) {
+			return backups[i].Created.After(backups[j].Created)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+
+	return backups, nil
+}
+
+// findBackup returns the backup of addonsPath with the given file name
+func findBackup(addonsPath, name string) (Backup, error) {
+	backups, err := listBackups(addonsPath)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	for _, backup := range backups {
+		if backup.Name == name {
+			return backup, nil
+		}
+	}
+
+	return Backup{}, fmt.Errorf("backup not found: %s", name)
+}
+
+// backupIndexPath returns the file that records backup metadata. Its name
+// deliberately doesn't share the backup prefix so it's never pruned.
+func backupIndexPath(addonsPath string) string {
+	return addonsPath + ".backups.json"
+}
+
+// loadBackupIndex reads the backup metadata of addonsPath, keyed by backup
+// file name. A missing index is empty.
+func loadBackupIndex(addonsPath string) (map[string]backupMeta, error) {
+	index := make(map[string]backupMeta)
+
+	data, err := os.ReadFile(backupIndexPath(addonsPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, fmt.Errorf("failed to read backup index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse backup index: %w", err)
+	}
+
+	return index, nil
+}
+
+// saveBackupIndex writes the backup metadata of addonsPath
+func saveBackupIndex(addonsPath string, index map[string]backupMeta) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup index: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(backupIndexPath(addonsPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+func TestBackupRecordsProfile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+	os.WriteFile(filepath.Join(accountDir, "AddOns.txt"), []byte("Details: 1\n"), 0644)
+
+	mgr := NewManager(tmpDir, account, 5)
+	profile := &lua.Profile{Name: "Raiding", Addons: map[string]bool{"DBM-Core": true}}
+
+	// Two applies within the same second must not overwrite each other
+	for i := 0; i < 2; i++ {
+		if _, err := mgr.ApplyProfile(profile); err != nil {
+			t.Fatalf("ApplyProfile() error = %v", err)
+		}
+	}
+
+	backups, err := mgr.ListBackups(nil)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+
+	for _, backup := range backups {
+		if backup.Profile != "Raiding" {
+			t.Errorf("Profile = %v, want %v", backup.Profile, "Raiding")
+		}
+		if backup.Reason == "" {
+			t.Errorf("Reason of %s is empty", backup.Name)
+		}
+		if backup.Created.IsZero() {
+			t.Errorf("Created of %s is zero", backup.Name)
+		}
+	}
+}
+
+func TestPinBackup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, []byte("Current: 1\n"), 0644)
+
+	names := []string{
+		"AddOns.txt.backup.20240101_120000",
+		"AddOns.txt.backup.20240201_120000",
+		"AddOns.txt.backup.20240301_120000",
+	}
+	for i, name := range names {
+		path := filepath.Join(accountDir, name)
+		os.WriteFile(path, []byte("test"), 0644)
+		mtime := time.Date(2024, time.Month(i+1), 1, 12, 0, 0, 0, time.Local)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	mgr := NewManager(tmpDir, account, 1)
+
+	if err := mgr.PinBackup(nil, names[0], true); err != nil {
+		t.Fatalf("PinBackup() error = %v", err)
+	}
+	if err := mgr.cleanupBackups(addonsPath); err != nil {
+		t.Fatalf("cleanupBackups() error = %v", err)
+	}
+
+	// The pinned backup survives and doesn't count towards the limit
+	backups, _ := mgr.ListBackups(nil)
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	if backups[0].Name != names[2] || backups[0].Pinned {
+		t.Errorf("backups[0] = %v (pinned %v), want %v unpinned", backups[0].Name, backups[0].Pinned, names[2])
+	}
+	if backups[1].Name != names[0] || !backups[1].Pinned {
+		t.Errorf("backups[1] = %v (pinned %v), want %v pinned", backups[1].Name, backups[1].Pinned, names[0])
+	}
+
+	// Unpinning makes it subject to the limit again
+	if err := mgr.PinBackup(nil, names[0], false); err != nil {
+		t.Fatalf("PinBackup() error = %v", err)
+	}
+	backups, _ = mgr.ListBackups(nil)
+	if len(backups) != 1 || backups[0].Name != names[2] {
+		t.Errorf("ListBackups() = %v, want only %v", backups, names[2])
+	}
+
+	if err := mgr.PinBackup(nil, "AddOns.txt.backup.19990101_000000", true); err == nil {
+		t.Error("Expected error for unknown backup")
+	}
+}
+
+func TestDiffBackup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, []byte("Details: 1\n# DBM-Core: 0\nWeakAuras: 1\n"), 0644)
+	os.WriteFile(addonsPath+".backup.20240101_120000", []byte("Details: 1\nDBM-Core: 1\nBigWigs: 1\n"), 0644)
+
+	mgr := NewManager(tmpDir, account, 5)
+	diff, err := mgr.DiffBackup(nil, "AddOns.txt.backup.20240101_120000")
+	if err != nil {
+		t.Fatalf("DiffBackup() error = %v", err)
+	}
+
+	assertNames(t, "Enabled", diff.Enabled, []string{"DBM-Core"})
+	assertNames(t, "Added", diff.Added, []string{"BigWigs"})
+	assertNames(t, "Removed", diff.Removed, []string{"WeakAuras"})
+	assertNames(t, "Unchanged", diff.Unchanged, []string{"Details"})
+
+	if diff.Path != addonsPath {
+		t.Errorf("Path = %v, want %v", diff.Path, addonsPath)
+	}
+
+	// Diffing doesn't touch the current file
+	addons, _ := mgr.GetActiveAddons()
+	if !addons["WeakAuras"] || addons["BigWigs"] {
+		t.Errorf("GetActiveAddons() = %v, want the file unchanged", addons)
+	}
+}
@@ -0,0 +1,151 @@
+package wow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+// Character identifies a character folder under WTF/Account/<account>/<realm>
+type Character struct {
+	Realm string
+	Name  string
+}
+
+// Key returns the "Name - Realm" key AddonProfilesDB uses for the character
+func (c Character) Key() string {
+	return c.Name + " - " + c.Realm
+}
+
+// ParseCharacterKey splits an AddonProfilesDB "Name - Realm" key.
+// Character names cannot contain spaces, so the first separator is used.
+func ParseCharacterKey(key string) (Character, error) {
+	parts := strings.SplitN(key, " - ", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Character{}, fmt.Errorf("invalid character key: %q", key)
+	}
+
+	return Character{Name: parts[0], Realm: parts[1]}, nil
+}
+
+// GetCharacters returns the characters of the selected account, sorted by
+// realm and then by name
+func (m *Manager) GetCharacters() ([]Character, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	realms, err := os.ReadDir(m.accountPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account directory: %w", err)
+	}
+
+	var characters []Character
+	for _, realm := range realms {
+		if !realm.IsDir() || realm.Name() == "SavedVariables" {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(m.accountPath(), realm.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read realm directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() != "SavedVariables" {
+				characters = append(characters, Character{Realm: realm.Name(), Name: entry.Name()})
+			}
+		}
+	}
+
+	sort.Slice(characters, func(i, j int) bool {
+		if characters[i].Realm != characters[j].Realm {
+			return characters[i].Realm < characters[j].Realm
+		}
+		return characters[i].Name < characters[j].Name
+	})
+
+	return characters, nil
+}
+
+// FindCharacter returns the character folder matching an AddonProfilesDB key
+func (m *Manager) FindCharacter(key string) (Character, error) {
+	wanted, err := ParseCharacterKey(key)
+	if err != nil {
+		return Character{}, err
+	}
+
+	characters, err := m.GetCharacters()
+	if err != nil {
+		return Character{}, err
+	}
+
+	for _, char := range characters {
+		if strings.EqualFold(char.Name, wanted.Name) && strings.EqualFold(char.Realm, wanted.Realm) {
+			return char, nil
+		}
+	}
+
+	return Character{}, fmt.Errorf("character %s not found in account %s", key, m.selectedAccount)
+}
+
+// characterAddonsPath returns the AddOns.txt path of a character
+func (m *Manager) characterAddonsPath(char Character) string {
+	return filepath.Join(m.accountPath(), char.Realm, char.Name, "AddOns.txt")
+}
+
+// GetCharacterAddons returns the addon states from a character's AddOns.txt
+func (m *Manager) GetCharacterAddons(char Character) (map[string]bool, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	return readAddOns(m.characterAddonsPath(char))
+}
+
+// ApplyProfileToCharacter applies a profile to a character's AddOns.txt
+func (m *Manager) ApplyProfileToCharacter(profile *lua.Profile, char Character) (*ApplyResult, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	if _, err := os.Stat(filepath.Dir(m.characterAddonsPath(char))); err != nil {
+		return nil, fmt.Errorf("character %s not found: %w", char.Key(), err)
+	}
+
+	return m.applyToFile(profile, m.characterAddonsPath(char))
+}
+
+// ApplyProfileToAllCharacters applies a profile to every character of the
+// selected account. A character that fails doesn't stop the others: the
+// results of the characters that were updated are returned along with the
+// joined errors of the ones that weren't.
+func (m *Manager) ApplyProfileToAllCharacters(profile *lua.Profile) ([]*ApplyResult, error) {
+	// Check up front so a running client doesn't leave characters half done
+	if err := m.checkClient(); err != nil {
+		return nil, err
+	}
+
+	characters, err := m.GetCharacters()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*ApplyResult
+	var errs []error
+	for _, char := range characters {
+		result, err := m.ApplyProfileToCharacter(profile, char)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply profile to %s: %w", char.Key(), err))
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
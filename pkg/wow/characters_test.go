@@ -0,0 +1,273 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+// setupCharacters creates an account with two realms and three characters
+func setupCharacters(t *testing.T) (string, string) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(filepath.Join(accountDir, "SavedVariables"), 0755)
+	os.MkdirAll(filepath.Join(accountDir, "Argent Dawn", "Alpha", "SavedVariables"), 0755)
+	os.MkdirAll(filepath.Join(accountDir, "Argent Dawn", "Bravo"), 0755)
+	os.MkdirAll(filepath.Join(accountDir, "Stormrage", "Charlie"), 0755)
+
+	// Files next to realms and characters should be ignored
+	os.WriteFile(filepath.Join(accountDir, "AddOns.txt"), []byte("Ace3: 1\n"), 0644)
+	os.WriteFile(filepath.Join(accountDir, "Stormrage", "notes.txt"), []byte("test"), 0644)
+
+	writeAddOnsFile(filepath.Join(accountDir, "Argent Dawn", "Alpha", "AddOns.txt"), map[string]bool{
+		"Ace3":    true,
+		"Details": false,
+	})
+
+	return tmpDir, account
+}
+
+func TestParseCharacterKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    Character
+		wantErr bool
+	}{
+		{key: "TestChar - TestRealm", want: Character{Realm: "TestRealm", Name: "TestChar"}},
+		{key: "Alpha - Argent Dawn", want: Character{Realm: "Argent Dawn", Name: "Alpha"}},
+		{key: "Bravo - Azjol-Nerub", want: Character{Realm: "Azjol-Nerub", Name: "Bravo"}},
+		{key: "NoRealm", wantErr: true},
+		{key: " - Realm", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := ParseCharacterKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCharacterKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCharacterKey() = %+v, want %+v", got, tt.want)
+			}
+			if !tt.wantErr && got.Key() != tt.key {
+				t.Errorf("Key() = %v, want %v", got.Key(), tt.key)
+			}
+		})
+	}
+}
+
+func TestGetCharacters(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, account, 5)
+	characters, err := mgr.GetCharacters()
+	if err != nil {
+		t.Fatalf("GetCharacters() error = %v", err)
+	}
+
+	expected := []Character{
+		{Realm: "Argent Dawn", Name: "Alpha"},
+		{Realm: "Argent Dawn", Name: "Bravo"},
+		{Realm: "Stormrage", Name: "Charlie"},
+	}
+
+	if len(characters) != len(expected) {
+		t.Fatalf("GetCharacters() = %v, want %v", characters, expected)
+	}
+
+	for i := range expected {
+		if characters[i] != expected[i] {
+			t.Errorf("GetCharacters()[%d] = %+v, want %+v", i, characters[i], expected[i])
+		}
+	}
+}
+
+func TestGetCharacterAddons(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, account, 5)
+
+	addons, err := mgr.GetCharacterAddons(Character{Realm: "Argent Dawn", Name: "Alpha"})
+	if err != nil {
+		t.Fatalf("GetCharacterAddons() error = %v", err)
+	}
+
+	if len(addons) != 2 || !addons["Ace3"] || addons["Details"] {
+		t.Errorf("GetCharacterAddons() = %v, want Ace3 enabled and Details disabled", addons)
+	}
+
+	// A character without AddOns.txt has no addon state yet
+	addons, err = mgr.GetCharacterAddons(Character{Realm: "Stormrage", Name: "Charlie"})
+	if err != nil {
+		t.Fatalf("GetCharacterAddons() error = %v", err)
+	}
+
+	if len(addons) != 0 {
+		t.Errorf("Expected no addons, got %v", addons)
+	}
+}
+
+func TestFindCharacter(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, account, 5)
+
+	char, err := mgr.FindCharacter("charlie - stormrage")
+	if err != nil {
+		t.Fatalf("FindCharacter() error = %v", err)
+	}
+
+	if char.Name != "Charlie" || char.Realm != "Stormrage" {
+		t.Errorf("FindCharacter() = %+v, want Charlie on Stormrage", char)
+	}
+
+	if _, err := mgr.FindCharacter("Delta - Stormrage"); err == nil {
+		t.Error("Expected error for unknown character")
+	}
+}
+
+func TestApplyProfileToCharacter(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetApplyMode(ApplyModeReplace)
+
+	profile := &lua.Profile{
+		Name:   "PvP",
+		Scope:  "character",
+		Addons: map[string]bool{"Gladius": true},
+	}
+
+	char := Character{Realm: "Argent Dawn", Name: "Alpha"}
+	result, err := mgr.ApplyProfileToCharacter(profile, char)
+	if err != nil {
+		t.Fatalf("ApplyProfileToCharacter() error = %v", err)
+	}
+
+	charDir := filepath.Join(tmpDir, "WTF", "Account", account, "Argent Dawn", "Alpha")
+	if result.Path != filepath.Join(charDir, "AddOns.txt") {
+		t.Errorf("Path = %v, want character AddOns.txt", result.Path)
+	}
+
+	addons, err := mgr.GetCharacterAddons(char)
+	if err != nil {
+		t.Fatalf("GetCharacterAddons() error = %v", err)
+	}
+
+	if len(addons) != 1 || !addons["Gladius"] {
+		t.Errorf("Character addons = %v, want only Gladius", addons)
+	}
+
+	// The character file gets its own backup
+	entries, _ := os.ReadDir(charDir)
+	backupFound := false
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "AddOns.txt.backup.") {
+			backupFound = true
+		}
+	}
+	if !backupFound {
+		t.Error("Backup file not created for character")
+	}
+
+	// The account file is untouched
+	accountAddons, err := mgr.GetActiveAddons()
+	if err != nil {
+		t.Fatalf("GetActiveAddons() error = %v", err)
+	}
+
+	if len(accountAddons) != 1 || !accountAddons["Ace3"] {
+		t.Errorf("Account addons = %v, want only Ace3", accountAddons)
+	}
+
+	if _, err := mgr.ApplyProfileToCharacter(profile, Character{Realm: "Nowhere", Name: "Nobody"}); err == nil {
+		t.Error("Expected error for unknown character")
+	}
+}
+
+func TestApplyProfileToAllCharacters(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, account, 5)
+
+	profile := &lua.Profile{
+		Name:   "Leveling",
+		Scope:  "account",
+		Addons: map[string]bool{"Questie": true},
+	}
+
+	results, err := mgr.ApplyProfileToAllCharacters(profile)
+	if err != nil {
+		t.Fatalf("ApplyProfileToAllCharacters() error = %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	characters, _ := mgr.GetCharacters()
+	for _, char := range characters {
+		addons, err := mgr.GetCharacterAddons(char)
+		if err != nil {
+			t.Fatalf("GetCharacterAddons() error = %v", err)
+		}
+		if !addons["Questie"] {
+			t.Errorf("Questie not enabled for %s", char.Key())
+		}
+	}
+}
+
+func TestApplyProfileToAllCharactersPartialFailure(t *testing.T) {
+	tmpDir, account := setupCharacters(t)
+	defer os.RemoveAll(tmpDir)
+
+	// A directory where Bravo's AddOns.txt should be makes that character fail
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(filepath.Join(accountDir, "Argent Dawn", "Bravo", "AddOns.txt"), 0755)
+
+	mgr := NewManager(tmpDir, account, 5)
+
+	profile := &lua.Profile{
+		Name:   "Leveling",
+		Scope:  "account",
+		Addons: map[string]bool{"Questie": true},
+	}
+
+	results, err := mgr.ApplyProfileToAllCharacters(profile)
+	if err == nil {
+		t.Fatal("ApplyProfileToAllCharacters() error = nil, want error for Bravo")
+	}
+	if !strings.Contains(err.Error(), "Bravo - Argent Dawn") {
+		t.Errorf("ApplyProfileToAllCharacters() error = %v, want it to name Bravo", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// The characters after the failing one are still updated
+	for _, char := range []Character{{Realm: "Argent Dawn", Name: "Alpha"}, {Realm: "Stormrage", Name: "Charlie"}} {
+		addons, err := mgr.GetCharacterAddons(char)
+		if err != nil {
+			t.Fatalf("GetCharacterAddons() error = %v", err)
+		}
+		if !addons["Questie"] {
+			t.Errorf("Questie not enabled for %s", char.Key())
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package wow
+
+import (
+	"sort"
+	"strings"
+)
+
+// DependencyReport describes what dependency resolution changed for a profile
+type DependencyReport struct {
+	Added   []string            // addons enabled because an enabled addon requires them
+	Missing map[string][]string // addon -> required dependencies that are not installed
+	Cycles  [][]string          // dependency cycles, each starting and ending with the same addon
+}
+
+// HasProblems reports whether any dependency is missing or cyclic
+func (r *DependencyReport) HasProblems() bool {
+	return len(r.Missing) > 0 || len(r.Cycles) > 0
+}
+
+// ResolveDependencies returns a copy of addons with the required dependencies
+// of every enabled addon enabled as well, following dependencies transitively.
+// Dependencies are matched against the catalog case-insensitively and added
+// under their installed folder name.
+func ResolveDependencies(catalog AddonCatalog, addons map[string]bool) (map[string]bool, *DependencyReport) {
+	resolved := make(map[string]bool, len(addons))
+	for name, enabled := range addons {
+		resolved[name] = enabled
+	}
+
+	report := &DependencyReport{
+		Missing: make(map[string][]string),
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		addon, ok := catalog.Lookup(name)
+		if !ok {
+			return
+		}
+
+		switch state[addon.Name] {
+		case visiting:
+			// Report the part of the stack that loops back to this addon
+			for i, entry := range stack {
+				if entry == addon.Name {
+					cycle := append([]string{}, stack[i:]...)
+					report.Cycles = append(report.Cycles, append(cycle, addon.Name))
+					break
+				}
+			}
+			return
+		case done:
+			return
+		}
+
+		state[addon.Name] = visiting
+		stack = append(stack, addon.Name)
+
+		for _, dep := range addon.Dependencies {
+			depAddon, ok := catalog.Lookup(dep)
+			if !ok {
+				report.Missing[addon.Name] = append(report.Missing[addon.Name], dep)
+				continue
+			}
+
+			key := findAddonKey(resolved, depAddon.Name)
+			if !resolved[key] {
+				resolved[key] = true
+				report.Added = append(report.Added, key)
+			}
+			visit(depAddon.Name)
+		}
+
+		stack = stack[:len(stack)-1]
+		state[addon.Name] = done
+	}
+
+	// Visit in name order so the report is stable
+	var names []string
+	for name, enabled := range addons {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		visit(name)
+	}
+
+	sort.Strings(report.Added)
+	return resolved, report
+}
+
+// findAddonKey returns the key addons already uses for name, ignoring case
+// like the client does, or name itself if the addon is not listed
+func findAddonKey(addons map[string]bool, name string) string {
+	if _, ok := addons[name]; ok {
+		return name
+	}
+	for key := range addons {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
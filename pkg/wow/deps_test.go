@@ -0,0 +1,181 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+func testCatalog() AddonCatalog {
+	return AddonCatalog{
+		"LibStub":   {Name: "LibStub"},
+		"Ace3":      {Name: "Ace3", Dependencies: []string{"LibStub"}},
+		"DBM-Core":  {Name: "DBM-Core", Dependencies: []string{"ace3"}},
+		"DBM-Raids": {Name: "DBM-Raids", Dependencies: []string{"DBM-Core"}},
+		"Broken":    {Name: "Broken", Dependencies: []string{"NotInstalled"}},
+		"LoopA":     {Name: "LoopA", Dependencies: []string{"LoopB"}},
+		"LoopB":     {Name: "LoopB", Dependencies: []string{"LoopA"}},
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	addons := map[string]bool{
+		"DBM-Raids": true,
+		"WeakAuras": true,
+	}
+
+	resolved, report := ResolveDependencies(testCatalog(), addons)
+
+	for _, name := range []string{"DBM-Raids", "DBM-Core", "Ace3", "LibStub", "WeakAuras"} {
+		if !resolved[name] {
+			t.Errorf("Expected %s to be enabled", name)
+		}
+	}
+
+	expectedAdded := []string{"Ace3", "DBM-Core", "LibStub"}
+	if len(report.Added) != len(expectedAdded) {
+		t.Fatalf("Added = %v, want %v", report.Added, expectedAdded)
+	}
+	for i, name := range expectedAdded {
+		if report.Added[i] != name {
+			t.Errorf("Added[%d] = %v, want %v", i, report.Added[i], name)
+		}
+	}
+
+	if report.HasProblems() {
+		t.Errorf("Expected no problems, got missing %v cycles %v", report.Missing, report.Cycles)
+	}
+
+	// The input map must not be modified
+	if len(addons) != 2 {
+		t.Errorf("ResolveDependencies() modified its input: %v", addons)
+	}
+}
+
+func TestResolveDependenciesDisabledDependency(t *testing.T) {
+	addons := map[string]bool{
+		"Ace3":    true,
+		"libstub": false,
+	}
+
+	resolved, report := ResolveDependencies(testCatalog(), addons)
+
+	if !resolved["libstub"] {
+		t.Error("Expected disabled dependency to be enabled under its existing name")
+	}
+
+	if _, ok := resolved["LibStub"]; ok {
+		t.Error("Dependency was added twice with different case")
+	}
+
+	if len(report.Added) != 1 || report.Added[0] != "libstub" {
+		t.Errorf("Added = %v, want [libstub]", report.Added)
+	}
+}
+
+func TestResolveDependenciesMissing(t *testing.T) {
+	_, report := ResolveDependencies(testCatalog(), map[string]bool{"Broken": true})
+
+	missing := report.Missing["Broken"]
+	if len(missing) != 1 || missing[0] != "NotInstalled" {
+		t.Errorf("Missing[Broken] = %v, want [NotInstalled]", missing)
+	}
+
+	if !report.HasProblems() {
+		t.Error("Expected HasProblems() to be true")
+	}
+}
+
+func TestResolveDependenciesCycle(t *testing.T) {
+	resolved, report := ResolveDependencies(testCatalog(), map[string]bool{"LoopA": true})
+
+	if !resolved["LoopB"] {
+		t.Error("Expected LoopB to be enabled")
+	}
+
+	if len(report.Cycles) != 1 {
+		t.Fatalf("Cycles = %v, want 1 cycle", report.Cycles)
+	}
+
+	cycle := report.Cycles[0]
+	expected := []string{"LoopA", "LoopB", "LoopA"}
+	if len(cycle) != len(expected) {
+		t.Fatalf("Cycle = %v, want %v", cycle, expected)
+	}
+	for i := range expected {
+		if cycle[i] != expected[i] {
+			t.Errorf("Cycle = %v, want %v", cycle, expected)
+			break
+		}
+	}
+}
+
+func TestApplyProfileAutoDeps(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	tocs := map[string]string{
+		"Ace3":     "## Title: Ace3\n",
+		"DBM-Core": "## Title: DBM\n## Dependencies: Ace3, LibMissing\n",
+	}
+	for name, content := range tocs {
+		dir := filepath.Join(tmpDir, "Interface", "AddOns", name)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, name+".toc"), []byte(content), 0644)
+	}
+
+	mgr := NewManager(tmpDir, account, 5)
+
+	profile := &lua.Profile{
+		Name:     "Raid",
+		Scope:    "account",
+		Addons:   map[string]bool{"DBM-Core": true},
+		AutoDeps: true,
+	}
+
+	result, err := mgr.ApplyProfile(profile)
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+
+	if result.Dependencies == nil {
+		t.Fatal("Expected a dependency report")
+	}
+
+	if len(result.Dependencies.Added) != 1 || result.Dependencies.Added[0] != "Ace3" {
+		t.Errorf("Added = %v, want [Ace3]", result.Dependencies.Added)
+	}
+
+	if len(result.Dependencies.Missing["DBM-Core"]) != 1 {
+		t.Errorf("Missing = %v, want LibMissing for DBM-Core", result.Dependencies.Missing)
+	}
+
+	addons, err := parseAddOnsFile(filepath.Join(accountDir, "AddOns.txt"))
+	if err != nil {
+		t.Fatalf("parseAddOnsFile() error = %v", err)
+	}
+
+	if !addons["Ace3"] || !addons["DBM-Core"] {
+		t.Errorf("AddOns.txt = %v, want Ace3 and DBM-Core enabled", addons)
+	}
+
+	// Without AutoDeps the profile is applied as-is
+	profile.AutoDeps = false
+	result, err = mgr.ApplyProfile(profile)
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+
+	if result.Dependencies != nil {
+		t.Error("Expected no dependency report without AutoDeps")
+	}
+}
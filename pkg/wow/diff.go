@@ -0,0 +1,95 @@
+package wow
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+// ProfileDiff describes how applying a profile would change an AddOns.txt
+type ProfileDiff struct {
+	Path         string            // AddOns.txt the profile would be written to
+	Enabled      []string          // listed and disabled now, enabled afterwards
+	Disabled     []string          // listed and enabled now, disabled afterwards
+	Added        []string          // not listed now, added by the profile
+	Removed      []string          // listed now, dropped from the file
+	Unchanged    []string          // listed before and after with the same state
+	Result       map[string]bool   // addon states after applying
+	Dependencies *DependencyReport // nil unless the profile uses AutoDeps
+}
+
+// HasChanges reports whether applying the profile would change anything
+func (d *ProfileDiff) HasChanges() bool {
+	return len(d.Enabled) > 0 || len(d.Disabled) > 0 || len(d.Added) > 0 || len(d.Removed) > 0
+}
+
+// DiffProfile computes what applying a profile would change, without
+// writing anything. The diff is against a character's AddOns.txt, or the
+// account's when char is nil, and honors the apply mode and AutoDeps.
+func (m *Manager) DiffProfile(profile *lua.Profile, char *Character) (*ProfileDiff, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	addonsPath := m.AddOnsPath(char)
+
+	addons, report, err := m.ResolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	before := make(map[string]bool)
+	if _, err := os.Stat(addonsPath); err == nil {
+		before, err = parseAddOnsFile(addonsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AddOns.txt: %w", err)
+		}
+	}
+
+	entries, err := m.buildAddOnsEntries(addonsPath, addons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AddOns.txt: %w", err)
+	}
+
+	diff := diffAddOns(before, entries)
+	diff.Path = addonsPath
+	diff.Dependencies = report
+	return diff, nil
+}
+
+// diffAddOns compares addon states before and after a write
+func diffAddOns(before map[string]bool, after []addonEntry) *ProfileDiff {
+	diff := &ProfileDiff{
+		Result: make(map[string]bool, len(after)),
+	}
+
+	for _, entry := range after {
+		diff.Result[entry.Name] = entry.Enabled
+
+		wasEnabled, listed := before[entry.Name]
+		switch {
+		case !listed:
+			diff.Added = append(diff.Added, entry.Name)
+		case wasEnabled == entry.Enabled:
+			diff.Unchanged = append(diff.Unchanged, entry.Name)
+		case entry.Enabled:
+			diff.Enabled = append(diff.Enabled, entry.Name)
+		default:
+			diff.Disabled = append(diff.Disabled, entry.Name)
+		}
+	}
+
+	for name := range before {
+		if _, ok := diff.Result[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	for _, list := range [][]string{diff.Enabled, diff.Disabled, diff.Added, diff.Removed, diff.Unchanged} {
+		sort.Strings(list)
+	}
+
+	return diff
+}
@@ -0,0 +1,118 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+func assertNames(t *testing.T, field string, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s = %v, want %v", field, got, want)
+			return
+		}
+	}
+}
+
+func TestDiffProfile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	// Ace3, Details, DBM-Core and BigWigs enabled; AddonProfiles and WeakAuras disabled
+	data, err := os.ReadFile(filepath.Join("testdata", "AddOns.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, data, 0644)
+
+	profile := &lua.Profile{
+		Name:  "Raiding",
+		Scope: "account",
+		Addons: map[string]bool{
+			"Ace3":          true,
+			"WeakAuras":     true,
+			"RCLootCouncil": true,
+		},
+	}
+
+	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetApplyMode(ApplyModeMerge)
+
+	diff, err := mgr.DiffProfile(profile, nil)
+	if err != nil {
+		t.Fatalf("DiffProfile() error = %v", err)
+	}
+
+	assertNames(t, "Enabled", diff.Enabled, []string{"WeakAuras"})
+	assertNames(t, "Disabled", diff.Disabled, []string{"BigWigs", "DBM-Core", "Details"})
+	assertNames(t, "Added", diff.Added, []string{"RCLootCouncil"})
+	assertNames(t, "Removed", diff.Removed, nil)
+	assertNames(t, "Unchanged", diff.Unchanged, []string{"Ace3", "AddonProfiles"})
+
+	if !diff.HasChanges() {
+		t.Error("Expected HasChanges() to be true")
+	}
+
+	if diff.Path != addonsPath {
+		t.Errorf("Path = %v, want %v", diff.Path, addonsPath)
+	}
+
+	// Replace mode drops everything the profile doesn't list
+	mgr.SetApplyMode(ApplyModeReplace)
+	diff, err = mgr.DiffProfile(profile, nil)
+	if err != nil {
+		t.Fatalf("DiffProfile() error = %v", err)
+	}
+
+	assertNames(t, "Removed", diff.Removed, []string{"AddonProfiles", "BigWigs", "DBM-Core", "Details"})
+	assertNames(t, "Unchanged", diff.Unchanged, []string{"Ace3"})
+
+	// Nothing was written
+	after, err := os.ReadFile(addonsPath)
+	if err != nil {
+		t.Fatalf("Failed to read AddOns.txt: %v", err)
+	}
+	if string(after) != string(data) {
+		t.Error("DiffProfile() modified AddOns.txt")
+	}
+}
+
+func TestDiffProfileNoChanges(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+	writeAddOnsFile(filepath.Join(accountDir, "AddOns.txt"), map[string]bool{"Ace3": true})
+
+	mgr := NewManager(tmpDir, account, 5)
+	diff, err := mgr.DiffProfile(&lua.Profile{Addons: map[string]bool{"Ace3": true}}, nil)
+	if err != nil {
+		t.Fatalf("DiffProfile() error = %v", err)
+	}
+
+	if diff.HasChanges() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+}
@@ -0,0 +1,129 @@
+package wow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Flavor is a game client installed under the WoW install root
+type Flavor struct {
+	Dir  string // directory name, e.g. "_retail_"
+	Name string // display name, e.g. "Retail"
+}
+
+// knownFlavors lists the client directories Battle.net creates, in the
+// order they are offered to the user
+var knownFlavors = []Flavor{
+	{Dir: "_retail_", Name: "Retail"},
+	{Dir: "_classic_", Name: "Classic"},
+	{Dir: "_classic_era_", Name: "Classic Era"},
+	{Dir: "_ptr_", Name: "PTR"},
+	{Dir: "_xptr_", Name: "Experimental PTR"},
+	{Dir: "_beta_", Name: "Beta"},
+	{Dir: "_classic_ptr_", Name: "Classic PTR"},
+	{Dir: "_classic_era_ptr_", Name: "Classic Era PTR"},
+	{Dir: "_classic_beta_", Name: "Classic Beta"},
+}
+
+// flavorTocSuffixes are the toc file suffixes each client looks for, most
+// specific first. Clients not listed here use defaultTocSuffixes.
+var flavorTocSuffixes = map[string][]string{
+	"_classic_":         {"Mists", "Cata", "Classic"},
+	"_classic_ptr_":     {"Mists", "Cata", "Classic"},
+	"_classic_beta_":    {"Mists", "Cata", "Classic"},
+	"_classic_era_":     {"Vanilla", "Classic"},
+	"_classic_era_ptr_": {"Vanilla", "Classic"},
+}
+
+// isFlavorDir reports whether a directory name looks like a client directory
+func isFlavorDir(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "_") && strings.HasSuffix(name, "_")
+}
+
+// flavorName returns the display name of a client directory
+func flavorName(dir string) string {
+	for _, flavor := range knownFlavors {
+		if flavor.Dir == dir {
+			return flavor.Name
+		}
+	}
+
+	words := strings.Fields(strings.ReplaceAll(dir, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// DetectFlavors returns the clients installed under a WoW install root.
+// A client counts as installed once it has a WTF/Account directory.
+func DetectFlavors(root string) ([]Flavor, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WoW directory: %w", err)
+	}
+
+	found := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() || !isFlavorDir(entry.Name()) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(root, entry.Name(), "WTF", "Account")); err == nil && info.IsDir() {
+			found[entry.Name()] = true
+		}
+	}
+
+	var flavors []Flavor
+	for _, flavor := range knownFlavors {
+		if found[flavor.Dir] {
+			flavors = append(flavors, flavor)
+			delete(found, flavor.Dir)
+		}
+	}
+
+	// Unknown clients go last, in directory order
+	for _, entry := range entries {
+		if found[entry.Name()] {
+			flavors = append(flavors, Flavor{Dir: entry.Name(), Name: flavorName(entry.Name())})
+		}
+	}
+
+	return flavors, nil
+}
+
+// DefaultFlavor picks the client to use when none is configured: retail if
+// it is installed, otherwise the first client found. It returns "" when path
+// is a client directory itself rather than an install root.
+func DefaultFlavor(root string) string {
+	flavors, err := DetectFlavors(root)
+	if err != nil || len(flavors) == 0 {
+		return ""
+	}
+	return flavors[0].Dir
+}
+
+// SetFlavor selects the client directory the manager operates on.
+// An empty flavor means wowPath is the client directory itself.
+func (m *Manager) SetFlavor(flavor string) {
+	m.flavor = flavor
+}
+
+// Flavor returns the selected client directory
+func (m *Manager) Flavor() string {
+	return m.flavor
+}
+
+// clientPath returns the directory of the selected client
+func (m *Manager) clientPath() string {
+	return filepath.Join(m.wowPath, m.flavor)
+}
+
+// tocSuffixes returns the toc suffixes the selected client loads
+func (m *Manager) tocSuffixes() []string {
+	if suffixes, ok := flavorTocSuffixes[m.flavor]; ok {
+		return suffixes
+	}
+	return defaultTocSuffixes
+}
@@ -0,0 +1,125 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupFlavors creates an install root with retail and classic era clients
+func setupFlavors(t *testing.T) string {
+	t.Helper()
+
+	root, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(root, "_retail_", "WTF", "Account", "RetailAccount"), 0755)
+	os.MkdirAll(filepath.Join(root, "_classic_era_", "WTF", "Account", "EraAccount"), 0755)
+	os.MkdirAll(filepath.Join(root, "_anniversary_", "WTF", "Account", "FreshAccount"), 0755)
+
+	// Not installed yet: no WTF/Account
+	os.MkdirAll(filepath.Join(root, "_ptr_", "Interface"), 0755)
+	// Not a client directory
+	os.MkdirAll(filepath.Join(root, "Data"), 0755)
+
+	for flavor, toc := range map[string]string{
+		"_retail_":      "Questie_Mainline.toc",
+		"_classic_era_": "Questie_Vanilla.toc",
+	} {
+		dir := filepath.Join(root, flavor, "Interface", "AddOns", "Questie")
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, toc), []byte("## Title: Questie "+flavor+"\n"), 0644)
+	}
+
+	return root
+}
+
+func TestDetectFlavors(t *testing.T) {
+	root := setupFlavors(t)
+	defer os.RemoveAll(root)
+
+	flavors, err := DetectFlavors(root)
+	if err != nil {
+		t.Fatalf("DetectFlavors() error = %v", err)
+	}
+
+	expected := []Flavor{
+		{Dir: "_retail_", Name: "Retail"},
+		{Dir: "_classic_era_", Name: "Classic Era"},
+		{Dir: "_anniversary_", Name: "Anniversary"},
+	}
+
+	if len(flavors) != len(expected) {
+		t.Fatalf("DetectFlavors() = %v, want %v", flavors, expected)
+	}
+
+	for i := range expected {
+		if flavors[i] != expected[i] {
+			t.Errorf("DetectFlavors()[%d] = %+v, want %+v", i, flavors[i], expected[i])
+		}
+	}
+
+	if got := DefaultFlavor(root); got != "_retail_" {
+		t.Errorf("DefaultFlavor() = %v, want _retail_", got)
+	}
+
+	// A client directory has no flavors below it
+	if got := DefaultFlavor(filepath.Join(root, "_retail_")); got != "" {
+		t.Errorf("DefaultFlavor() = %v, want empty for a client directory", got)
+	}
+}
+
+func TestManagerFlavorScope(t *testing.T) {
+	root := setupFlavors(t)
+	defer os.RemoveAll(root)
+
+	mgr := NewManager(root, "", 5)
+	mgr.SetFlavor("_classic_era_")
+
+	accounts, err := mgr.GetAccounts()
+	if err != nil {
+		t.Fatalf("GetAccounts() error = %v", err)
+	}
+
+	if len(accounts) != 1 || accounts[0] != "EraAccount" {
+		t.Errorf("GetAccounts() = %v, want [EraAccount]", accounts)
+	}
+
+	catalog, err := mgr.GetInstalledAddons()
+	if err != nil {
+		t.Fatalf("GetInstalledAddons() error = %v", err)
+	}
+
+	if filepath.Base(catalog["Questie"].TocPath) != "Questie_Vanilla.toc" {
+		t.Errorf("TocPath = %v, want Questie_Vanilla.toc", catalog["Questie"].TocPath)
+	}
+
+	mgr.SetFlavor("_retail_")
+	catalog, err = mgr.GetInstalledAddons()
+	if err != nil {
+		t.Fatalf("GetInstalledAddons() error = %v", err)
+	}
+
+	if filepath.Base(catalog["Questie"].TocPath) != "Questie_Mainline.toc" {
+		t.Errorf("TocPath = %v, want Questie_Mainline.toc", catalog["Questie"].TocPath)
+	}
+}
+
+func TestValidateWowDirectoryInstallRoot(t *testing.T) {
+	root := setupFlavors(t)
+	defer os.RemoveAll(root)
+
+	if err := ValidateWowDirectory(root); err != nil {
+		t.Errorf("ValidateWowDirectory() error = %v for install root", err)
+	}
+
+	if err := ValidateWowDirectory(filepath.Join(root, "_retail_")); err != nil {
+		t.Errorf("ValidateWowDirectory() error = %v for client directory", err)
+	}
+
+	if err := ValidateWowDirectory(filepath.Join(root, "_ptr_")); err == nil {
+		t.Error("Expected error for client directory without WTF")
+	}
+}
@@ -2,21 +2,65 @@ package wow
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/jmervine/AddonProfiles-GUI/pkg/fsutil"
 	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
 )
 
+// ApplyMode controls how ApplyProfile treats addons that are not in the profile
+type ApplyMode int
+
+const (
+	// ApplyModeReplace rewrites AddOns.txt with only the profile's addons
+	ApplyModeReplace ApplyMode = iota
+	// ApplyModeMerge keeps every entry already in AddOns.txt and lists every
+	// installed addon, enabling the profile's addons and disabling
+	// everything else. It is the default.
+	ApplyModeMerge
+)
+
+// String returns the config name of the apply mode
+func (mode ApplyMode) String() string {
+	switch mode {
+	case ApplyModeMerge:
+		return "merge"
+	default:
+		return "replace"
+	}
+}
+
+// ParseApplyMode converts a config value into an ApplyMode
+func ParseApplyMode(value string) (ApplyMode, error) {
+	switch value {
+	case "replace":
+		return ApplyModeReplace, nil
+	case "merge", "":
+		return ApplyModeMerge, nil
+	default:
+		return ApplyModeReplace, fmt.Errorf("unknown apply mode: %s", value)
+	}
+}
+
 // Manager handles WoW data operations
 type Manager struct {
 	wowPath         string
+	flavor          string
 	selectedAccount string
 	backupCount     int
+	applyMode       ApplyMode
+	parseOptions    lua.ParseOptions
+	detector        ClientDetector
+	warnings        io.Writer
 }
 
 // NewManager creates a new WoW data manager
@@ -25,12 +69,63 @@ func NewManager(wowPath, account string, backupCount int) *Manager {
 		wowPath:         wowPath,
 		selectedAccount: account,
 		backupCount:     backupCount,
+		applyMode:       ApplyModeMerge,
+		detector:        DefaultDetector(),
+		warnings:        os.Stderr,
+	}
+}
+
+// SetWarningOutput sets where warnings about problems that don't stop an
+// operation, such as old backups that couldn't be removed, are written. A
+// nil writer discards them.
+func (m *Manager) SetWarningOutput(w io.Writer) {
+	if w == nil {
+		w = io.Discard
 	}
+	m.warnings = w
+}
+
+// warnf writes a warning line
+func (m *Manager) warnf(format string, args ...interface{}) {
+	fmt.Fprintf(m.warnings, "Warning: "+format+"\n", args...)
+}
+
+// SetApplyMode sets how ApplyProfile treats addons that are not in the profile
+func (m *Manager) SetApplyMode(mode ApplyMode) {
+	m.applyMode = mode
+}
+
+// ApplyMode returns the current apply mode
+func (m *Manager) ApplyMode() ApplyMode {
+	return m.applyMode
+}
+
+// SetParseOptions sets how SavedVariables files are parsed, e.g. to fail on
+// damaged files instead of recovering what the fallback parser can
+func (m *Manager) SetParseOptions(opts lua.ParseOptions) {
+	m.parseOptions = opts
+}
+
+// ParseOptions returns how SavedVariables files are parsed
+func (m *Manager) ParseOptions() lua.ParseOptions {
+	return m.parseOptions
+}
+
+// accountPath returns the WTF directory of the selected account
+func (m *Manager) accountPath() string {
+	return filepath.Join(m.clientPath(), "WTF", "Account", m.selectedAccount)
+}
+
+// withAccount returns a copy of the manager that operates on another account
+func (m *Manager) withAccount(account string) *Manager {
+	other := *m
+	other.selectedAccount = account
+	return &other
 }
 
 // GetAccounts returns a list of account names found in the WTF directory
 func (m *Manager) GetAccounts() ([]string, error) {
-	accountsPath := filepath.Join(m.wowPath, "WTF", "Account")
+	accountsPath := filepath.Join(m.clientPath(), "WTF", "Account")
 
 	entries, err := os.ReadDir(accountsPath)
 	if err != nil {
@@ -47,34 +142,78 @@ func (m *Manager) GetAccounts() ([]string, error) {
 	return accounts, nil
 }
 
+// AccountSummary describes an account folder for account pickers
+type AccountSummary struct {
+	Name          string
+	HasProfilesDB bool  // whether SavedVariables/AddonProfilesDB.lua exists
+	ProfileCount  int   // account and character profiles combined
+	Err           error // set if AddonProfilesDB.lua could not be read
+}
+
+// GetAccountSummaries returns every account with its profile count
+func (m *Manager) GetAccountSummaries() ([]AccountSummary, error) {
+	accounts, err := m.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]AccountSummary, 0, len(accounts))
+	for _, account := range accounts {
+		summary := AccountSummary{Name: account}
+
+		accountMgr := m.withAccount(account)
+		if _, err := os.Stat(accountMgr.profilesDBPath()); err == nil {
+			summary.HasProfilesDB = true
+
+			db, err := accountMgr.LoadProfiles()
+			if err != nil {
+				summary.Err = err
+			} else {
+				summary.ProfileCount = len(db.Global.Profiles)
+				for _, charData := range db.Char {
+					summary.ProfileCount += len(charData.Profiles)
+				}
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// SelectedAccount returns the account the manager operates on
+func (m *Manager) SelectedAccount() string {
+	return m.selectedAccount
+}
+
 // LoadProfiles loads all profiles from SavedVariables
 func (m *Manager) LoadProfiles() (*lua.Database, error) {
+	db, _, err := m.LoadProfilesWithReport()
+	return db, err
+}
+
+// LoadProfilesWithReport loads all profiles and reports how the file was
+// parsed. A recovered report means the file is damaged and some profiles
+// may be missing.
+func (m *Manager) LoadProfilesWithReport() (*lua.Database, *lua.ParseReport, error) {
 	if m.selectedAccount == "" {
-		return nil, fmt.Errorf("no account selected")
+		return nil, nil, fmt.Errorf("no account selected")
 	}
 
-	savedVarsPath := filepath.Join(m.wowPath, "WTF", "Account", m.selectedAccount,
-		"SavedVariables", "AddonProfilesDB.lua")
+	savedVarsPath := m.profilesDBPath()
 
 	if _, err := os.Stat(savedVarsPath); os.IsNotExist(err) {
 		// Return empty database if file doesn't exist
 		return &lua.Database{
-			Global: struct {
-				ActiveProfile string
-				Profiles      map[string]*lua.Profile
-				Settings      map[string]interface{}
-			}{
+			Global: lua.GlobalData{
 				Profiles: make(map[string]*lua.Profile),
-				Settings: make(map[string]interface{}),
 			},
-			Char: make(map[string]struct {
-				ActiveProfile string
-				Profiles      map[string]*lua.Profile
-			}),
-		}, nil
+			Char: make(map[string]lua.CharData),
+		}, &lua.ParseReport{Parser: lua.ParserSimple}, nil
 	}
 
-	return lua.ParseFile(savedVarsPath)
+	return lua.ParseFileWithOptions(savedVarsPath, m.parseOptions)
 }
 
 // GetActiveAddons returns the currently active addons from AddOns.txt
@@ -83,8 +222,11 @@ func (m *Manager) GetActiveAddons() (map[string]bool, error) {
 		return nil, fmt.Errorf("no account selected")
 	}
 
-	addonsPath := filepath.Join(m.wowPath, "WTF", "Account", m.selectedAccount, "AddOns.txt")
+	return readAddOns(m.AddOnsPath(nil))
+}
 
+// readAddOns parses an AddOns.txt file, treating a missing file as empty
+func readAddOns(addonsPath string) (map[string]bool, error) {
 	if _, err := os.Stat(addonsPath); os.IsNotExist(err) {
 		return make(map[string]bool), nil
 	}
@@ -92,100 +234,224 @@ func (m *Manager) GetActiveAddons() (map[string]bool, error) {
 	return parseAddOnsFile(addonsPath)
 }
 
-// ApplyProfile applies a profile by updating AddOns.txt
-func (m *Manager) ApplyProfile(profile *lua.Profile) error {
+// ApplyResult describes the outcome of applying a profile
+type ApplyResult struct {
+	Path         string            // AddOns.txt file that was written
+	Addons       map[string]bool   // addon states requested, including dependencies
+	Dependencies *DependencyReport // nil unless the profile uses AutoDeps
+}
+
+// ResolveProfile returns the addon states a profile asks for. When the
+// profile has AutoDeps set, the required dependencies of its enabled addons
+// are looked up in the installed toc files and enabled too.
+func (m *Manager) ResolveProfile(profile *lua.Profile) (map[string]bool, *DependencyReport, error) {
+	if !profile.AutoDeps {
+		return profile.Addons, nil, nil
+	}
+
+	catalog, err := m.GetInstalledAddons()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	addons, report := ResolveDependencies(catalog, profile.Addons)
+	return addons, report, nil
+}
+
+// ApplyProfile applies a profile by updating AddOns.txt. It returns a
+// *ClientRunningError while a WoW client is running.
+func (m *Manager) ApplyProfile(profile *lua.Profile) (*ApplyResult, error) {
 	if m.selectedAccount == "" {
-		return fmt.Errorf("no account selected")
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	return m.applyToFile(profile, m.AddOnsPath(nil))
+}
+
+// applyToFile applies a profile to a single AddOns.txt file
+func (m *Manager) applyToFile(profile *lua.Profile, addonsPath string) (*ApplyResult, error) {
+	if err := m.checkClient(); err != nil {
+		return nil, err
 	}
 
-	addonsPath := filepath.Join(m.wowPath, "WTF", "Account", m.selectedAccount, "AddOns.txt")
+	addons, report, err := m.ResolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create backup
-	if err := m.createBackup(addonsPath); err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
+	meta := backupMeta{Profile: profile.Name, Reason: fmt.Sprintf("Applied profile '%s'", profile.Name)}
+	if err := m.createBackup(addonsPath, meta); err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	entries, err := m.buildAddOnsEntries(addonsPath, addons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AddOns.txt: %w", err)
 	}
 
 	// Write new AddOns.txt
-	if err := writeAddOnsFile(addonsPath, profile.Addons); err != nil {
-		return fmt.Errorf("failed to write AddOns.txt: %w", err)
+	if err := writeAddOnsEntries(addonsPath, entries); err != nil {
+		return nil, fmt.Errorf("failed to write AddOns.txt: %w", err)
 	}
 
 	// Clean up old backups
 	if err := m.cleanupBackups(addonsPath); err != nil {
-		// Log but don't fail
-		fmt.Printf("Warning: failed to cleanup old backups: %v\n", err)
+		// Warn but don't fail
+		m.warnf("failed to cleanup old backups: %v", err)
 	}
 
-	return nil
+	return &ApplyResult{
+		Path:         addonsPath,
+		Addons:       addons,
+		Dependencies: report,
+	}, nil
+}
+
+// buildAddOnsEntries returns the AddOns.txt lines that result from applying
+// addons to the file at addonsPath using the manager's apply mode
+func (m *Manager) buildAddOnsEntries(addonsPath string, addons map[string]bool) ([]addonEntry, error) {
+	if m.applyMode != ApplyModeMerge {
+		return sortedAddOnsEntries(addons), nil
+	}
+
+	var existing []addonEntry
+	if _, err := os.Stat(addonsPath); err == nil {
+		existing, err = readAddOnsEntries(addonsPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// WoW enables installed addons that AddOns.txt doesn't list, so they
+	// need a line to be disabled. Without an AddOns folder only the file's
+	// entries can be merged.
+	var installed []string
+	catalog, err := m.GetInstalledAddons()
+	switch {
+	case err == nil:
+		installed = catalog.Names()
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to list installed addons: %w", err)
+	}
+
+	return mergeAddOns(existing, installed, addons), nil
 }
 
-// createBackup creates a timestamped backup of AddOns.txt
-func (m *Manager) createBackup(addonsPath string) error {
+// createBackup creates a timestamped backup of AddOns.txt and records
+// why it was taken in the backup index
+func (m *Manager) createBackup(addonsPath string, meta backupMeta) error {
 	if _, err := os.Stat(addonsPath); os.IsNotExist(err) {
 		// No file to backup
 		return nil
 	}
 
-	timestamp := time.Now().Format("20060102_150405")
+	timestamp := time.Now().Format(backupTimeFormat)
 	backupPath := fmt.Sprintf("%s.backup.%s", addonsPath, timestamp)
 
+	// Don't overwrite a backup taken within the same second
+	for i := 2; ; i++ {
+		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.backup.%s_%d", addonsPath, timestamp, i)
+	}
+
 	data, err := os.ReadFile(addonsPath)
 	if err != nil {
 		return fmt.Errorf("failed to read AddOns.txt: %w", err)
 	}
 
-	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(backupPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write backup: %w", err)
 	}
 
+	if meta != (backupMeta{}) {
+		index, err := loadBackupIndex(addonsPath)
+		if err != nil {
+			return err
+		}
+		index[filepath.Base(backupPath)] = meta
+		if err := saveBackupIndex(addonsPath, index); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// cleanupBackups removes old backups, keeping only the most recent N
+// cleanupBackups removes old backups, keeping only the most recent N.
+// Pinned backups are kept and don't count towards N.
 func (m *Manager) cleanupBackups(addonsPath string) error {
-	dir := filepath.Dir(addonsPath)
-	base := filepath.Base(addonsPath)
-
-	entries, err := os.ReadDir(dir)
+	// Same order as the backup list, so the oldest ones listed are pruned
+	backups, err := listBackups(addonsPath)
 	if err != nil {
 		return err
 	}
 
-	// Find all backup files
-	var backups []string
-	prefix := base + ".backup."
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
-			backups = append(backups, filepath.Join(dir, entry.Name()))
+	var unpinned []Backup
+	for _, backup := range backups {
+		if !backup.Pinned {
+			unpinned = append(unpinned, backup)
 		}
 	}
 
-	// Sort by modification time (newest first)
-	sort.Slice(backups, func(i, j int) bool {
-		infoI, _ := os.Stat(backups[i])
-		infoJ, _ := os.Stat(backups[j])
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
-
 	// Remove old backups
-	if len(backups) > m.backupCount {
-		for _, backup := range backups[m.backupCount:] {
-			os.Remove(backup)
+	if len(unpinned) > m.backupCount {
+		index, err := loadBackupIndex(addonsPath)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for _, backup := range unpinned[m.backupCount:] {
+			os.Remove(backup.Path)
+			if _, ok := index[backup.Name]; ok {
+				delete(index, backup.Name)
+				changed = true
+			}
+		}
+
+		if changed {
+			return saveBackupIndex(addonsPath, index)
 		}
 	}
 
 	return nil
 }
 
+// addonEntry is a single line of AddOns.txt
+type addonEntry struct {
+	Name    string
+	Enabled bool
+}
+
 // parseAddOnsFile parses an AddOns.txt file
 func parseAddOnsFile(path string) (map[string]bool, error) {
+	entries, err := readAddOnsEntries(path)
+	if err != nil {
+		return nil, err
+	}
+
+	addons := make(map[string]bool)
+	for _, entry := range entries {
+		addons[entry.Name] = entry.Enabled
+	}
+
+	return addons, nil
+}
+
+// readAddOnsEntries parses an AddOns.txt file, keeping the order of its lines.
+// If an addon is listed more than once only its last line is kept.
+func readAddOnsEntries(path string) ([]addonEntry, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	addons := make(map[string]bool)
+	var entries []addonEntry
+	index := make(map[string]int)
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
@@ -209,53 +475,143 @@ func parseAddOnsFile(path string) (map[string]bool, error) {
 			enabled = false
 		}
 
-		addons[addonName] = enabled
+		if i, ok := index[addonName]; ok {
+			entries[i].Enabled = enabled
+			continue
+		}
+		index[addonName] = len(entries)
+		entries = append(entries, addonEntry{Name: addonName, Enabled: enabled})
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
-	return addons, nil
+	return entries, nil
 }
 
-// writeAddOnsFile writes addons to AddOns.txt file
-func writeAddOnsFile(path string, addons map[string]bool) error {
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// mergeAddOns applies addons on top of the existing AddOns.txt lines.
+// Existing lines keep their position and are enabled only if the profile
+// enables them. Addons of the profile and installed addons that are new to
+// the file are appended in name order, disabled unless the profile enables
+// them.
+func mergeAddOns(existing []addonEntry, installed []string, addons map[string]bool) []addonEntry {
+	merged := make([]addonEntry, 0, len(existing)+len(installed)+len(addons))
+	seen := make(map[string]bool)
+
+	for _, entry := range existing {
+		seen[strings.ToLower(entry.Name)] = true
+		enabled, _ := lookupFold(addons, entry.Name)
+		merged = append(merged, addonEntry{Name: entry.Name, Enabled: enabled})
 	}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+	added := make(map[string]bool, len(addons)+len(installed))
+	for name, enabled := range addons {
+		added[name] = enabled
+	}
+	for _, name := range installed {
+		if _, ok := lookupFold(added, name); !ok {
+			added[name] = false
+		}
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
+	for _, entry := range sortedAddOnsEntries(added) {
+		if !seen[strings.ToLower(entry.Name)] {
+			seen[strings.ToLower(entry.Name)] = true
+			merged = append(merged, entry)
+		}
+	}
 
-	// Sort addon names for consistent output
+	return merged
+}
+
+// lookupFold finds name in addons, ignoring case as WoW does for folder
+// names
+func lookupFold(addons map[string]bool, name string) (bool, bool) {
+	if enabled, ok := addons[name]; ok {
+		return enabled, true
+	}
+	for key, enabled := range addons {
+		if strings.EqualFold(key, name) {
+			return enabled, true
+		}
+	}
+	return false, false
+}
+
+// sortedAddOnsEntries converts an addon map into lines sorted by name
+func sortedAddOnsEntries(addons map[string]bool) []addonEntry {
 	var names []string
 	for name := range addons {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
-	// Write each addon
+	entries := make([]addonEntry, 0, len(names))
 	for _, name := range names {
-		enabled := addons[name]
-		if enabled {
-			fmt.Fprintf(writer, "%s: 1\n", name)
+		entries = append(entries, addonEntry{Name: name, Enabled: addons[name]})
+	}
+
+	return entries
+}
+
+// writeAddOnsFile writes addons to AddOns.txt file
+func writeAddOnsFile(path string, addons map[string]bool) error {
+	// Sort addon names for consistent output
+	return writeAddOnsEntries(path, sortedAddOnsEntries(addons))
+}
+
+// writeAddOnsEntries writes AddOns.txt lines in the given order
+func writeAddOnsEntries(path string, entries []addonEntry) error {
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	want := make(map[string]bool, len(entries))
+
+	// Write each addon
+	for _, entry := range entries {
+		if entry.Enabled {
+			fmt.Fprintf(&buf, "%s: 1\n", entry.Name)
 		} else {
-			fmt.Fprintf(writer, "# %s: 0\n", name)
+			fmt.Fprintf(&buf, "# %s: 0\n", entry.Name)
 		}
+		want[entry.Name] = entry.Enabled
 	}
 
-	return writer.Flush()
+	// Only replace the live file once it reads back as intended
+	return fsutil.WriteFileVerified(path, buf.Bytes(), 0644, func(tmpPath string) error {
+		got, err := parseAddOnsFile(tmpPath)
+		if err != nil {
+			return err
+		}
+		return compareAddOns(got, want)
+	})
 }
 
-// ValidateWowDirectory checks if a directory is a valid WoW installation
+// compareAddOns reports the first difference between two addon states
+func compareAddOns(got, want map[string]bool) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("read back %d addons, want %d", len(got), len(want))
+	}
+	for name, enabled := range want {
+		state, ok := got[name]
+		if !ok {
+			return fmt.Errorf("addon %s missing after write", name)
+		}
+		if state != enabled {
+			return fmt.Errorf("addon %s enabled = %v after write, want %v", name, state, enabled)
+		}
+	}
+	return nil
+}
+
+// ValidateWowDirectory checks if a directory is a valid WoW installation.
+// It accepts either an install root containing client directories such as
+// _retail_ and _classic_, or a single client directory.
 func ValidateWowDirectory(path string) error {
 	// Check if directory exists
 	info, err := os.Stat(path)
@@ -267,10 +623,14 @@ func ValidateWowDirectory(path string) error {
 		return fmt.Errorf("path is not a directory")
 	}
 
+	if flavors, err := DetectFlavors(path); err == nil && len(flavors) > 0 {
+		return nil
+	}
+
 	// Check for WTF directory
 	wtfPath := filepath.Join(path, "WTF")
 	if _, err := os.Stat(wtfPath); os.IsNotExist(err) {
-		return fmt.Errorf("WTF directory not found")
+		return fmt.Errorf("WTF directory not found (and no client folders such as _retail_ or _classic_)")
 	}
 
 	// Check for Account directory
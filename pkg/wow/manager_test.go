@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
 )
@@ -27,6 +29,11 @@ func TestNewManager(t *testing.T) {
 	if mgr.backupCount != 5 {
 		t.Errorf("backupCount = %v, want 5", mgr.backupCount)
 	}
+
+	// The default matches an empty apply_mode in the config
+	if mode, _ := ParseApplyMode(""); mgr.applyMode != mode {
+		t.Errorf("applyMode = %v, want %v", mgr.applyMode, mode)
+	}
 }
 
 func TestParseAddOnsFile(t *testing.T) {
@@ -92,6 +99,40 @@ func TestWriteAddOnsFile(t *testing.T) {
 	}
 }
 
+func TestWriteAddOnsFileVerifies(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	testPath := filepath.Join(tmpDir, "AddOns.txt")
+	os.WriteFile(testPath, []byte("Addon1: 1\n"), 0644)
+
+	// A name with a line break can't be read back as written
+	addons := map[string]bool{
+		"Broken\nName": true,
+	}
+
+	if err := writeAddOnsFile(testPath, addons); err == nil {
+		t.Fatal("Expected verification error")
+	}
+
+	// The live file is left as it was
+	parsed, err := parseAddOnsFile(testPath)
+	if err != nil {
+		t.Fatalf("parseAddOnsFile() error = %v", err)
+	}
+	if len(parsed) != 1 || !parsed["Addon1"] {
+		t.Errorf("parseAddOnsFile() = %v, want only Addon1", parsed)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(entries))
+	}
+}
+
 func TestValidateWowDirectory(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -268,6 +309,40 @@ func TestLoadProfilesNoFile(t *testing.T) {
 	}
 }
 
+func TestLoadProfilesStrict(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	savedVarsDir := filepath.Join(tmpDir, "WTF", "Account", account, "SavedVariables")
+	os.MkdirAll(savedVarsDir, 0755)
+
+	// A damaged file the regex parser can still read a profile from
+	damaged := `AddonProfilesDB = { ["global"] = { ["profiles"] = { ["Raid"] = { ["addons"] = { ["DBM-Core"] = true, }, }, `
+	os.WriteFile(filepath.Join(savedVarsDir, "AddonProfilesDB.lua"), []byte(damaged), 0644)
+
+	mgr := NewManager(tmpDir, account, 5)
+	db, report, err := mgr.LoadProfilesWithReport()
+	if err != nil {
+		t.Fatalf("LoadProfilesWithReport() error = %v", err)
+	}
+	if !report.Recovered() || db.Global.Profiles["Raid"] == nil {
+		t.Errorf("LoadProfilesWithReport() = %+v, %+v, want Raid recovered", db.Global.Profiles, report)
+	}
+
+	mgr.SetParseOptions(lua.ParseOptions{Mode: lua.ParseStrict})
+	db, report, err = mgr.LoadProfilesWithReport()
+	if err == nil {
+		t.Fatalf("LoadProfilesWithReport() = %+v, want error in strict mode", db)
+	}
+	if report == nil || report.Recovered() {
+		t.Errorf("report = %+v, want not recovered", report)
+	}
+}
+
 func TestApplyProfile(t *testing.T) {
 	// Create test structure
 	tmpDir, err := os.MkdirTemp("", "wow-test-*")
@@ -289,6 +364,7 @@ func TestApplyProfile(t *testing.T) {
 	writeAddOnsFile(addonsPath, initialAddons)
 
 	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetApplyMode(ApplyModeReplace)
 
 	// Apply new profile
 	profile := &lua.Profile{
@@ -301,7 +377,7 @@ func TestApplyProfile(t *testing.T) {
 		},
 	}
 
-	if err := mgr.ApplyProfile(profile); err != nil {
+	if _, err := mgr.ApplyProfile(profile); err != nil {
 		t.Fatalf("ApplyProfile() error = %v", err)
 	}
 
@@ -374,6 +450,47 @@ func TestCleanupBackups(t *testing.T) {
 	}
 }
 
+func TestCleanupBackupsNameOrder(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+
+	// Modification times run opposite to the name stamps, as after a copy
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		backupPath := fmt.Sprintf("%s.backup.2024010%d_120000", addonsPath, i)
+		os.WriteFile(backupPath, []byte("test"), 0644)
+		mtime := now.Add(-time.Duration(i) * time.Hour)
+		os.Chtimes(backupPath, mtime, mtime)
+	}
+
+	mgr := NewManager(tmpDir, account, 3)
+	if err := mgr.cleanupBackups(addonsPath); err != nil {
+		t.Fatalf("cleanupBackups() error = %v", err)
+	}
+
+	backups, err := mgr.ListBackups(nil)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	var names []string
+	for _, backup := range backups {
+		names = append(names, strings.TrimPrefix(backup.Name, "AddOns.txt.backup."))
+	}
+	want := []string{"20240105_120000", "20240104_120000", "20240103_120000"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("backups = %v, want %v", names, want)
+	}
+}
+
 func TestGetActiveAddons(t *testing.T) {
 	// Create test structure
 	tmpDir, err := os.MkdirTemp("", "wow-test-*")
@@ -417,3 +534,272 @@ func TestGetActiveAddons(t *testing.T) {
 		t.Error("Expected AddonProfiles to be disabled")
 	}
 }
+
+func TestApplyProfileMerge(t *testing.T) {
+	// Create test structure
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	// Copy test AddOns.txt
+	data, err := os.ReadFile(filepath.Join("testdata", "AddOns.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	if err := os.WriteFile(addonsPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// An installed addon AddOns.txt doesn't list yet
+	skadaDir := filepath.Join(tmpDir, "Interface", "AddOns", "Skada")
+	os.MkdirAll(skadaDir, 0755)
+	os.WriteFile(filepath.Join(skadaDir, "Skada.toc"), []byte("## Title: Skada\n"), 0644)
+
+	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetApplyMode(ApplyModeMerge)
+
+	profile := &lua.Profile{
+		Name:  "Raiding",
+		Scope: "account",
+		Addons: map[string]bool{
+			"WeakAuras":     true,
+			"BigWigs":       true,
+			"RCLootCouncil": true,
+		},
+	}
+
+	if _, err := mgr.ApplyProfile(profile); err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+
+	entries, err := readAddOnsEntries(addonsPath)
+	if err != nil {
+		t.Fatalf("readAddOnsEntries() error = %v", err)
+	}
+
+	expected := []addonEntry{
+		{Name: "Ace3", Enabled: false},
+		{Name: "AddonProfiles", Enabled: false},
+		{Name: "Details", Enabled: false},
+		{Name: "DBM-Core", Enabled: false},
+		{Name: "WeakAuras", Enabled: true},
+		{Name: "BigWigs", Enabled: true},
+		{Name: "RCLootCouncil", Enabled: true},
+		{Name: "Skada", Enabled: false},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+
+	for i, want := range expected {
+		if entries[i] != want {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want)
+		}
+	}
+}
+
+func TestApplyProfileMergeCatalogError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, []byte("Ace3: enabled\n"), 0644)
+
+	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetApplyMode(ApplyModeMerge)
+	profile := &lua.Profile{Name: "Raiding", Scope: "account", Addons: map[string]bool{"BigWigs": true}}
+
+	// Without an AddOns folder only the file's entries are merged
+	if _, err := mgr.ApplyProfile(profile); err != nil {
+		t.Fatalf("ApplyProfile() without AddOns folder error = %v", err)
+	}
+
+	// An AddOns folder that can't be read must not silently skip the
+	// installed addons
+	os.MkdirAll(filepath.Join(tmpDir, "Interface"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "Interface", "AddOns"), []byte("not a folder"), 0644)
+	before, _ := os.ReadFile(addonsPath)
+
+	if _, err := mgr.ApplyProfile(profile); err == nil || !strings.Contains(err.Error(), "installed addons") {
+		t.Errorf("ApplyProfile() error = %v, want installed addons error", err)
+	}
+	if after, _ := os.ReadFile(addonsPath); string(after) != string(before) {
+		t.Error("AddOns.txt was rewritten without the installed addons")
+	}
+}
+
+func TestMergeAddOns(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []addonEntry
+		installed []string
+		addons    map[string]bool
+		want      []addonEntry
+	}{
+		{
+			name:     "no existing file",
+			existing: nil,
+			addons:   map[string]bool{"B": true, "A": false},
+			want:     []addonEntry{{"A", false}, {"B", true}},
+		},
+		{
+			name:     "keeps order and disables unlisted",
+			existing: []addonEntry{{"Z", true}, {"A", true}, {"M", false}},
+			addons:   map[string]bool{"M": true},
+			want:     []addonEntry{{"Z", false}, {"A", false}, {"M", true}},
+		},
+		{
+			name:     "appends new addons sorted",
+			existing: []addonEntry{{"Z", true}},
+			addons:   map[string]bool{"Z": true, "C": true, "B": false},
+			want:     []addonEntry{{"Z", true}, {"B", false}, {"C", true}},
+		},
+		{
+			name:      "disables installed addons missing from the file",
+			existing:  []addonEntry{{"Z", true}},
+			installed: []string{"Details", "WeakAuras", "Z"},
+			addons:    map[string]bool{"weakauras": true, "A": true},
+			want:      []addonEntry{{"Z", false}, {"A", true}, {"Details", false}, {"weakauras", true}},
+		},
+		{
+			name:     "matches names ignoring case",
+			existing: []addonEntry{{"Details", false}},
+			addons:   map[string]bool{"details": true},
+			want:     []addonEntry{{"Details", true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeAddOns(tt.existing, tt.installed, tt.addons)
+			if len(got) != len(tt.want) {
+				t.Fatalf("mergeAddOns() returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetAccountSummaries(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	accountsDir := filepath.Join(tmpDir, "WTF", "Account")
+	savedVarsDir := filepath.Join(accountsDir, "12345#1", "SavedVariables")
+	os.MkdirAll(savedVarsDir, 0755)
+	os.MkdirAll(filepath.Join(accountsDir, "12345#2"), 0755)
+
+	data, err := os.ReadFile(filepath.Join("testdata", "SavedVariables", "AddonProfilesDB.lua"))
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	os.WriteFile(filepath.Join(savedVarsDir, "AddonProfilesDB.lua"), data, 0644)
+
+	mgr := NewManager(tmpDir, "", 5)
+	summaries, err := mgr.GetAccountSummaries()
+	if err != nil {
+		t.Fatalf("GetAccountSummaries() error = %v", err)
+	}
+
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 summaries, got %d", len(summaries))
+	}
+
+	first := summaries[0]
+	if first.Name != "12345#1" || !first.HasProfilesDB || first.ProfileCount != 3 {
+		t.Errorf("summaries[0] = %+v, want 12345#1 with 3 profiles", first)
+	}
+
+	second := summaries[1]
+	if second.Name != "12345#2" || second.HasProfilesDB || second.ProfileCount != 0 {
+		t.Errorf("summaries[1] = %+v, want 12345#2 without AddonProfilesDB.lua", second)
+	}
+}
+
+func TestListAndRestoreBackups(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(accountDir, 0755)
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, []byte("Current: 1\n"), 0644)
+	os.WriteFile(addonsPath+".backup.20240101_120000", []byte("Oldest: 1\n"), 0644)
+	os.WriteFile(addonsPath+".backup.20240301_120000", []byte("Newest: 1\n"), 0644)
+	os.WriteFile(addonsPath+".backup.20240201_120000", []byte("Middle: 1\n"), 0644)
+
+	mgr := NewManager(tmpDir, account, 10)
+
+	backups, err := mgr.ListBackups(nil)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+
+	expected := []string{
+		"AddOns.txt.backup.20240301_120000",
+		"AddOns.txt.backup.20240201_120000",
+		"AddOns.txt.backup.20240101_120000",
+	}
+	if len(backups) != len(expected) {
+		t.Fatalf("Expected %d backups, got %d", len(expected), len(backups))
+	}
+	for i, name := range expected {
+		if backups[i].Name != name {
+			t.Errorf("backups[%d] = %v, want %v", i, backups[i].Name, name)
+		}
+	}
+
+	if backups[2].Created.Year() != 2024 || backups[2].Created.Month() != 1 {
+		t.Errorf("Created = %v, want January 2024", backups[2].Created)
+	}
+
+	if err := mgr.RestoreBackup(nil, "AddOns.txt.backup.20240101_120000"); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	addons, err := mgr.GetActiveAddons()
+	if err != nil {
+		t.Fatalf("GetActiveAddons() error = %v", err)
+	}
+
+	if len(addons) != 1 || !addons["Oldest"] {
+		t.Errorf("GetActiveAddons() = %v, want only Oldest", addons)
+	}
+
+	// The file that was replaced is kept as a new backup
+	backups, _ = mgr.ListBackups(nil)
+	if len(backups) != 4 {
+		t.Errorf("Expected 4 backups after restore, got %d", len(backups))
+	}
+
+	if err := mgr.RestoreBackup(nil, "../AddOns.txt"); err == nil {
+		t.Error("Expected error for unknown backup")
+	}
+}
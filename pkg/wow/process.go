@@ -0,0 +1,107 @@
+package wow
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Process is a running WoW client
+type Process struct {
+	PID  int
+	Name string // executable name, e.g. Wow.exe
+}
+
+// ClientDetector finds running WoW clients. Platforms without a built-in
+// detector can plug their own in with Manager.SetDetector.
+type ClientDetector interface {
+	RunningClients() ([]Process, error)
+}
+
+// ClientRunningError is returned when AddOns.txt can't be written safely
+// because a WoW client is running. WoW rewrites AddOns.txt on logout, so
+// anything written while it runs would be undone.
+type ClientRunningError struct {
+	Processes []Process
+}
+
+func (e *ClientRunningError) Error() string {
+	names := make([]string, 0, len(e.Processes))
+	for _, p := range e.Processes {
+		names = append(names, fmt.Sprintf("%s (pid %d)", p.Name, p.PID))
+	}
+	return fmt.Sprintf("World of Warcraft is running: %s; exit the game first", strings.Join(names, ", "))
+}
+
+// clientExecutables lists the lowercased executable names of WoW clients
+var clientExecutables = map[string]bool{
+	"wow.exe":           true, // retail
+	"wow-64.exe":        true, // older 64-bit retail
+	"wowt.exe":          true, // retail PTR
+	"wowb.exe":          true, // retail beta
+	"wowclassic.exe":    true, // classic and classic era
+	"wowclassict.exe":   true, // classic PTR
+	"wowclassicb.exe":   true, // classic beta
+	"world of warcraft": true, // macOS
+}
+
+// isClientExecutable reports whether a process name or path is a WoW
+// client. Both / and \ separated paths are accepted, since clients run
+// under Wine report Windows paths.
+func isClientExecutable(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	return clientExecutables[strings.ToLower(filepath.Base(name))]
+}
+
+// noDetector is used where no detector is available; it never finds a client
+type noDetector struct{}
+
+func (noDetector) RunningClients() ([]Process, error) {
+	return nil, nil
+}
+
+// SetDetector replaces the detector used to find running clients. A nil
+// detector disables the check.
+func (m *Manager) SetDetector(detector ClientDetector) {
+	if detector == nil {
+		detector = noDetector{}
+	}
+	m.detector = detector
+}
+
+// RunningClients returns the WoW clients that are currently running
+func (m *Manager) RunningClients() ([]Process, error) {
+	return m.detector.RunningClients()
+}
+
+// checkClient returns a *ClientRunningError if a WoW client is running.
+// Detection failures don't block writing.
+func (m *Manager) checkClient() error {
+	processes, err := m.detector.RunningClients()
+	if err != nil {
+		m.warnf("failed to check for a running WoW client: %v", err)
+		return nil
+	}
+	if len(processes) > 0 {
+		return &ClientRunningError{Processes: processes}
+	}
+	return nil
+}
+
+// WaitForClientExit polls every interval until no WoW client is running,
+// or until ctx is done
+func (m *Manager) WaitForClientExit(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := m.checkClient(); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
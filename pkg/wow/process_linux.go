@@ -0,0 +1,70 @@
+package wow
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDetector finds WoW clients, including ones run under Wine or Proton,
+// by scanning /proc
+type procDetector struct {
+	root string
+}
+
+// DefaultDetector returns the client detector for this platform
+func DefaultDetector() ClientDetector {
+	return procDetector{root: "/proc"}
+}
+
+// RunningClients scans each process's name and command line for a WoW
+// client executable
+func (d procDetector) RunningClients() ([]Process, error) {
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		// Processes may exit while we scan, so read errors are skipped
+		if name := d.clientName(filepath.Join(d.root, entry.Name())); name != "" {
+			processes = append(processes, Process{PID: pid, Name: name})
+		}
+	}
+
+	return processes, nil
+}
+
+// clientName returns the client executable a process runs, or ""
+func (d procDetector) clientName(dir string) string {
+	// Wine starts Windows programs with the .exe path as the first argument
+	// to a loader, so look at the first arguments rather than just argv[0]
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+		for i, arg := range args {
+			if i > 2 {
+				break
+			}
+			if isClientExecutable(string(arg)) {
+				return filepath.Base(strings.ReplaceAll(string(arg), "\\", "/"))
+			}
+		}
+	}
+
+	if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		name := strings.TrimSpace(string(comm))
+		if isClientExecutable(name) {
+			return name
+		}
+	}
+
+	return ""
+}
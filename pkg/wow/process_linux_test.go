@@ -0,0 +1,50 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcDetector(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	procs := map[string]struct {
+		comm    string
+		cmdline string
+	}{
+		// Wine runs the client through a loader
+		"100": {"Wow.exe", "C:\\Program Files (x86)\\World of Warcraft\\_retail_\\Wow.exe\x00-launcherlogin\x00"},
+		"200": {"wine64-preloade", "/usr/bin/wine64-preloader\x00Z:\\games\\wow\\_classic_\\WowClassic.exe\x00"},
+		"300": {"bash", "/bin/bash\x00"},
+		"400": {"Battle.net.exe", "C:\\Program Files (x86)\\Battle.net\\Battle.net.exe\x00"},
+	}
+	for pid, p := range procs {
+		dir := filepath.Join(tmpDir, pid)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "comm"), []byte(p.comm+"\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "cmdline"), []byte(p.cmdline), 0644)
+	}
+
+	// Non-process entries are ignored
+	os.MkdirAll(filepath.Join(tmpDir, "sys"), 0755)
+
+	processes, err := procDetector{root: tmpDir}.RunningClients()
+	if err != nil {
+		t.Fatalf("RunningClients() error = %v", err)
+	}
+
+	want := map[int]string{100: "Wow.exe", 200: "WowClassic.exe"}
+	if len(processes) != len(want) {
+		t.Fatalf("RunningClients() = %v, want %v", processes, want)
+	}
+	for _, p := range processes {
+		if want[p.PID] != p.Name {
+			t.Errorf("process %d = %v, want %v", p.PID, p.Name, want[p.PID])
+		}
+	}
+}
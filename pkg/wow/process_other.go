@@ -0,0 +1,10 @@
+//go:build !linux
+
+package wow
+
+// DefaultDetector returns the client detector for this platform. There is
+// no built-in detector here yet, so running clients aren't detected unless
+// one is set with Manager.SetDetector.
+func DefaultDetector() ClientDetector {
+	return noDetector{}
+}
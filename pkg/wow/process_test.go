@@ -0,0 +1,151 @@
+package wow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+// fakeDetector reports a fixed set of running clients
+type fakeDetector struct {
+	mu        sync.Mutex
+	processes []Process
+}
+
+func (d *fakeDetector) RunningClients() ([]Process, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.processes, nil
+}
+
+func (d *fakeDetector) set(processes []Process) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.processes = processes
+}
+
+// failingDetector can't tell whether a client is running
+type failingDetector struct{}
+
+func (failingDetector) RunningClients() ([]Process, error) {
+	return nil, errors.New("no access to the process list")
+}
+
+func TestIsClientExecutable(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Wow.exe", true},
+		{"WowClassic.exe", true},
+		{"C:\\Program Files (x86)\\World of Warcraft\\_retail_\\Wow.exe", true},
+		{"/home/user/Games/wow/drive_c/World of Warcraft/_classic_era_/WowClassic.exe", true},
+		{"/Applications/World of Warcraft/_retail_/World of Warcraft.app/Contents/MacOS/World of Warcraft", true},
+		{"Battle.net.exe", false},
+		{"wine64-preloader", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isClientExecutable(tt.name); got != tt.want {
+				t.Errorf("isClientExecutable(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyProfileClientRunning(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(filepath.Join(accountDir, "Realm", "Char"), 0755)
+
+	addonsPath := filepath.Join(accountDir, "AddOns.txt")
+	os.WriteFile(addonsPath, []byte("Details: 1\n"), 0644)
+
+	detector := &fakeDetector{processes: []Process{{PID: 42, Name: "Wow.exe"}}}
+	mgr := NewManager(tmpDir, account, 5)
+	mgr.SetDetector(detector)
+
+	profile := &lua.Profile{Name: "Raiding", Addons: map[string]bool{"DBM-Core": true}}
+
+	_, err = mgr.ApplyProfile(profile)
+	var running *ClientRunningError
+	if !errors.As(err, &running) {
+		t.Fatalf("ApplyProfile() error = %v, want *ClientRunningError", err)
+	}
+	if len(running.Processes) != 1 || running.Processes[0].PID != 42 {
+		t.Errorf("Processes = %v, want Wow.exe with pid 42", running.Processes)
+	}
+
+	if _, err := mgr.ApplyProfileToAllCharacters(profile); !errors.As(err, &running) {
+		t.Errorf("ApplyProfileToAllCharacters() error = %v, want *ClientRunningError", err)
+	}
+
+	// Nothing was written or backed up
+	addons, _ := mgr.GetActiveAddons()
+	if len(addons) != 1 || !addons["Details"] {
+		t.Errorf("GetActiveAddons() = %v, want only Details", addons)
+	}
+	backups, _ := mgr.ListBackups(nil)
+	if len(backups) != 0 {
+		t.Errorf("Expected no backups, got %d", len(backups))
+	}
+
+	detector.set(nil)
+	if _, err := mgr.ApplyProfile(profile); err != nil {
+		t.Errorf("ApplyProfile() after exit error = %v", err)
+	}
+}
+
+func TestCheckClientWarning(t *testing.T) {
+	mgr := NewManager("", "", 5)
+	mgr.SetDetector(failingDetector{})
+
+	var warnings bytes.Buffer
+	mgr.SetWarningOutput(&warnings)
+
+	// A failed check warns but doesn't block writing
+	if err := mgr.checkClient(); err != nil {
+		t.Errorf("checkClient() error = %v, want nil", err)
+	}
+	if !strings.Contains(warnings.String(), "no access to the process list") {
+		t.Errorf("warnings = %q, want the detection error", warnings.String())
+	}
+}
+
+func TestWaitForClientExit(t *testing.T) {
+	detector := &fakeDetector{processes: []Process{{PID: 42, Name: "Wow.exe"}}}
+	mgr := NewManager("", "", 5)
+	mgr.SetDetector(detector)
+
+	// Gives up when the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := mgr.WaitForClientExit(ctx, time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("WaitForClientExit() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Returns once the client exits
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		detector.set(nil)
+	}()
+	if err := mgr.WaitForClientExit(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("WaitForClientExit() error = %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package wow
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmervine/AddonProfiles-GUI/pkg/fsutil"
+	"github.com/jmervine/AddonProfiles-GUI/pkg/lua"
+)
+
+// profilesDBPath returns the addon's SavedVariables file of the selected account
+func (m *Manager) profilesDBPath() string {
+	return m.savedVariablesPath("AddonProfilesDB", nil)
+}
+
+// savedVariablesPath returns the SavedVariables file named name of a
+// character, or of the account when char is nil
+func (m *Manager) savedVariablesPath(name string, char *Character) string {
+	dir := m.accountPath()
+	if char != nil {
+		dir = filepath.Join(dir, char.Realm, char.Name)
+	}
+	return filepath.Join(dir, "SavedVariables", name+".lua")
+}
+
+// LoadSavedVariables reads every global of the SavedVariables file named
+// name, such as an addon's per-character settings when char is given
+func (m *Manager) LoadSavedVariables(name string, char *Character) (map[string]lua.Value, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	globals, err := lua.ParseChunkFile(m.savedVariablesPath(name, char))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	return globals, nil
+}
+
+// CreateProfileFromAddons saves the addons currently enabled in a
+// character's AddOns.txt, or the account's when char is nil, as a new
+// profile of that character or the account
+func (m *Manager) CreateProfileFromAddons(name string, char *Character) (*lua.Profile, error) {
+	if m.selectedAccount == "" {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	current, err := readAddOns(m.AddOnsPath(char))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AddOns.txt: %w", err)
+	}
+
+	addons := make(map[string]bool)
+	for addon, enabled := range current {
+		if enabled {
+			addons[addon] = true
+		}
+	}
+
+	return m.CreateProfile(name, char, addons)
+}
+
+// CreateProfile adds a profile with the given addons to a character, or
+// to the account when char is nil
+func (m *Manager) CreateProfile(name string, char *Character, addons map[string]bool) (*lua.Profile, error) {
+	name, err := validateProfileName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &lua.Profile{
+		Name:     name,
+		Scope:    profileScope(char),
+		Addons:   copyAddons(addons),
+		AutoDeps: true,
+		Created:  time.Now().Unix(),
+	}
+
+	err = m.updateProfiles(char, fmt.Sprintf("Created profile '%s'", name), func(active *string, profiles map[string]*lua.Profile) error {
+		if _, exists := profiles[name]; exists {
+			return fmt.Errorf("profile already exists: %s", name)
+		}
+		profiles[name] = profile
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// SetProfileAddons replaces the addon set of a profile
+func (m *Manager) SetProfileAddons(name string, char *Character, addons map[string]bool) error {
+	return m.updateProfiles(char, fmt.Sprintf("Edited profile '%s'", name), func(active *string, profiles map[string]*lua.Profile) error {
+		profile, ok := profiles[name]
+		if !ok {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+		profile.Addons = copyAddons(addons)
+		return nil
+	})
+}
+
+// RenameProfile renames a profile, keeping it active if it was
+func (m *Manager) RenameProfile(oldName, newName string, char *Character) error {
+	newName, err := validateProfileName(newName)
+	if err != nil {
+		return err
+	}
+
+	return m.updateProfiles(char, fmt.Sprintf("Renamed profile '%s' to '%s'", oldName, newName), func(active *string, profiles map[string]*lua.Profile) error {
+		profile, ok := profiles[oldName]
+		if !ok {
+			return fmt.Errorf("profile not found: %s", oldName)
+		}
+		if newName == oldName {
+			return nil
+		}
+		if _, exists := profiles[newName]; exists {
+			return fmt.Errorf("profile already exists: %s", newName)
+		}
+
+		delete(profiles, oldName)
+		profile.Name = newName
+		profiles[newName] = profile
+
+		if *active == oldName {
+			*active = newName
+		}
+		return nil
+	})
+}
+
+// DeleteProfile removes a profile. If it was active, no profile is active
+// afterwards.
+func (m *Manager) DeleteProfile(name string, char *Character) error {
+	return m.updateProfiles(char, fmt.Sprintf("Deleted profile '%s'", name), func(active *string, profiles map[string]*lua.Profile) error {
+		if _, ok := profiles[name]; !ok {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+
+		delete(profiles, name)
+		if *active == name {
+			*active = ""
+		}
+		return nil
+	})
+}
+
+// SetActiveProfile marks a profile as active. An empty name clears it.
+func (m *Manager) SetActiveProfile(name string, char *Character) error {
+	return m.updateProfiles(char, fmt.Sprintf("Activated profile '%s'", name), func(active *string, profiles map[string]*lua.Profile) error {
+		if _, ok := profiles[name]; !ok && name != "" {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+		*active = name
+		return nil
+	})
+}
+
+// updateProfiles loads AddonProfilesDB.lua, lets update change the
+// profiles of a character, or of the account when char is nil, and writes
+// the file back after backing it up
+func (m *Manager) updateProfiles(char *Character, reason string, update func(active *string, profiles map[string]*lua.Profile) error) error {
+	if m.selectedAccount == "" {
+		return fmt.Errorf("no account selected")
+	}
+
+	// WoW writes SavedVariables on logout, which would undo the change
+	if err := m.checkClient(); err != nil {
+		return err
+	}
+
+	db, report, err := m.LoadProfilesWithReport()
+	if err != nil {
+		return err
+	}
+
+	// Profiles the fallback parser couldn't read would be lost on save
+	if report.Recovered() {
+		return fmt.Errorf("AddonProfilesDB.lua is damaged, fix or restore it before editing profiles: %w", report.Errors[0])
+	}
+
+	// So would entries the simple parser skipped for having the wrong type
+	if !report.Complete() {
+		return fmt.Errorf("AddonProfilesDB.lua has an entry of the wrong type, fix or restore it before editing profiles: %w", report.Errors[0])
+	}
+
+	if char == nil {
+		if db.Global.Profiles == nil {
+			db.Global.Profiles = make(map[string]*lua.Profile)
+		}
+		if err := update(&db.Global.ActiveProfile, db.Global.Profiles); err != nil {
+			return err
+		}
+	} else {
+		if db.Char == nil {
+			db.Char = make(map[string]lua.CharData)
+		}
+
+		charData := db.Char[char.Key()]
+		if charData.Profiles == nil {
+			charData.Profiles = make(map[string]*lua.Profile)
+		}
+		if err := update(&charData.ActiveProfile, charData.Profiles); err != nil {
+			return err
+		}
+		db.Char[char.Key()] = charData
+	}
+
+	return m.saveProfiles(db, reason)
+}
+
+// saveProfiles writes AddonProfilesDB.lua, keeping a backup of the
+// previous version
+func (m *Manager) saveProfiles(db *lua.Database, reason string) error {
+	path := m.profilesDBPath()
+
+	data, err := encodeProfiles(path, db)
+	if err != nil {
+		return fmt.Errorf("failed to encode profiles: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := m.createBackup(path, backupMeta{Reason: reason}); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write profiles: %w", err)
+	}
+
+	if err := m.cleanupBackups(path); err != nil {
+		// Warn but don't fail
+		m.warnf("failed to cleanup old backups: %v", err)
+	}
+
+	return nil
+}
+
+// encodeProfiles returns the new contents of the file at path. An existing
+// file is edited in place so only the changed entries are rewritten, and
+// keys, comments and other globals the addon keeps there survive.
+func encodeProfiles(path string, db *lua.Database) ([]byte, error) {
+	if raw, err := os.ReadFile(path); err == nil {
+		doc, docErr := lua.ParseDocument(raw)
+		old, oldErr := lua.ParseSimple(string(raw))
+		if docErr == nil && oldErr == nil {
+			if err := patchProfiles(doc, old, db); err != nil {
+				return nil, err
+			}
+			return doc.Bytes(), nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := lua.Write(&buf, db); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// patchProfiles edits doc from the old to the new profiles
+func patchProfiles(doc *lua.Document, old, db *lua.Database) error {
+	root := lua.NewPath("AddonProfilesDB")
+
+	err := patchScope(doc, append(root, lua.String("global")), "account",
+		old.Global.ActiveProfile, old.Global.Profiles, db.Global.ActiveProfile, db.Global.Profiles)
+	if err != nil {
+		return err
+	}
+
+	charKeys := make(map[string]bool)
+	for key := range old.Char {
+		charKeys[key] = true
+	}
+	for key := range db.Char {
+		charKeys[key] = true
+	}
+
+	// Edits are made in key order so new entries are added in order
+	for _, key := range sortedKeys(charKeys) {
+		path := append(root, lua.String("char"), lua.String(key))
+		before, after := old.Char[key], db.Char[key]
+		if err := patchScope(doc, path, "character", before.ActiveProfile, before.Profiles, after.ActiveProfile, after.Profiles); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patchScope edits the active profile and profiles stored at path
+func patchScope(doc *lua.Document, path lua.Path, scope, oldActive string, oldProfiles map[string]*lua.Profile, active string, profiles map[string]*lua.Profile) error {
+	at := func(keys ...string) lua.Path {
+		p := append(lua.Path{}, path...)
+		for _, key := range keys {
+			p = append(p, lua.String(key))
+		}
+		return p
+	}
+
+	if active != oldActive {
+		var value interface{}
+		if active != "" {
+			value = active
+		}
+		if err := doc.Set(at("activeProfile"), value); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(oldProfiles) {
+		if _, ok := profiles[name]; !ok {
+			if err := doc.Delete(at("profiles", name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(profiles) {
+		profile := profiles[name]
+		before, ok := oldProfiles[name]
+		if !ok {
+			saved := *profile
+			saved.Scope = scope
+			if err := doc.Set(at("profiles", name), &saved); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Only touch the entries that changed
+		for _, addon := range sortedKeys(profile.Addons) {
+			enabled := profile.Addons[addon]
+			if was, ok := before.Addons[addon]; !ok || was != enabled {
+				if err := doc.Set(at("profiles", name, "addons", addon), enabled); err != nil {
+					return err
+				}
+			}
+		}
+		for _, addon := range sortedKeys(before.Addons) {
+			if _, ok := profile.Addons[addon]; !ok {
+				if err := doc.Delete(at("profiles", name, "addons", addon)); err != nil {
+					return err
+				}
+			}
+		}
+		if profile.AutoDeps != before.AutoDeps {
+			if err := doc.Set(at("profiles", name, "autoDeps"), profile.AutoDeps); err != nil {
+				return err
+			}
+		}
+		if profile.Created != before.Created {
+			if err := doc.Set(at("profiles", name, "created"), profile.Created); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateProfileName trims a profile name and rejects empty ones
+func validateProfileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("profile name is empty")
+	}
+	return name, nil
+}
+
+// profileScope returns the scope of profiles stored for char
+func profileScope(char *Character) string {
+	if char == nil {
+		return "account"
+	}
+	return "character"
+}
+
+// copyAddons copies an addon set so later edits don't leak into a profile
+func copyAddons(addons map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(addons))
+	for name, enabled := range addons {
+		result[name] = enabled
+	}
+	return result
+}
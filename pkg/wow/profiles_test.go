@@ -0,0 +1,339 @@
+package wow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupProfiles creates an account with the test AddonProfilesDB.lua and
+// AddOns.txt and a character folder
+func setupProfiles(t *testing.T) (*Manager, string) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	account := "TestAccount"
+	accountDir := filepath.Join(tmpDir, "WTF", "Account", account)
+	os.MkdirAll(filepath.Join(accountDir, "SavedVariables"), 0755)
+	os.MkdirAll(filepath.Join(accountDir, "TestRealm", "TestChar"), 0755)
+
+	for _, file := range []string{filepath.Join("SavedVariables", "AddonProfilesDB.lua"), "AddOns.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("Failed to read test file: %v", err)
+		}
+		os.WriteFile(filepath.Join(accountDir, file), data, 0644)
+	}
+
+	return NewManager(tmpDir, account, 5), tmpDir
+}
+
+func TestCreateProfileFromAddons(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	profile, err := mgr.CreateProfileFromAddons("  Questing ", nil)
+	if err != nil {
+		t.Fatalf("CreateProfileFromAddons() error = %v", err)
+	}
+	if profile.Name != "Questing" {
+		t.Errorf("Name = %v, want %v", profile.Name, "Questing")
+	}
+
+	db, err := mgr.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+
+	saved, ok := db.Global.Profiles["Questing"]
+	if !ok {
+		t.Fatal("profile Questing not saved")
+	}
+
+	// Only the enabled addons of AddOns.txt are taken
+	want := []string{"Ace3", "BigWigs", "DBM-Core", "Details"}
+	if len(saved.Addons) != len(want) {
+		t.Errorf("Addons = %v, want %v", saved.Addons, want)
+	}
+	for _, addon := range want {
+		if !saved.Addons[addon] {
+			t.Errorf("Addons[%s] = false, want true", addon)
+		}
+	}
+
+	// Existing profiles and settings are kept
+	if len(db.Global.Profiles) != 3 || db.Global.ActiveProfile != "Default" {
+		t.Errorf("global = %d profiles, active %q; want 3 profiles, active Default", len(db.Global.Profiles), db.Global.ActiveProfile)
+	}
+	if db.Global.Settings == nil || !db.Global.Settings.HideDefaultAddonsButton {
+		t.Errorf("Settings = %v, want hideDefaultAddonsButton kept", db.Global.Settings)
+	}
+	if len(db.Char["TestChar - TestRealm"].Profiles) != 1 {
+		t.Errorf("character profiles = %v, want PvP kept", db.Char["TestChar - TestRealm"].Profiles)
+	}
+
+	// The previous file is backed up
+	backups, _ := listBackups(mgr.profilesDBPath())
+	if len(backups) != 1 || !strings.Contains(backups[0].Reason, "Questing") {
+		t.Errorf("backups = %v, want one for Questing", backups)
+	}
+
+	if _, err := mgr.CreateProfileFromAddons("Questing", nil); err == nil {
+		t.Error("Expected error for duplicate profile")
+	}
+	if _, err := mgr.CreateProfileFromAddons(" ", nil); err == nil {
+		t.Error("Expected error for empty name")
+	}
+}
+
+func TestCharacterProfileEdits(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	char := &Character{Realm: "TestRealm", Name: "TestChar"}
+
+	if _, err := mgr.CreateProfile("Healing", char, map[string]bool{"VuhDo": true}); err != nil {
+		t.Fatalf("CreateProfile() error = %v", err)
+	}
+	if err := mgr.SetProfileAddons("Healing", char, map[string]bool{"VuhDo": true, "Grid2": true}); err != nil {
+		t.Fatalf("SetProfileAddons() error = %v", err)
+	}
+	if err := mgr.SetActiveProfile("Healing", char); err != nil {
+		t.Fatalf("SetActiveProfile() error = %v", err)
+	}
+	if err := mgr.RenameProfile("Healing", "Raid Healing", char); err != nil {
+		t.Fatalf("RenameProfile() error = %v", err)
+	}
+
+	db, err := mgr.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+
+	charData := db.Char["TestChar - TestRealm"]
+	if charData.ActiveProfile != "Raid Healing" {
+		t.Errorf("ActiveProfile = %v, want %v", charData.ActiveProfile, "Raid Healing")
+	}
+	if _, ok := charData.Profiles["Healing"]; ok {
+		t.Error("old name Healing still present")
+	}
+	profile, ok := charData.Profiles["Raid Healing"]
+	if !ok {
+		t.Fatal("profile Raid Healing missing")
+	}
+	if profile.Scope != "character" || len(profile.Addons) != 2 || !profile.Addons["Grid2"] {
+		t.Errorf("profile = %+v, want character scope with VuhDo and Grid2", profile)
+	}
+
+	// The account's profiles are untouched
+	if _, ok := db.Global.Profiles["Raid Healing"]; ok {
+		t.Error("character profile saved to the account")
+	}
+
+	if err := mgr.DeleteProfile("Raid Healing", char); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	db, _ = mgr.LoadProfiles()
+	charData = db.Char["TestChar - TestRealm"]
+	if _, ok := charData.Profiles["Raid Healing"]; ok || charData.ActiveProfile != "" {
+		t.Errorf("after delete = %+v, want Raid Healing gone and nothing active", charData)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"rename missing", mgr.RenameProfile("Missing", "Other", char)},
+		{"rename to existing", mgr.RenameProfile("Default", "Raiding", nil)},
+		{"delete missing", mgr.DeleteProfile("Missing", nil)},
+		{"activate missing", mgr.SetActiveProfile("Missing", nil)},
+		{"edit missing", mgr.SetProfileAddons("Missing", nil, nil)},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestProfileEditsClientRunning(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	before, _ := os.ReadFile(mgr.profilesDBPath())
+
+	mgr.SetDetector(&fakeDetector{processes: []Process{{PID: 42, Name: "Wow.exe"}}})
+	if err := mgr.DeleteProfile("Default", nil); err == nil {
+		t.Fatal("Expected error while WoW is running")
+	}
+
+	after, _ := os.ReadFile(mgr.profilesDBPath())
+	if string(after) != string(before) {
+		t.Error("AddonProfilesDB.lua changed while WoW was running")
+	}
+}
+
+func TestProfileEditsDamagedFile(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	damaged := []byte("AddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"activeProfile\"] = \"Default\"\n\t\t[\"profiles\"] = {},\n\t},\n}\n")
+	os.WriteFile(mgr.profilesDBPath(), damaged, 0644)
+
+	_, report, err := mgr.LoadProfilesWithReport()
+	if err != nil {
+		t.Fatalf("LoadProfilesWithReport() error = %v", err)
+	}
+	if !report.Recovered() {
+		t.Errorf("Recovered() = false, want true")
+	}
+
+	err = mgr.SetActiveProfile("", nil)
+	if err == nil || !strings.Contains(err.Error(), "line 4, column 3") {
+		t.Errorf("SetActiveProfile() error = %v, want damaged file at line 4, column 3", err)
+	}
+
+	after, _ := os.ReadFile(mgr.profilesDBPath())
+	if string(after) != string(damaged) {
+		t.Error("damaged AddonProfilesDB.lua was rewritten")
+	}
+}
+
+func TestProfileEditsWrongType(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Rewriting the file would drop the autoDeps that isn't a boolean
+	mistyped := []byte("AddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"profiles\"] = {\n\t\t\t[\"Raid\"] = {\n\t\t\t\t[\"autoDeps\"] = \"yes\",\n\t\t\t},\n\t\t},\n\t},\n}\n")
+	os.WriteFile(mgr.profilesDBPath(), mistyped, 0644)
+
+	db, report, err := mgr.LoadProfilesWithReport()
+	if err != nil {
+		t.Fatalf("LoadProfilesWithReport() error = %v", err)
+	}
+	if report.Complete() || db.Global.Profiles["Raid"] == nil {
+		t.Errorf("LoadProfilesWithReport() = %+v, want Raid read and the wrong type reported", report)
+	}
+
+	err = mgr.SetActiveProfile("Raid", nil)
+	if err == nil || !strings.Contains(err.Error(), "global.profiles.Raid.autoDeps") {
+		t.Errorf("SetActiveProfile() error = %v, want wrong type at global.profiles.Raid.autoDeps", err)
+	}
+
+	after, _ := os.ReadFile(mgr.profilesDBPath())
+	if string(after) != string(mistyped) {
+		t.Error("AddonProfilesDB.lua with an entry of the wrong type was rewritten")
+	}
+}
+
+func TestProfileEditsOlderFile(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Older addon versions left out autoDeps and scope
+	old := "AddonProfilesDB = {\n\t[\"global\"] = {\n\t\t[\"profiles\"] = {\n\t\t\t[\"Default\"] = {\n\t\t\t\t[\"addons\"] = {\n\t\t\t\t\t[\"Ace3\"] = true,\n\t\t\t\t},\n\t\t\t},\n\t\t},\n\t},\n}\n"
+	os.WriteFile(mgr.profilesDBPath(), []byte(old), 0644)
+
+	db, err := mgr.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	if profile := db.Global.Profiles["Default"]; profile == nil || !profile.AutoDeps || profile.Scope != "account" {
+		t.Errorf("Default = %+v, want autoDeps and account scope filled in", profile)
+	}
+
+	if err := mgr.SetActiveProfile("Default", nil); err != nil {
+		t.Fatalf("SetActiveProfile() error = %v", err)
+	}
+
+	// Only the edited entry is written; the defaults stay implicit
+	want := strings.Replace(old, "\t},\n}\n", "\t\t[\"activeProfile\"] = \"Default\",\n\t},\n}\n", 1)
+	got, _ := os.ReadFile(mgr.profilesDBPath())
+	if string(got) != want {
+		t.Errorf("AddonProfilesDB.lua =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProfileEditsKeepFile(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Things only the addon knows about must survive an edit
+	original, _ := os.ReadFile(mgr.profilesDBPath())
+	content := "-- written by AddonProfiles\n" + strings.Replace(string(original),
+		"\t\t[\"settings\"] = {\n",
+		"\t\t[\"minimap\"] = { hide = true },\n\t\t[\"settings\"] = {\n", 1) +
+		"AddonProfilesDBVersion = 2\n"
+	os.WriteFile(mgr.profilesDBPath(), []byte(content), 0644)
+
+	if err := mgr.SetActiveProfile("Raiding", nil); err != nil {
+		t.Fatalf("SetActiveProfile() error = %v", err)
+	}
+	err := mgr.SetProfileAddons("Raiding", nil, map[string]bool{
+		"BigWigs": true, "WeakAuras": true, "Details": true,
+	})
+	if err != nil {
+		t.Fatalf("SetProfileAddons() error = %v", err)
+	}
+
+	want := strings.Replace(content, "\"Default\",\n", "\"Raiding\",\n", 1)
+	want = strings.Replace(want,
+		"\t\t\t\t\t[\"RCLootCouncil\"] = true,\n",
+		"\t\t\t\t\t[\"Details\"] = true,\n", 1)
+
+	got, _ := os.ReadFile(mgr.profilesDBPath())
+	if string(got) != want {
+		t.Errorf("AddonProfilesDB.lua =\n%s\nwant\n%s", got, want)
+	}
+
+	// New profiles are written in full
+	if _, err := mgr.CreateProfile("Solo", nil, map[string]bool{"Ace3": true}); err != nil {
+		t.Fatalf("CreateProfile() error = %v", err)
+	}
+	db, err := mgr.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	if solo := db.Global.Profiles["Solo"]; solo == nil || !solo.Addons["Ace3"] || solo.Scope != "account" {
+		t.Errorf("Solo = %+v, want account profile with Ace3", solo)
+	}
+	got, _ = os.ReadFile(mgr.profilesDBPath())
+	if !strings.Contains(string(got), "AddonProfilesDBVersion = 2") || !strings.Contains(string(got), "hide = true") {
+		t.Error("unknown keys lost when adding a profile")
+	}
+}
+
+func TestLoadSavedVariables(t *testing.T) {
+	mgr, tmpDir := setupProfiles(t)
+	defer os.RemoveAll(tmpDir)
+
+	char := &Character{Realm: "TestRealm", Name: "TestChar"}
+	dir := filepath.Join(tmpDir, "WTF", "Account", "TestAccount", "TestRealm", "TestChar", "SavedVariables")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "Details.lua"), []byte("\n_detalhes_database = {\n\t[\"tabela_historico\"] = {\n\t},\n}\nDetailsTimeLine = nil\n"), 0644)
+
+	globals, err := mgr.LoadSavedVariables("Details", char)
+	if err != nil {
+		t.Fatalf("LoadSavedVariables() error = %v", err)
+	}
+	if len(globals) != 2 || globals["_detalhes_database"] == nil {
+		t.Errorf("globals = %v, want _detalhes_database and DetailsTimeLine", globals)
+	}
+
+	globals, err = mgr.LoadSavedVariables("AddonProfilesDB", nil)
+	if err != nil {
+		t.Fatalf("LoadSavedVariables() error = %v", err)
+	}
+	if _, ok := globals["AddonProfilesDB"]; !ok {
+		t.Errorf("globals = %v, want AddonProfilesDB", globals)
+	}
+
+	if _, err := mgr.LoadSavedVariables("Missing", char); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,251 @@
+package wow
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Addon describes an addon installed under Interface/AddOns
+type Addon struct {
+	Name                       string // folder name, as used in AddOns.txt
+	Title                      string
+	Notes                      string
+	Version                    string
+	Interface                  []int
+	Dependencies               []string
+	OptionalDeps               []string
+	LoadOnDemand               bool
+	SavedVariables             []string
+	SavedVariablesPerCharacter []string
+	Extra                      map[string]string // X-* fields
+	TocPath                    string
+}
+
+// DisplayTitle returns the addon title without WoW color codes,
+// falling back to the folder name when the toc has no title
+func (a *Addon) DisplayTitle() string {
+	title := strings.TrimSpace(stripColorCodes(a.Title))
+	if title == "" {
+		return a.Name
+	}
+	return title
+}
+
+// AddonCatalog is the set of installed addons keyed by folder name
+type AddonCatalog map[string]*Addon
+
+// Names returns the addon folder names in sorted order
+func (c AddonCatalog) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup finds an addon by folder name. WoW treats addon names
+// case-insensitively, so an exact match is tried first and then a
+// case-insensitive one.
+func (c AddonCatalog) Lookup(name string) (*Addon, bool) {
+	if addon, ok := c[name]; ok {
+		return addon, true
+	}
+	for key, addon := range c {
+		if strings.EqualFold(key, name) {
+			return addon, true
+		}
+	}
+	return nil, false
+}
+
+// defaultTocSuffixes are the flavor suffixes tried for a retail client
+var defaultTocSuffixes = []string{"Mainline"}
+
+// GetInstalledAddons scans Interface/AddOns and returns the installed
+// addons. Addons whose toc file can't be read are left out with a warning.
+func (m *Manager) GetInstalledAddons() (AddonCatalog, error) {
+	addonsDir := filepath.Join(m.clientPath(), "Interface", "AddOns")
+	catalog, skipped, err := loadAddonCatalog(addonsDir, m.tocSuffixes())
+	for _, err := range skipped {
+		m.warnf("skipped addon: %v", err)
+	}
+	return catalog, err
+}
+
+// loadAddonCatalog reads the toc file of every addon folder in addonsDir.
+// Folders without a toc file usable by the client are skipped, and so are
+// those whose toc file can't be read, which are returned as skipped.
+func loadAddonCatalog(addonsDir string, suffixes []string) (AddonCatalog, []error, error) {
+	entries, err := os.ReadDir(addonsDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read AddOns directory: %w", err)
+	}
+
+	catalog := make(AddonCatalog)
+	var skipped []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(addonsDir, entry.Name())
+		tocPath, ok := findTocFile(dir, entry.Name(), suffixes)
+		if !ok {
+			continue
+		}
+
+		addon, err := parseTocFile(tocPath)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("failed to parse %s: %w", tocPath, err))
+			continue
+		}
+		addon.Name = entry.Name()
+		catalog[addon.Name] = addon
+	}
+
+	return catalog, skipped, nil
+}
+
+// findTocFile picks the toc file the client would load for an addon folder.
+// Flavor-specific files such as Name_Mainline.toc or Name-Classic.toc win
+// over the plain Name.toc, in the order given by suffixes.
+func findTocFile(dir, name string, suffixes []string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files[strings.ToLower(entry.Name())] = entry.Name()
+		}
+	}
+
+	var candidates []string
+	for _, suffix := range suffixes {
+		candidates = append(candidates,
+			name+"_"+suffix+".toc",
+			name+"-"+suffix+".toc",
+		)
+	}
+	candidates = append(candidates, name+".toc")
+
+	for _, candidate := range candidates {
+		if file, ok := files[strings.ToLower(candidate)]; ok {
+			return filepath.Join(dir, file), true
+		}
+	}
+
+	return "", false
+}
+
+// maxTocLine is the longest toc line read; longer lines fail the toc
+const maxTocLine = 1 << 20
+
+// parseTocFile parses the metadata lines of a toc file
+func parseTocFile(path string) (*Addon, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	addon := &Addon{
+		Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Extra:   make(map[string]string),
+		TocPath: path,
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTocLine)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		// Metadata lines look like "## Key: Value"; other lines are
+		// comments or file names
+		if !strings.HasPrefix(line, "##") {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "##"), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch {
+		case key == "Title":
+			addon.Title = value
+		case key == "Notes":
+			addon.Notes = value
+		case key == "Version":
+			addon.Version = value
+		case key == "Interface":
+			addon.Interface = parseInterfaceList(value)
+		case key == "OptionalDeps":
+			addon.OptionalDeps = append(addon.OptionalDeps, splitTocList(value)...)
+		case key == "RequiredDeps" || strings.HasPrefix(key, "Dep"):
+			// The client treats any tag starting with "Dep" as a dependency list
+			addon.Dependencies = append(addon.Dependencies, splitTocList(value)...)
+		case key == "LoadOnDemand":
+			addon.LoadOnDemand = value == "1"
+		case key == "SavedVariables":
+			addon.SavedVariables = splitTocList(value)
+		case key == "SavedVariablesPerCharacter":
+			addon.SavedVariablesPerCharacter = splitTocList(value)
+		case strings.HasPrefix(key, "X-"):
+			addon.Extra[key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return addon, nil
+}
+
+// splitTocList splits a comma separated toc value
+func splitTocList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseInterfaceList parses "## Interface: 110002, 40400"
+func parseInterfaceList(value string) []int {
+	var versions []int
+	for _, item := range splitTocList(value) {
+		if version, err := strconv.Atoi(item); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+var colorCodePattern = regexp.MustCompile(`\|c[0-9a-fA-F]{8}|\|r`)
+
+// stripColorCodes removes |cAARRGGBB and |r escapes from a toc string
+func stripColorCodes(s string) string {
+	return colorCodePattern.ReplaceAllString(s, "")
+}
@@ -0,0 +1,215 @@
+package wow
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTocFile(t *testing.T) {
+	path := filepath.Join("testdata", "Interface", "AddOns", "DBM-Core", "DBM-Core.toc")
+	addon, err := parseTocFile(path)
+	if err != nil {
+		t.Fatalf("parseTocFile() error = %v", err)
+	}
+
+	if addon.Name != "DBM-Core" {
+		t.Errorf("Name = %v, want DBM-Core", addon.Name)
+	}
+
+	if addon.DisplayTitle() != "<DBM> Core" {
+		t.Errorf("DisplayTitle() = %q, want %q", addon.DisplayTitle(), "<DBM> Core")
+	}
+
+	if addon.Version != "11.0.5" {
+		t.Errorf("Version = %v, want 11.0.5", addon.Version)
+	}
+
+	if len(addon.Interface) != 2 || addon.Interface[0] != 110002 || addon.Interface[1] != 40400 {
+		t.Errorf("Interface = %v, want [110002 40400]", addon.Interface)
+	}
+
+	if len(addon.Dependencies) != 1 || addon.Dependencies[0] != "Ace3" {
+		t.Errorf("Dependencies = %v, want [Ace3]", addon.Dependencies)
+	}
+
+	if len(addon.OptionalDeps) != 2 || addon.OptionalDeps[1] != "LibDBIcon-1.0" {
+		t.Errorf("OptionalDeps = %v, want [LibSharedMedia-3.0 LibDBIcon-1.0]", addon.OptionalDeps)
+	}
+
+	if len(addon.SavedVariables) != 2 {
+		t.Errorf("SavedVariables = %v, want 2 entries", addon.SavedVariables)
+	}
+
+	if len(addon.SavedVariablesPerCharacter) != 1 {
+		t.Errorf("SavedVariablesPerCharacter = %v, want 1 entry", addon.SavedVariablesPerCharacter)
+	}
+}
+
+func TestParseTocFileExtraFields(t *testing.T) {
+	path := filepath.Join("testdata", "Interface", "AddOns", "Details", "Details.toc")
+	addon, err := parseTocFile(path)
+	if err != nil {
+		t.Fatalf("parseTocFile() error = %v", err)
+	}
+
+	// File starts with a UTF-8 byte order mark
+	if len(addon.Interface) != 1 || addon.Interface[0] != 110002 {
+		t.Errorf("Interface = %v, want [110002]", addon.Interface)
+	}
+
+	if addon.LoadOnDemand {
+		t.Error("Expected LoadOnDemand to be false")
+	}
+
+	if len(addon.Dependencies) != 1 || addon.Dependencies[0] != "Ace3" {
+		t.Errorf("Dependencies = %v, want [Ace3]", addon.Dependencies)
+	}
+
+	if addon.Extra["X-Curse-Project-ID"] != "61284" {
+		t.Errorf("X-Curse-Project-ID = %v, want 61284", addon.Extra["X-Curse-Project-ID"])
+	}
+}
+
+func TestFindTocFile(t *testing.T) {
+	addonsDir := filepath.Join("testdata", "Interface", "AddOns")
+
+	tests := []struct {
+		name     string
+		addon    string
+		suffixes []string
+		want     string
+		wantOK   bool
+	}{
+		{
+			name:     "plain toc",
+			addon:    "Ace3",
+			suffixes: []string{"Mainline"},
+			want:     "Ace3.toc",
+			wantOK:   true,
+		},
+		{
+			name:     "underscore flavor toc",
+			addon:    "WeakAuras",
+			suffixes: []string{"Mainline"},
+			want:     "WeakAuras_Mainline.toc",
+			wantOK:   true,
+		},
+		{
+			name:     "flavor order",
+			addon:    "WeakAuras",
+			suffixes: []string{"Vanilla", "Mainline"},
+			want:     "WeakAuras_Vanilla.toc",
+			wantOK:   true,
+		},
+		{
+			name:     "dash flavor toc",
+			addon:    "ClassicOnly",
+			suffixes: []string{"Classic"},
+			want:     "ClassicOnly-Classic.toc",
+			wantOK:   true,
+		},
+		{
+			name:     "no toc for flavor",
+			addon:    "ClassicOnly",
+			suffixes: []string{"Mainline"},
+			wantOK:   false,
+		},
+		{
+			name:     "folder without toc",
+			addon:    "Empty",
+			suffixes: []string{"Mainline"},
+			wantOK:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := findTocFile(filepath.Join(addonsDir, tt.addon), tt.addon, tt.suffixes)
+			if ok != tt.wantOK {
+				t.Fatalf("findTocFile() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && filepath.Base(path) != tt.want {
+				t.Errorf("findTocFile() = %v, want %v", filepath.Base(path), tt.want)
+			}
+		})
+	}
+}
+
+func TestGetInstalledAddons(t *testing.T) {
+	mgr := NewManager("testdata", "", 5)
+	catalog, err := mgr.GetInstalledAddons()
+	if err != nil {
+		t.Fatalf("GetInstalledAddons() error = %v", err)
+	}
+
+	expected := []string{"Ace3", "DBM-Core", "Details", "WeakAuras"}
+	names := catalog.Names()
+	if len(names) != len(expected) {
+		t.Fatalf("Names() = %v, want %v", names, expected)
+	}
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("Names()[%d] = %v, want %v", i, names[i], name)
+		}
+	}
+
+	if catalog["WeakAuras"].Title != "WeakAuras" {
+		t.Errorf("WeakAuras title = %v, want WeakAuras", catalog["WeakAuras"].Title)
+	}
+
+	if _, ok := catalog.Lookup("dbm-core"); !ok {
+		t.Error("Lookup() should match names case-insensitively")
+	}
+
+	if _, ok := catalog.Lookup("Missing"); ok {
+		t.Error("Lookup() found an addon that is not installed")
+	}
+}
+
+func TestGetInstalledAddonsNoDirectory(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	mgr := NewManager(tmpDir, "", 5)
+	if _, err := mgr.GetInstalledAddons(); err == nil {
+		t.Error("Expected error for missing Interface/AddOns directory")
+	}
+}
+
+func TestGetInstalledAddonsSkipsBrokenToc(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wow-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	addonsDir := filepath.Join(tmpDir, "Interface", "AddOns")
+	os.MkdirAll(filepath.Join(addonsDir, "Good"), 0755)
+	os.MkdirAll(filepath.Join(addonsDir, "Broken"), 0755)
+	os.WriteFile(filepath.Join(addonsDir, "Good", "Good.toc"), []byte("## Title: Good\n"), 0644)
+
+	// A line longer than the scanner accepts fails the toc
+	long := "## Notes: " + strings.Repeat("x", maxTocLine) + "\n"
+	os.WriteFile(filepath.Join(addonsDir, "Broken", "Broken.toc"), []byte("## Title: Broken\n"+long), 0644)
+
+	mgr := NewManager(tmpDir, "", 5)
+	var warnings bytes.Buffer
+	mgr.SetWarningOutput(&warnings)
+
+	catalog, err := mgr.GetInstalledAddons()
+	if err != nil {
+		t.Fatalf("GetInstalledAddons() error = %v", err)
+	}
+	if names := catalog.Names(); len(names) != 1 || names[0] != "Good" {
+		t.Errorf("Names() = %v, want [Good]", names)
+	}
+	if !strings.Contains(warnings.String(), "Broken.toc") {
+		t.Errorf("warnings = %q, want the broken toc named", warnings.String())
+	}
+}